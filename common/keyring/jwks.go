@@ -0,0 +1,113 @@
+package keyring
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// JSONWebKey is the public part of an RSA key as described in RFC 7517.
+type JSONWebKey struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+// JSONWebKeySet is a JWKS document.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// readKeyDir adds the PEM encoded RSA keys stored in the directory to the given keys.
+// It returns the IDs of the private keys.
+func readKeyDir(dir string, keys map[string]*key) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list key directory")
+	}
+	var private []string
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read key")
+		}
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			keys[id] = &key{method: jwt.SigningMethodRS256, sign: privateKey, verify: &privateKey.PublicKey}
+			private = append(private, id)
+			continue
+		}
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, errors.Errorf("key %q is neither an RSA private nor public key", id)
+		}
+		keys[id] = &key{method: jwt.SigningMethodRS256, verify: publicKey}
+	}
+	return private, nil
+}
+
+// JSONWebKeys returns the public keys of all RSA keys, sorted by their ID.
+// HMAC secrets are never published.
+func (k *Keyring) JSONWebKeys() []JSONWebKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	jwks := make([]JSONWebKey, 0, len(k.keys))
+	for id, verifier := range k.keys {
+		publicKey, ok := verifier.verify.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		jwks = append(jwks, JSONWebKey{
+			KeyID:     id,
+			KeyType:   "RSA",
+			Algorithm: verifier.method.Alg(),
+			Use:       "sig",
+			N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		})
+	}
+	sort.Slice(jwks, func(i, j int) bool {
+		return jwks[i].KeyID < jwks[j].KeyID
+	})
+	return jwks
+}
+
+// SetPublicKeys replaces the keys retrieved from another service's JWKS.
+// Keys of other types or algorithms than RS256 are ignored.
+func (k *Keyring) SetPublicKeys(jwks []JSONWebKey) error {
+	public := make(map[string]*key, len(jwks))
+	for _, jwk := range jwks {
+		if jwk.KeyType != "RSA" || jwk.Algorithm != jwt.SigningMethodRS256.Alg() || jwk.Use != "sig" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return errors.Wrapf(err, "malformed modulus of key %q", jwk.KeyID)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return errors.Wrapf(err, "malformed exponent of key %q", jwk.KeyID)
+		}
+		public[jwk.KeyID] = &key{
+			method: jwt.SigningMethodRS256,
+			verify: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			},
+		}
+	}
+	k.mu.Lock()
+	k.public = public
+	k.mu.Unlock()
+	return nil
+}
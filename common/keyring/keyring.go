@@ -0,0 +1,282 @@
+// Package keyring manages the keys used to sign and verify tokens.
+// Every token carries the ID of its signing key in the kid header, so keys can be rotated
+// without invalidating tokens signed by older keys.
+// Keys are either HS256 secrets or RS256 key pairs, whose public keys can be published as JWKS.
+package keyring
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// DefaultKeyID identifies the key configured as plain secret.
+// Tokens without a kid header have been issued before key IDs were introduced and are verified using this key.
+const DefaultKeyID = "default"
+
+var (
+	// ErrUnknownKey is returned if a key ID is not part of the keyring.
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrNoKeyFile is returned when promoting a key without a key file.
+	// The promotion could not be shared with other replicas and would be lost on reload.
+	ErrNoKeyFile = errors.New("no key file configured")
+)
+
+// Config describes where the keys of a keyring come from.
+type Config struct {
+	// Secret is stored as key DefaultKeyID.
+	Secret string
+	// Keys is a comma separated list of id:secret pairs.
+	Keys string
+	// Active is the ID of the signing key, it is overridden by the key file.
+	Active string
+	// File is the path of an optional JSON key file.
+	File string
+	// Dir is the path of an optional directory of PEM encoded RSA keys named <id>.pem.
+	// Private keys can sign tokens, public keys only verify them.
+	Dir string
+	// VerifyOnly allows keyrings without an active key, which cannot sign tokens.
+	VerifyOnly bool
+}
+
+// keyFile is the JSON representation of a key file.
+type keyFile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// key is a single signing or verification key.
+type key struct {
+	method jwt.SigningMethod
+	// sign is nil for keys which can only verify tokens.
+	sign   interface{}
+	verify interface{}
+}
+
+func hmacKey(secret []byte) *key {
+	return &key{method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+}
+
+// Keyring stores one active signing key and any number of verification keys.
+// It is safe for concurrent use.
+type Keyring struct {
+	cfg    Config
+	mu     sync.RWMutex
+	active string
+	keys   map[string]*key
+	// public stores verification keys retrieved from a JWKS, they are kept across reloads.
+	public map[string]*key
+}
+
+// New loads the keyring described by the config.
+// It returns an error if no keys are configured or the active key is unknown.
+func New(cfg Config) (*Keyring, error) {
+	k := &Keyring{cfg: cfg}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload reads the configured keys again, picking up changes of the key file.
+func (k *Keyring) Reload() error {
+	keys := make(map[string]*key)
+	if k.cfg.Secret != "" {
+		keys[DefaultKeyID] = hmacKey([]byte(k.cfg.Secret))
+	}
+	for _, pair := range strings.Split(k.cfg.Keys, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return errors.New("malformed key, expected id:secret")
+		}
+		keys[parts[0]] = hmacKey([]byte(parts[1]))
+	}
+	active := k.cfg.Active
+	if k.cfg.File != "" {
+		file, err := readKeyFile(k.cfg.File)
+		if err != nil {
+			return err
+		}
+		for id, secret := range file.Keys {
+			keys[id] = hmacKey([]byte(secret))
+		}
+		if file.Active != "" {
+			active = file.Active
+		}
+	}
+	var rsaKeys []string
+	if k.cfg.Dir != "" {
+		var err error
+		if rsaKeys, err = readKeyDir(k.cfg.Dir, keys); err != nil {
+			return err
+		}
+	}
+	if len(keys) == 0 && !k.cfg.VerifyOnly {
+		return errors.New("no keys configured")
+	}
+	if active == "" {
+		// Prefer a single RSA key over the secret, since it has been added to replace it
+		if len(rsaKeys) == 1 {
+			active = rsaKeys[0]
+		} else if _, ok := keys[DefaultKeyID]; ok {
+			active = DefaultKeyID
+		} else if len(keys) == 1 {
+			for id := range keys {
+				active = id
+			}
+		} else if !k.cfg.VerifyOnly {
+			return errors.New("no active key configured")
+		}
+	}
+	if active != "" {
+		if signer, ok := keys[active]; !ok {
+			return errors.Errorf("active key %q is not configured", active)
+		} else if signer.sign == nil {
+			return errors.Errorf("active key %q cannot sign tokens", active)
+		}
+	}
+	k.mu.Lock()
+	k.active, k.keys = active, keys
+	k.mu.Unlock()
+	return nil
+}
+
+// Active returns the ID of the signing key.
+func (k *Keyring) Active() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// IDs returns the sorted IDs of all keys.
+func (k *Keyring) IDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Promote makes the key with the given ID the signing key.
+// The keyring is reloaded beforehand, so keys freshly added to the key file can be promoted.
+// The promotion is persisted in the key file, it returns ErrNoKeyFile if none is configured.
+// It returns the ID of the previous signing key.
+func (k *Keyring) Promote(id string) (string, error) {
+	if k.cfg.File == "" {
+		return "", ErrNoKeyFile
+	}
+	previous := k.Active()
+	if err := k.Reload(); err != nil {
+		return "", errors.Wrap(err, "failed to reload keys")
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if signer, ok := k.keys[id]; !ok {
+		return "", errors.Wrapf(ErrUnknownKey, "key %q", id)
+	} else if signer.sign == nil {
+		return "", errors.Errorf("key %q cannot sign tokens", id)
+	}
+	file, err := readKeyFile(k.cfg.File)
+	if err != nil {
+		return "", err
+	}
+	file.Active = id
+	if err := writeKeyFile(k.cfg.File, file); err != nil {
+		return "", err
+	}
+	k.active = id
+	return previous, nil
+}
+
+// Sign signs the claims using the active key and stores its ID in the kid header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	id, signer := k.active, k.keys[k.active]
+	k.mu.RUnlock()
+	if id == "" {
+		return "", errors.New("no active key configured")
+	}
+	token := jwt.NewWithClaims(signer.method, claims)
+	token.Header["kid"] = id
+	signed, err := token.SignedString(signer.sign)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign token")
+	}
+	return signed, nil
+}
+
+// Keyfunc looks up the verification key of a token by its kid header.
+// The token has to be signed using the algorithm of the key, so a public key can never be used as HMAC secret.
+// It can be passed to jwt.Parse and jwt.ParseWithClaims.
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	if id == "" {
+		id = DefaultKeyID
+	}
+	k.mu.RLock()
+	verifier, ok := k.keys[id]
+	if !ok {
+		verifier, ok = k.public[id]
+	}
+	k.mu.RUnlock()
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownKey, "key %q", id)
+	}
+	if token.Method.Alg() != verifier.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return verifier.verify, nil
+}
+
+func readKeyFile(path string) (*keyFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read key file")
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, errors.Wrap(err, "failed to parse key file")
+	}
+	return &file, nil
+}
+
+// writeKeyFile replaces the key file atomically, so concurrent readers never see a partial file.
+func writeKeyFile(path string, file *keyFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode key file")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".keys-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create key file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write key file")
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write key file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write key file")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(err, "failed to replace key file")
+	}
+	return nil
+}
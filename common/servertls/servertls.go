@@ -0,0 +1,111 @@
+// Package servertls secures gRPC servers using TLS and optionally verifies client certificates.
+package servertls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Config stores the certificate files of a server.
+type Config struct {
+	CertFile, KeyFile string
+	// ClientCAFile enables mutual TLS, clients have to present a certificate signed by one of its CAs.
+	ClientCAFile string
+	// AdminMethods are the full names of methods only admins may call.
+	AdminMethods []string
+	// Admins are the common names of the client certificates of admins, they require ClientCAFile.
+	// Without admins, the admin methods can not be called at all.
+	Admins []string
+}
+
+// ParseNames splits a comma separated list of names.
+func ParseNames(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Options returns the server options enabling TLS and restricting the admin methods.
+// It returns no TLS options if no certificate is configured, so the server accepts plaintext connections.
+func Options(cfg Config) ([]grpc.ServerOption, error) {
+	if len(cfg.Admins) > 0 && cfg.ClientCAFile == "" {
+		return nil, errors.New("admins require client verification")
+	}
+	var options []grpc.ServerOption
+	if len(cfg.AdminMethods) > 0 {
+		options = append(options, grpc.UnaryInterceptor(adminInterceptor(cfg.AdminMethods, cfg.Admins)))
+	}
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		if cfg.ClientCAFile != "" {
+			return nil, errors.New("client verification requires a server certificate")
+		}
+		return options, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load server certificate")
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if cfg.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read client CA file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in client CA file")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return append(options, grpc.Creds(credentials.NewTLS(tlsConfig))), nil
+}
+
+// adminInterceptor rejects calls of the admin methods by clients that are not admins.
+func adminInterceptor(methods, admins []string) grpc.UnaryServerInterceptor {
+	restricted := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		restricted[method] = true
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if restricted[info.FullMethod] && !isAdmin(ctx, admins) {
+			return nil, status.Error(codes.PermissionDenied, "method requires an admin client certificate")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// isAdmin checks if the client presented a verified certificate issued to one of the admins.
+func isAdmin(ctx context.Context, admins []string) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return false
+	}
+	name := info.State.VerifiedChains[0][0].Subject.CommonName
+	for _, admin := range admins {
+		if name == admin {
+			return true
+		}
+	}
+	return false
+}
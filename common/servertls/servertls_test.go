@@ -0,0 +1,55 @@
+package servertls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func clientContext(name string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{cert}},
+		}},
+	})
+}
+
+func TestAdminInterceptor(t *testing.T) {
+	interceptor := adminInterceptor([]string{"/api.Session/PromoteKey"}, ParseNames(" ops, deploy ,"))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		code   codes.Code
+	}{
+		{"admin", clientContext("ops"), "/api.Session/PromoteKey", codes.OK},
+		{"second admin", clientContext("deploy"), "/api.Session/PromoteKey", codes.OK},
+		{"other client", clientContext("gateway"), "/api.Session/PromoteKey", codes.PermissionDenied},
+		{"plaintext", context.Background(), "/api.Session/PromoteKey", codes.PermissionDenied},
+		{"unrestricted", clientContext("gateway"), "/api.Session/Create", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := interceptor(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.code {
+				t.Errorf("expected %v, got %v", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestOptionsAdminsRequireClientCA(t *testing.T) {
+	if _, err := Options(Config{Admins: []string{"ops"}}); err == nil {
+		t.Error("expected admins without client CA to be rejected")
+	}
+}
@@ -0,0 +1,113 @@
+// Package diff computes line-level differences between two texts.
+// It finds a longest common subsequence of lines, which is good enough for posts of a few thousand lines.
+package diff
+
+import "strings"
+
+// maxCells limits the size of the table used to compare two texts to 4MB.
+// Texts differing in more lines are reported as removed and added as a whole.
+const maxCells = 1 << 20
+
+// Op describes what happened to a line.
+type Op int
+
+const (
+	// Equal lines appear in both texts.
+	Equal Op = iota
+	// Delete lines only appear in the old text.
+	Delete
+	// Insert lines only appear in the new text.
+	Insert
+)
+
+// Line is a line of the old or new text.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines compares the old and new text line by line.
+// It returns the lines of both texts in order, removed lines come before added ones.
+func Lines(old, new string) []Line {
+	a, b := split(old), split(new)
+	// Common prefixes and suffixes are cheap to find and keep the table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	result := make([]Line, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		result = append(result, Line{Equal, text})
+	}
+	result = append(result, middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		result = append(result, Line{Equal, text})
+	}
+	return result
+}
+
+// split splits the text into lines, ignoring the line break at the end of the text.
+func split(text string) []string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// middle compares the lines between the common prefix and suffix.
+func middle(a, b []string) []Line {
+	var result []Line
+	if len(a)*len(b) > maxCells {
+		for _, text := range a {
+			result = append(result, Line{Delete, text})
+		}
+		for _, text := range b {
+			result = append(result, Line{Insert, text})
+		}
+		return result
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	cells := make([]int32, (len(a)+1)*(len(b)+1))
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = cells[i*(len(b)+1) : (i+1)*(len(b)+1)]
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, Line{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, Line{Delete, a[i]})
+			i++
+		default:
+			result = append(result, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, Line{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, Line{Insert, b[j]})
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []Line
+	}{
+		{"empty", "", "", []Line{}},
+		{"equal", "a\nb\n", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{"added", "", "a\nb", []Line{{Insert, "a"}, {Insert, "b"}}},
+		{"removed", "a\nb", "", []Line{{Delete, "a"}, {Delete, "b"}}},
+		{"windows line breaks", "a\r\nb", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{
+			"changed line between prefix and suffix",
+			"a\nb\nc", "a\nx\nc",
+			[]Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}},
+		},
+		{
+			"appended to repeated lines",
+			"a\na", "a\na\na",
+			[]Line{{Equal, "a"}, {Equal, "a"}, {Insert, "a"}},
+		},
+		{
+			"moved line",
+			"a\nb\nc\nd", "a\nc\nb\nd",
+			[]Line{{Equal, "a"}, {Delete, "b"}, {Equal, "c"}, {Insert, "b"}, {Equal, "d"}},
+		},
+		{
+			"common lines in changed middle",
+			"head\nx\nshared\ny\ntail", "head\nshared\nz\ntail",
+			[]Line{{Equal, "head"}, {Delete, "x"}, {Equal, "shared"}, {Delete, "y"}, {Insert, "z"}, {Equal, "tail"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Lines(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLinesFallback(t *testing.T) {
+	// The changed middle exceeds maxCells, so it is replaced as a whole while the prefix and suffix are kept
+	var a, b []string
+	for i := 0; i < 1100; i++ {
+		a = append(a, "old "+strconv.Itoa(i))
+		b = append(b, "new "+strconv.Itoa(i))
+	}
+	a[500], b[600] = "shared", "shared"
+	old := "head\n" + strings.Join(a, "\n") + "\ntail"
+	new := "head\n" + strings.Join(b, "\n") + "\ntail"
+	got := Lines(old, new)
+	if len(got) != len(a)+len(b)+2 {
+		t.Fatalf("expected %d lines, got %d", len(a)+len(b)+2, len(got))
+	}
+	if got[0] != (Line{Equal, "head"}) || got[len(got)-1] != (Line{Equal, "tail"}) {
+		t.Errorf("expected common prefix and suffix to be kept, got %v and %v", got[0], got[len(got)-1])
+	}
+	for i, line := range got[1 : len(got)-1] {
+		want := Delete
+		if i >= len(a) {
+			want = Insert
+		}
+		if line.Op != want {
+			t.Fatalf("expected line %d to be %v, got %v", i+1, want, line.Op)
+		}
+	}
+}
@@ -2,100 +2,210 @@ package email
 
 import (
 	"context"
-	"time"
 
 	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/lnsp/microlog/gateway/internal/rpc"
 	"github.com/lnsp/microlog/mail/api"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// Status is the delivery status of a queued email.
+type Status int
+
+const (
+	// StatusPending is returned while the email is waiting for delivery.
+	StatusPending Status = iota
+	// StatusSent is returned once the email has been handed over for delivery.
+	StatusSent
+	// StatusFailed is returned if the email could not be delivered.
+	StatusFailed
+)
+
+// ErrUnknownMessage is returned if the mail service does not know the message.
+var ErrUnknownMessage = errors.New("unknown message")
+
 type Client struct {
-	data    *models.DataSource
-	service string
+	data   *models.DataSource
+	client api.MailClient
 }
 
-func NewClient(dataSource *models.DataSource, mailService string) *Client {
+// NewClient creates a mail service client using the given connection.
+func NewClient(dataSource *models.DataSource, conn *grpc.ClientConn) *Client {
 	return &Client{
-		data:    dataSource,
-		service: mailService,
+		data:   dataSource,
+		client: api.NewMailClient(conn),
 	}
 }
 
-func (email *Client) serviceClient() (api.MailClient, *grpc.ClientConn, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	conn, err := grpc.DialContext(ctx, email.service, grpc.WithInsecure(), grpc.WithBlock())
-	if err != nil {
-		return nil, nil, errors.Wrapf(err, "failed to dial mail service %s", email.service)
-	}
-	client := api.NewMailClient(conn)
-	return client, conn, nil
+func (email *Client) VerifyConfirmationToken(ctx context.Context, token string) (string, uint, error) {
+	return email.verifyToken(ctx, token, api.VerificationRequest_CONFIRMATION)
+}
+
+func (email *Client) VerifyPasswordResetToken(ctx context.Context, token string) (string, uint, error) {
+	return email.verifyToken(ctx, token, api.VerificationRequest_PASSWORD_RESET)
+}
+
+// ConsumeConfirmationToken verifies the confirmation token and marks it as used.
+func (email *Client) ConsumeConfirmationToken(ctx context.Context, token string) (string, uint, error) {
+	return email.consumeToken(ctx, token, api.VerificationRequest_CONFIRMATION)
 }
 
-func (email *Client) VerifyConfirmationToken(token string) (string, uint, error) {
-	return email.verifyToken(token, api.VerificationRequest_CONFIRMATION)
+// ConsumePasswordResetToken verifies the password reset token and marks it as used.
+func (email *Client) ConsumePasswordResetToken(ctx context.Context, token string) (string, uint, error) {
+	return email.consumeToken(ctx, token, api.VerificationRequest_PASSWORD_RESET)
 }
 
-func (email *Client) VerifyPasswordResetToken(token string) (string, uint, error) {
-	return email.verifyToken(token, api.VerificationRequest_PASSWORD_RESET)
+// ConsumeEmailChangeToken verifies the email change token and marks it as used.
+// It returns the new address, the address it replaces and the user ID.
+func (email *Client) ConsumeEmailChangeToken(ctx context.Context, token string) (string, string, uint, error) {
+	return email.consumeChangeToken(ctx, token, api.VerificationRequest_EMAIL_CHANGE)
 }
 
-func (email *Client) verifyToken(token string, purpose api.VerificationRequest_Purpose) (string, uint, error) {
-	client, conn, err := email.serviceClient()
+// ConsumeEmailChangeUndoToken verifies the token reverting an email change and marks it as used.
+// It returns the previous address to restore, the address it has been replaced with and the user ID.
+func (email *Client) ConsumeEmailChangeUndoToken(ctx context.Context, token string) (string, string, uint, error) {
+	return email.consumeChangeToken(ctx, token, api.VerificationRequest_EMAIL_CHANGE_UNDO)
+}
+
+func (email *Client) verifyToken(ctx context.Context, token string, purpose api.VerificationRequest_Purpose) (string, uint, error) {
+	req := &api.VerificationRequest{
+		Token:   token,
+		Purpose: purpose,
+	}
+	resp, err := email.client.VerifyToken(ctx, req, rpc.Idempotent)
 	if err != nil {
-		return "", 0, errors.Wrap(err, "failed to create client")
+		return "", 0, errors.Wrap(err, "failed to verify token")
 	}
-	defer conn.Close()
+	return resp.Email, uint(resp.Id), nil
+}
+
+func (email *Client) consumeToken(ctx context.Context, token string, purpose api.VerificationRequest_Purpose) (string, uint, error) {
 	req := &api.VerificationRequest{
 		Token:   token,
 		Purpose: purpose,
 	}
-	resp, err := client.VerifyToken(context.Background(), req)
+	resp, err := email.client.ConsumeToken(ctx, req)
 	if err != nil {
-		return "", 0, errors.Wrap(err, "failed to verify token")
+		return "", 0, errors.Wrap(err, "failed to consume token")
 	}
 	return resp.Email, uint(resp.Id), nil
 }
 
-func (email *Client) SendConfirmation(userID uint, emailAddr string) error {
-	user, err := email.data.User(userID)
+func (email *Client) consumeChangeToken(ctx context.Context, token string, purpose api.VerificationRequest_Purpose) (string, string, uint, error) {
+	req := &api.VerificationRequest{
+		Token:   token,
+		Purpose: purpose,
+	}
+	resp, err := email.client.ConsumeToken(ctx, req)
 	if err != nil {
-		return errors.Wrap(err, "failed to find user")
+		return "", "", 0, errors.Wrap(err, "failed to consume token")
+	}
+	return resp.Email, resp.PreviousEmail, uint(resp.Id), nil
+}
+
+// RevokeTokens invalidates all outstanding email tokens of the given user.
+// Links undoing an email change stay valid, so that the previous owner of the address can still use them.
+func (email *Client) RevokeTokens(ctx context.Context, userID uint) error {
+	if _, err := email.client.RevokeTokens(ctx, &api.RevocationRequest{
+		Id: uint32(userID),
+	}, rpc.Idempotent); err != nil {
+		return errors.Wrap(err, "failed to revoke tokens")
 	}
-	client, conn, err := email.serviceClient()
+	return nil
+}
+
+// SendConfirmation queues a confirmation email for the given user.
+// It returns the ID of the queued message.
+func (email *Client) SendConfirmation(ctx context.Context, userID uint, emailAddr string) (string, error) {
+	user, err := email.data.User(userID)
 	if err != nil {
-		return errors.Wrap(err, "failed to create client")
+		return "", errors.Wrap(err, "failed to find user")
 	}
-	defer conn.Close()
-	resp, err := client.SendConfirmation(context.Background(), &api.MailRequest{
+	resp, err := email.client.SendConfirmation(ctx, &api.MailRequest{
 		Name:  user.Name,
 		Id:    uint32(userID),
 		Email: emailAddr,
 	})
 	if err != nil {
-		return errors.Wrapf(err, "failed to send confirmation: %v", resp)
+		return "", errors.Wrap(err, "failed to send confirmation")
 	}
-	return nil
+	return resp.Id, nil
 }
 
-func (email *Client) SendPasswordReset(userID uint, emailAddr string) error {
+// SendEmailChange queues a confirmation to the new address and a notice with an undo link to the previous one.
+// It returns the ID of the queued confirmation.
+func (email *Client) SendEmailChange(ctx context.Context, userID uint, previous, emailAddr string) (string, error) {
 	user, err := email.data.User(userID)
 	if err != nil {
-		return errors.Wrap(err, "failed to find user")
+		return "", errors.Wrap(err, "failed to find user")
+	}
+	resp, err := email.client.SendEmailChange(ctx, &api.EmailChangeRequest{
+		Name:          user.Name,
+		Id:            uint32(userID),
+		Email:         emailAddr,
+		PreviousEmail: previous,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to send email change")
 	}
-	client, conn, err := email.serviceClient()
+	return resp.Id, nil
+}
+
+// SendPasswordReset queues a password reset email for the given user.
+// It returns the ID of the queued message.
+func (email *Client) SendPasswordReset(ctx context.Context, userID uint, emailAddr string) (string, error) {
+	user, err := email.data.User(userID)
 	if err != nil {
-		return errors.Wrap(err, "failed to create client")
+		return "", errors.Wrap(err, "failed to find user")
 	}
-	defer conn.Close()
-	resp, err := client.SendPasswordReset(context.Background(), &api.MailRequest{
+	resp, err := email.client.SendPasswordReset(ctx, &api.MailRequest{
 		Name:  user.Name,
 		Id:    uint32(userID),
 		Email: emailAddr,
 	})
 	if err != nil {
-		return errors.Wrapf(err, "failed to send password reset: %v", resp)
+		return "", errors.Wrap(err, "failed to send password reset")
+	}
+	return resp.Id, nil
+}
+
+// SendPasswordChanged queues a notice about a changed password for the given user.
+// It returns the ID of the queued message.
+func (email *Client) SendPasswordChanged(ctx context.Context, userID uint, emailAddr string) (string, error) {
+	user, err := email.data.User(userID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find user")
+	}
+	resp, err := email.client.SendPasswordChanged(ctx, &api.MailRequest{
+		Name:  user.Name,
+		Id:    uint32(userID),
+		Email: emailAddr,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to send password change notice")
+	}
+	return resp.Id, nil
+}
+
+// Status retrieves the delivery status of the queued message.
+func (email *Client) Status(ctx context.Context, messageID string) (Status, error) {
+	resp, err := email.client.GetStatus(ctx, &api.StatusRequest{
+		Id: messageID,
+	}, rpc.Idempotent)
+	if status.Code(err) == codes.NotFound {
+		return StatusPending, ErrUnknownMessage
+	} else if err != nil {
+		return StatusPending, errors.Wrap(err, "failed to get status")
+	}
+	switch resp.State {
+	case api.StatusResponse_SENT:
+		return StatusSent, nil
+	case api.StatusResponse_FAILED:
+		return StatusFailed, nil
+	default:
+		return StatusPending, nil
 	}
-	return nil
 }
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"github.com/pkg/errors"
+)
+
+// repliesQuery selects the oldest replies to each of the given comments.
+const repliesQuery = `
+SELECT * FROM (
+	SELECT posts.*, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY created_at ASC, id ASC) AS position
+	FROM posts
+	WHERE parent_id IN (?) AND deleted_at IS NULL)
+AS replies
+WHERE position <= ?
+ORDER BY created_at ASC, id ASC`
+
+// postCount is the number of rows referencing a post.
+type postCount struct {
+	PostID uint
+	Count  int
+}
+
+// RepliesOn returns the oldest replies to each of the given comments in chronological order.
+// At max count replies are returned per comment.
+func (data *DataSource) RepliesOn(ids []uint, count int) ([]Post, error) {
+	var replies []Post
+	if len(ids) == 0 {
+		return replies, nil
+	}
+	if err := data.db.Raw(repliesQuery, ids, count).Scan(&replies).Error; err != nil {
+		return nil, errors.Wrap(err, "could not fetch replies")
+	}
+	return replies, nil
+}
+
+// UsersByID returns the users with the given IDs, indexed by their ID.
+func (data *DataSource) UsersByID(ids []uint) (map[uint]User, error) {
+	users := make(map[uint]User)
+	if len(ids) == 0 {
+		return users, nil
+	}
+	var found []User
+	if err := data.db.Where("id IN (?)", ids).Find(&found).Error; err != nil {
+		return nil, errors.Wrap(err, "could not fetch users")
+	}
+	for _, user := range found {
+		users[user.ID] = user
+	}
+	return users, nil
+}
+
+// LikeCounts returns the number of likes of each of the given posts.
+// Posts without likes are missing from the result.
+func (data *DataSource) LikeCounts(ids []uint) (map[uint]int, error) {
+	var counts []postCount
+	if len(ids) > 0 {
+		err := data.db.Model(&Like{}).
+			Select("post_id, COUNT(*) AS count").
+			Where("post_id IN (?)", ids).
+			Group("post_id").
+			Scan(&counts).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "could not count likes")
+		}
+	}
+	return countsByPost(counts), nil
+}
+
+// CommentCounts returns the number of direct comments on each of the given posts.
+// Posts without comments are missing from the result.
+func (data *DataSource) CommentCounts(ids []uint) (map[uint]int, error) {
+	var counts []postCount
+	if len(ids) > 0 {
+		err := data.db.Model(&Post{}).
+			Select("parent_id AS post_id, COUNT(*) AS count").
+			Where("parent_id IN (?)", ids).
+			Group("parent_id").
+			Scan(&counts).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "could not count comments")
+		}
+	}
+	return countsByPost(counts), nil
+}
+
+// LikedPosts returns which of the given posts the user has liked.
+func (data *DataSource) LikedPosts(user uint, ids []uint) (map[uint]bool, error) {
+	liked := make(map[uint]bool)
+	if len(ids) == 0 {
+		return liked, nil
+	}
+	var posts []uint
+	if err := data.db.Model(&Like{}).Where("user_id = ? AND post_id IN (?)", user, ids).Pluck("post_id", &posts).Error; err != nil {
+		return nil, errors.Wrap(err, "could not fetch likes")
+	}
+	for _, post := range posts {
+		liked[post] = true
+	}
+	return liked, nil
+}
+
+func countsByPost(counts []postCount) map[uint]int {
+	result := make(map[uint]int, len(counts))
+	for _, count := range counts {
+		result[count.PostID] = count.Count
+	}
+	return result
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+// errInvalidCursor is returned when parsing a malformed cursor.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// PostCursor is a position in a listing of posts ordered by the time they were published, newest first.
+// Posts take the time they are published as their creation time, so drafts and scheduled posts
+// appear at the top once they are published. The ID orders posts published at the same time.
+type PostCursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+// Cursor returns the position of the post in listings ordered by publishing time.
+func (post *Post) Cursor() PostCursor {
+	return PostCursor{CreatedAt: post.CreatedAt, ID: post.ID}
+}
+
+// String encodes the cursor as text, the zero cursor is encoded as an empty string.
+func (cursor PostCursor) String() string {
+	if cursor.ID == 0 {
+		return ""
+	}
+	return strconv.FormatInt(cursor.CreatedAt.UnixNano(), 10) + "." + strconv.FormatUint(uint64(cursor.ID), 10)
+}
+
+// ParsePostCursor decodes a cursor encoded by String.
+// An empty text decodes to the zero cursor, which points at the newest post.
+func ParsePostCursor(text string) (PostCursor, error) {
+	if text == "" {
+		return PostCursor{}, nil
+	}
+	parts := strings.SplitN(text, ".", 2)
+	if len(parts) != 2 {
+		return PostCursor{}, errInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return PostCursor{}, errInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil || id == 0 {
+		return PostCursor{}, errInvalidCursor
+	}
+	return PostCursor{CreatedAt: time.Unix(0, nanos), ID: uint(id)}, nil
+}
+
+// PopularCursor is a position in a ranking of popular posts, ordered by their votes and then by publishing time.
+type PopularCursor struct {
+	Votes     int
+	CreatedAt time.Time
+	ID        uint
+}
+
+// String encodes the cursor as text, the zero cursor is encoded as an empty string.
+func (cursor PopularCursor) String() string {
+	if cursor.ID == 0 {
+		return ""
+	}
+	return strconv.Itoa(cursor.Votes) + "." + PostCursor{CreatedAt: cursor.CreatedAt, ID: cursor.ID}.String()
+}
+
+// ParsePopularCursor decodes a cursor encoded by String.
+// An empty text decodes to the zero cursor, which points at the top of the ranking.
+func ParsePopularCursor(text string) (PopularCursor, error) {
+	if text == "" {
+		return PopularCursor{}, nil
+	}
+	parts := strings.SplitN(text, ".", 2)
+	if len(parts) != 2 {
+		return PopularCursor{}, errInvalidCursor
+	}
+	votes, err := strconv.Atoi(parts[0])
+	if err != nil || votes < 0 {
+		return PopularCursor{}, errInvalidCursor
+	}
+	post, err := ParsePostCursor(parts[1])
+	if err != nil || post.ID == 0 {
+		return PopularCursor{}, errInvalidCursor
+	}
+	return PopularCursor{Votes: votes, CreatedAt: post.CreatedAt, ID: post.ID}, nil
+}
+
+// postsBefore restricts the query to at max count posts after the cursor, newest first.
+func postsBefore(query *gorm.DB, cursor PostCursor, count int) *gorm.DB {
+	if cursor.ID != 0 {
+		query = query.Where("(posts.created_at, posts.id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	return query.Order("posts.created_at DESC, posts.id DESC").Limit(count)
+}
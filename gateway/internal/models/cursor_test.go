@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPostCursor(t *testing.T) {
+	cursor := PostCursor{CreatedAt: time.Date(2026, 10, 18, 12, 30, 0, 123456000, time.UTC), ID: 42}
+	parsed, err := ParsePostCursor(cursor.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.CreatedAt.Equal(cursor.CreatedAt) || parsed.ID != cursor.ID {
+		t.Errorf("expected %v, got %v", cursor, parsed)
+	}
+	if zero, err := ParsePostCursor(PostCursor{}.String()); err != nil || zero.ID != 0 {
+		t.Errorf("expected zero cursor, got %v (%v)", zero, err)
+	}
+}
+
+func TestParsePostCursorInvalid(t *testing.T) {
+	for _, text := range []string{"42", "abc.42", "1700000000.abc", "1700000000.0", "1700000000.-1", "."} {
+		if _, err := ParsePostCursor(text); err == nil {
+			t.Errorf("expected %q to be rejected", text)
+		}
+	}
+}
+
+func TestPopularCursor(t *testing.T) {
+	cursor := PopularCursor{Votes: 7, CreatedAt: time.Date(2026, 10, 18, 12, 30, 0, 123456000, time.UTC), ID: 42}
+	parsed, err := ParsePopularCursor(cursor.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Votes != cursor.Votes || !parsed.CreatedAt.Equal(cursor.CreatedAt) || parsed.ID != cursor.ID {
+		t.Errorf("expected %v, got %v", cursor, parsed)
+	}
+	if zero, err := ParsePopularCursor(PopularCursor{}.String()); err != nil || zero.ID != 0 {
+		t.Errorf("expected zero cursor, got %v (%v)", zero, err)
+	}
+	for _, text := range []string{"7", "7.42", "x.1700000000.42", "-1.1700000000.42", "7.1700000000.0", "7."} {
+		if _, err := ParsePopularCursor(text); err == nil {
+			t.Errorf("expected %q to be rejected", text)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PostDraft marks posts that are only visible to their author.
+	PostDraft = "draft"
+	// PostScheduled marks drafts that are published once their PublishAt time has passed.
+	PostScheduled = "scheduled"
+	// PostPublished marks posts visible to everyone.
+	PostPublished = "published"
+)
+
+// publishedFilter restricts queries on posts to published posts.
+const publishedFilter = "posts.state = 'published'"
+
+// publishScheduledQuery publishes the scheduled posts that are due.
+// Rows locked by another replica are skipped, so that every post is published exactly once.
+const publishScheduledQuery = `
+UPDATE posts SET state = 'published', created_at = publish_at, publish_at = NULL, updated_at = now()
+WHERE id IN (
+	SELECT id FROM posts
+	WHERE state = 'scheduled' AND publish_at <= ? AND deleted_at IS NULL
+	FOR UPDATE SKIP LOCKED)
+RETURNING id`
+
+// errPostPublished is returned when treating a published post as a draft.
+var errPostPublished = errors.New("post already published")
+
+// Published checks if the post is visible to everyone.
+func (post *Post) Published() bool {
+	return post.State == PostPublished || post.State == ""
+}
+
+// ValidateDraft checks if the title and content of a draft are valid.
+// Drafts are work in progress, so only the maximum lengths are checked.
+func (data *DataSource) ValidateDraft(title, content string) bool {
+	return len(title) <= postTitleMaxLength && len(content) <= postContentMaxLength
+}
+
+// unpublishedPost returns the unpublished post owned by the given user.
+func (data *DataSource) unpublishedPost(author, id uint) (*Post, error) {
+	var post Post
+	data.db.First(&post, id)
+	if post.ID != id {
+		return nil, errPostNotFound
+	}
+	if post.UserID != author {
+		return nil, errPostNotOwned
+	}
+	if post.ParentID != 0 {
+		return nil, errPostIsComment
+	}
+	if post.Published() {
+		return nil, errPostPublished
+	}
+	return &post, nil
+}
+
+// SaveDraft stores the title, content and tags of a draft without changing its state.
+// If the ID is zero, a new draft is created.
+// It returns the ID of the draft and an error if the params are invalid or the post has already been published.
+func (data *DataSource) SaveDraft(author, id uint, title, content string, tags []string) (uint, error) {
+	if !data.ValidateDraft(title, content) || !data.ValidatePostTags(content, tags) {
+		return 0, errValidation
+	}
+	if id == 0 {
+		post := Post{
+			UserID:  author,
+			Title:   title,
+			Content: content,
+			State:   PostDraft,
+		}
+		if err := data.db.Create(&post).Error; err != nil {
+			return 0, errors.Wrap(err, "could not create draft")
+		}
+		id = post.ID
+	} else {
+		if _, err := data.unpublishedPost(author, id); err != nil {
+			return 0, err
+		}
+		// Only the title and content are changed, so that a post published by the scheduler in the meantime stays published
+		update := data.db.Model(&Post{}).Where("id = ? AND state <> ?", id, PostPublished).Updates(map[string]interface{}{
+			"title":   title,
+			"content": content,
+		})
+		if update.Error != nil {
+			return 0, errors.Wrap(update.Error, "could not save draft")
+		}
+		if update.RowsAffected != 1 {
+			return 0, errPostPublished
+		}
+	}
+	if err := data.setPostTags(id, postTags(content, tags)); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+// SetPostState changes the state of an unpublished post.
+// Scheduled posts are published at the given time, published posts take the current time as their creation time.
+// Posts have to be complete before they can be scheduled or published.
+// It returns an error if the post has already been published.
+func (data *DataSource) SetPostState(author, id uint, state string, publishAt time.Time) error {
+	post, err := data.unpublishedPost(author, id)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"state":      state,
+		"publish_at": nil,
+	}
+	switch state {
+	case PostDraft:
+	case PostScheduled, PostPublished:
+		if !data.ValidatePostTitle(post.Title) || !data.ValidatePostContent(post.Content) {
+			return errValidation
+		}
+		if state == PostPublished {
+			updates["created_at"] = time.Now()
+		} else {
+			updates["publish_at"] = publishAt
+		}
+	default:
+		return errValidation
+	}
+	// The state is checked again, so that a post published by the scheduler in the meantime is kept
+	update := data.db.Model(&Post{}).Where("id = ? AND state <> ?", post.ID, PostPublished).Updates(updates)
+	if update.Error != nil {
+		return errors.Wrap(update.Error, "could not change post state")
+	}
+	if update.RowsAffected != 1 {
+		return errPostPublished
+	}
+	return nil
+}
+
+// Drafts returns the drafts and scheduled posts of the given user, most recently changed first.
+func (data *DataSource) Drafts(author uint) ([]Post, error) {
+	var posts []Post
+	err := data.db.
+		Where("user_id = ? AND parent_id = 0 AND state IN (?)", author, []string{PostDraft, PostScheduled}).
+		Order("updated_at DESC").
+		Find(&posts).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch drafts")
+	}
+	return posts, nil
+}
+
+// PublishScheduledPosts publishes the scheduled posts whose time has come.
+// Multiple gateways may call it concurrently.
+// It returns the IDs of the published posts.
+func (data *DataSource) PublishScheduledPosts(now time.Time) ([]uint, error) {
+	rows, err := data.db.Raw(publishScheduledQuery, now).Rows()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not publish scheduled posts")
+	}
+	defer rows.Close()
+	var ids []uint
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return ids, errors.Wrap(err, "could not read published post")
+		}
+		ids = append(ids, id)
+	}
+	return ids, errors.Wrap(rows.Err(), "could not read published posts")
+}
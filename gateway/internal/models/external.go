@@ -0,0 +1,203 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrExternalIdentityLinked is returned when linking an external account that already belongs to a user.
+	ErrExternalIdentityLinked = errors.New("external identity already linked")
+	// ErrLastSignInMethod is returned when unlinking the only way a user can sign in.
+	ErrLastSignInMethod = errors.New("can not remove last sign in method")
+
+	errExternalIdentityNotFound = errors.New("external identity not found")
+	errExternalLoginNotFound    = errors.New("external login not found")
+)
+
+// ExternalIdentity links a user to an account at an external identity provider.
+// The account is identified by the issuer of the provider and the subject it assigned.
+// External identities are deleted permanently, since the account may be linked again.
+type ExternalIdentity struct {
+	gorm.Model
+	Issuer  string `gorm:"unique_index:idx_external_identity"`
+	Subject string `gorm:"unique_index:idx_external_identity"`
+	Email   string
+	UserID  uint
+}
+
+// ExternalLogin stores a sign in at an external identity provider in progress.
+// Once the provider authenticated an unknown account, it holds the account until the user picked a name.
+type ExternalLogin struct {
+	ID    string `gorm:"primary_key"`
+	Nonce string
+	// UserID is the user the account is linked to, it is zero when signing in.
+	UserID uint
+	// Name is the username suggested by the provider.
+	Name          string
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	ExpiresAt     time.Time
+}
+
+// randomHex generates a random hex string of the given number of bytes.
+func randomHex(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// AddExternalLogin starts a sign in at an external identity provider, linking the account to the user if not zero.
+// It returns the login with its ID, which is passed to the provider as state.
+func (data *DataSource) AddExternalLogin(user uint, ttl time.Duration) (*ExternalLogin, error) {
+	id, err := randomHex(32)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate state")
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate nonce")
+	}
+	data.db.Delete(&ExternalLogin{}, "expires_at < ?", time.Now())
+	login := ExternalLogin{
+		ID:        id,
+		Nonce:     nonce,
+		UserID:    user,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := data.db.Create(&login).Error; err != nil {
+		return nil, errors.Wrap(err, "could not store external login")
+	}
+	return &login, nil
+}
+
+// ExternalLogin retrieves a sign in in progress.
+// It returns an error if the login does not exist or has expired.
+func (data *DataSource) ExternalLogin(id string) (*ExternalLogin, error) {
+	var login ExternalLogin
+	data.db.Where("id = ? AND expires_at > ?", id, time.Now()).First(&login)
+	if login.ID != id || id == "" {
+		return nil, errExternalLoginNotFound
+	}
+	return &login, nil
+}
+
+// AuthenticateExternalLogin stores the account the provider authenticated, until the user picked a name.
+// The login can not be used with the provider again.
+func (data *DataSource) AuthenticateExternalLogin(id, name, issuer, subject, email string, emailVerified bool) error {
+	update := data.db.Model(&ExternalLogin{}).Where("id = ? AND subject = ''", id).Updates(map[string]interface{}{
+		"nonce":          "",
+		"name":           name,
+		"issuer":         issuer,
+		"subject":        subject,
+		"email":          email,
+		"email_verified": emailVerified,
+	})
+	if update.Error != nil {
+		return errors.Wrap(update.Error, "could not update external login")
+	}
+	if update.RowsAffected != 1 {
+		return errExternalLoginNotFound
+	}
+	return nil
+}
+
+// DeleteExternalLogin removes a sign in once it has been completed.
+// It returns false if the login has already been removed, so that it can only be completed once.
+func (data *DataSource) DeleteExternalLogin(id string) bool {
+	deletion := data.db.Delete(&ExternalLogin{}, "id = ?", id)
+	return deletion.Error == nil && deletion.RowsAffected == 1
+}
+
+// ExternalIdentity retrieves the external identity of the given account.
+func (data *DataSource) ExternalIdentity(issuer, subject string) (*ExternalIdentity, error) {
+	var identity ExternalIdentity
+	data.db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity)
+	if identity.Subject != subject || subject == "" {
+		return nil, errExternalIdentityNotFound
+	}
+	return &identity, nil
+}
+
+// ExternalIdentities lists the external identities linked to the given user.
+func (data *DataSource) ExternalIdentities(user uint) ([]ExternalIdentity, error) {
+	var identities []ExternalIdentity
+	if err := data.db.Where("user_id = ?", user).Order("id asc").Find(&identities).Error; err != nil {
+		return nil, errors.Wrap(err, "could not list external identities")
+	}
+	return identities, nil
+}
+
+// LinkExternalIdentity links the external account to the given user.
+func (data *DataSource) LinkExternalIdentity(user uint, issuer, subject, email string) error {
+	if _, err := data.ExternalIdentity(issuer, subject); err == nil {
+		return ErrExternalIdentityLinked
+	}
+	identity := ExternalIdentity{
+		Issuer:  issuer,
+		Subject: subject,
+		Email:   email,
+		UserID:  user,
+	}
+	if err := data.db.Create(&identity).Error; err != nil {
+		return errors.Wrap(err, "could not link external identity")
+	}
+	return nil
+}
+
+// UnlinkExternalIdentity removes the external identity of the given user.
+// Users need to keep a confirmed email address or another external identity to sign in with.
+func (data *DataSource) UnlinkExternalIdentity(user, id uint) error {
+	var identity ExternalIdentity
+	data.db.Where("id = ? AND user_id = ?", id, user).First(&identity)
+	if identity.ID != id || id == 0 {
+		return errExternalIdentityNotFound
+	}
+	var external, confirmed int
+	data.db.Model(&ExternalIdentity{}).Where("user_id = ?", user).Count(&external)
+	data.db.Model(&Identity{}).Where("user_id = ? AND confirmed", user).Count(&confirmed)
+	if external <= 1 && confirmed == 0 {
+		return ErrLastSignInMethod
+	}
+	data.db.Unscoped().Delete(&identity)
+	return nil
+}
+
+// AddExternalUser creates a new user signing in with the given external account.
+// A verified email address of the account becomes the primary identity, its password can be set by resetting it.
+// It returns the user ID and an error if the action is unsuccessful.
+func (data *DataSource) AddExternalUser(name, issuer, subject, email string, emailVerified bool) (uint, error) {
+	if !data.ValidateName(name) || subject == "" {
+		return 0, errValidation
+	}
+	if _, err := data.ExternalIdentity(issuer, subject); err == nil {
+		return 0, ErrExternalIdentityLinked
+	}
+	user := User{
+		Name: name,
+		ExternalIdentities: []ExternalIdentity{{
+			Issuer:  issuer,
+			Subject: subject,
+			Email:   email,
+		}},
+	}
+	if emailVerified && data.ValidateEmail(email) && !data.EmailExists(email) {
+		user.Identities = []Identity{{
+			Email:     email,
+			Confirmed: true,
+			Primary:   true,
+		}}
+	}
+	if err := data.db.Create(&user).Error; err != nil {
+		return 0, errors.Wrap(err, "could not create user")
+	}
+	return user.ID, nil
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Follow stores that the follower wants to see the posts of the followee on their timeline.
+// Follows are deleted permanently, so that following again does not collide with the unique index.
+type Follow struct {
+	ID         uint `gorm:"primary_key"`
+	CreatedAt  time.Time
+	FollowerID uint `gorm:"unique_index:idx_follow"`
+	FolloweeID uint `gorm:"unique_index:idx_follow;index"`
+}
+
+// Follow makes the follower follow the followee, following an already followed user has no effect.
+// It returns an error if the action is unsuccessful.
+func (data *DataSource) Follow(follower, followee uint) error {
+	if follower == followee || followee == 0 {
+		return errValidation
+	}
+	var follow Follow
+	if err := data.db.Where(Follow{FollowerID: follower, FolloweeID: followee}).FirstOrCreate(&follow).Error; err != nil {
+		return errors.Wrap(err, "could not follow user")
+	}
+	return nil
+}
+
+// Unfollow removes the followee from the users the follower follows.
+func (data *DataSource) Unfollow(follower, followee uint) error {
+	if err := data.db.Delete(&Follow{}, "follower_id = ? AND followee_id = ?", follower, followee).Error; err != nil {
+		return errors.Wrap(err, "could not unfollow user")
+	}
+	return nil
+}
+
+// IsFollowing checks if the follower follows the followee.
+func (data *DataSource) IsFollowing(follower, followee uint) bool {
+	var count int
+	data.db.Model(&Follow{}).Where("follower_id = ? AND followee_id = ?", follower, followee).Count(&count)
+	return count > 0
+}
+
+// FollowerCount returns the number of users following the given user.
+func (data *DataSource) FollowerCount(user uint) int {
+	var count int
+	data.db.Model(&Follow{}).Where("followee_id = ?", user).Count(&count)
+	return count
+}
+
+// FollowingCount returns the number of users the given user follows.
+func (data *DataSource) FollowingCount(user uint) int {
+	var count int
+	data.db.Model(&Follow{}).Where("follower_id = ?", user).Count(&count)
+	return count
+}
+
+// FollowingTimeline retrieves the posts by users the given user follows published before the cursor, excluding comments.
+// If the cursor is zero, the newest posts are returned. At max count posts are returned, newest first.
+// The followed users are resolved by the database, so that the timeline pages through large follow sets with the posts index.
+// It returns the slice of posts and an error if something unexpected occurs.
+func (data *DataSource) FollowingTimeline(user uint, before PostCursor, count int) ([]Post, error) {
+	var posts []Post
+	query := data.db.
+		Where("parent_id = 0").
+		Where(publishedFilter).
+		Where("user_id IN (SELECT followee_id FROM follows WHERE follower_id = ?)", user)
+	if err := postsBefore(query, before, count).Find(&posts).Error; err != nil {
+		return nil, errors.Wrap(err, "could not fetch timeline")
+	}
+	return posts, nil
+}
@@ -20,6 +20,8 @@ const (
 	postTitleMaxLength    = 80
 	postContentMaxLength  = 80000
 	postContentMinLength  = 10
+	commentMinLength      = 1
+	commentMaxLength      = 4000
 	reportReasonMaxLength = 240
 	usernameMaxLength     = 24
 	biographyMaxLength    = 240
@@ -30,17 +32,28 @@ var (
 	errUserNotFound             = errors.New("could not find user")
 	errIdentityNotFound         = errors.New("could not find identity")
 	errIdentityAlreadyConfirmed = errors.New("identity is already confirmed")
+	errIdentityExists           = errors.New("email is already used by another identity")
+	errIdentityUnconfirmed      = errors.New("identity is not confirmed")
 	errPostNotOwned             = errors.New("can not edit alien post")
+	errPostIsComment            = errors.New("can not edit comment as post")
 	errValidation               = errors.New("can not validate params")
 )
 
+var (
+	// ErrIdentityPrimary is returned when removing the primary identity of a user.
+	ErrIdentityPrimary = errors.New("can not remove primary identity")
+	// ErrLastConfirmedIdentity is returned when removing the only confirmed identity of a user.
+	ErrLastConfirmedIdentity = errors.New("can not remove last confirmed identity")
+)
+
 var (
 	unavailableNames = []string{
-		"microlog", "legal", "auth", "changelog", "profile", "post", "explore", "moderate", "admin",
+		"microlog", "legal", "auth", "changelog", "profile", "post", "explore", "moderate", "admin", "api", "search", "tag",
 	}
 )
 
-// User stores the name, biography, posts and identities of a user.
+// User stores the name, posts and identities of a user.
+// The biography is managed by the profile service, the copy kept here is only written by the profile client and used for search.
 type User struct {
 	gorm.Model
 	Name       string
@@ -49,15 +62,21 @@ type User struct {
 	Posts      []Post     `gorm:"foreignkey:UserID"`
 	Identities []Identity `gorm:"foreignkey:UserID"`
 	Likes      []Like     `gorm:"foreignkey:UserID"`
+
+	// ExternalIdentities are accounts at external identity providers the user signs in with.
+	ExternalIdentities []ExternalIdentity `gorm:"foreignkey:UserID"`
 }
 
 // Identity stores the email, password hash and user.
+// All identities of a user share the same password hash.
 type Identity struct {
 	gorm.Model
 	Email     string
 	Hash      []byte
 	UserID    uint
 	Confirmed bool
+	// Primary marks the identity notifications are sent to.
+	Primary bool `gorm:"column:is_primary"`
 }
 
 // Report stores the post, report author and reason for report.
@@ -70,6 +89,7 @@ type Report struct {
 }
 
 // Post stores the title, content and author of a post.
+// Only published posts are visible to other users, comments are always published.
 type Post struct {
 	gorm.Model
 	Title    string
@@ -77,6 +97,11 @@ type Post struct {
 	ParentID uint
 	UserID   uint
 	Likes    []Like `gorm:"foreignkey:PostID"`
+
+	// State is either PostDraft, PostScheduled or PostPublished.
+	State string `gorm:"default:'published';index"`
+	// PublishAt is the time a scheduled post is published at.
+	PublishAt *time.Time
 }
 
 // Like stores the user and post that got liked.
@@ -109,11 +134,17 @@ func Open(path string) (*DataSource, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create data source")
 	}
-	db.AutoMigrate(&Identity{}, &User{}, &Post{}, &Report{}, &Like{})
+	db.AutoMigrate(&Identity{}, &User{}, &Post{}, &Report{}, &Like{}, &TwoFactor{}, &RecoveryCode{}, &LoginChallenge{}, &ExternalIdentity{}, &ExternalLogin{}, &Follow{}, &Tag{}, &PostTag{}, &PostRevision{})
+	// The following timeline pages through the posts of many users at once, ordered by the time they were published
+	db.Exec("DROP INDEX IF EXISTS idx_posts_user_timeline")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_posts_user_published ON posts (user_id, created_at, id) WHERE parent_id = 0 AND deleted_at IS NULL")
+	if err := setupSearch(db); err != nil {
+		return nil, err
+	}
 	return &DataSource{db}, nil
 }
 
-// ResetPassword sets the password of the related identity.
+// ResetPassword sets the password of all identities of the user owning the related identity.
 // It returns an error if the action was unsuccessful.
 func (data *DataSource) ResetPassword(user uint, email string, password []byte) error {
 	hash, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
@@ -125,8 +156,7 @@ func (data *DataSource) ResetPassword(user uint, email string, password []byte)
 	if identity.UserID != user {
 		return errIdentityNotFound
 	}
-	identity.Hash = hash
-	data.db.Save(&identity)
+	data.db.Model(&Identity{}).Where("user_id = ?", user).Update("hash", hash)
 	return nil
 }
 
@@ -144,25 +174,53 @@ func (data *DataSource) HasUser(email string, password []byte) (uint, bool, erro
 	return id.UserID, id.Confirmed, nil
 }
 
-// UpdatePost updates the title and content of a specific post.
+// UpdatePost updates the title, content and tags of a specific post.
+// The post is tagged with the given tags and the hashtags used in its content.
+// Changes to the title or content of published posts are kept as revisions.
 // This action can only be applied to posts owned by the given user ID.
+// The post is locked while it is updated, so concurrent edits are applied one after another and each keeps its revision.
 // It returns any error if the action is unsuccessful.
-func (data *DataSource) UpdatePost(userID, postID uint, title, content string) error {
-	if !data.ValidatePostTitle(title) || !data.ValidatePostContent(content) {
+func (data *DataSource) UpdatePost(userID, postID uint, title, content string, tags []string) error {
+	if !data.ValidatePostTitle(title) || !data.ValidatePostContent(content) || !data.ValidatePostTags(content, tags) {
 		return errValidation
 	}
+	tx := data.db.Begin()
+	if tx.Error != nil {
+		return errors.Wrap(tx.Error, "could not begin transaction")
+	}
 	var post Post
-	data.db.First(&post, postID)
-	if post.ID != postID {
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&post, postID).Error; gorm.IsRecordNotFoundError(err) {
+		tx.Rollback()
 		return errPostNotFound
+	} else if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "could not lock post")
 	}
 	if post.UserID != userID {
+		tx.Rollback()
 		return errPostNotOwned
 	}
+	if post.ParentID != 0 {
+		tx.Rollback()
+		return errPostIsComment
+	}
+	if post.Published() && (post.Title != title || post.Content != content) {
+		if err := addRevisions(tx, &post, title, content); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
 	post.Title = title
 	post.Content = content
-	data.db.Save(&post)
-	return nil
+	if err := tx.Save(&post).Error; err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "could not update post")
+	}
+	if err := replacePostTags(tx, post.ID, postTags(content, tags)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return errors.Wrap(tx.Commit().Error, "could not commit post")
 }
 
 // AddUser creates a new user with a new default identity.
@@ -179,6 +237,7 @@ func (data *DataSource) AddUser(name, email string, password []byte) (uint, erro
 		Email:     email,
 		Hash:      hash,
 		Confirmed: false,
+		Primary:   true,
 	}
 	user := User{
 		Name:       name,
@@ -207,7 +266,7 @@ func (data *DataSource) AddReport(postID, reporterID uint, reason string) error
 	}
 	var post Post
 	data.db.First(&post, postID)
-	if post.ID != postID {
+	if post.ID != postID || !post.Published() {
 		return errPostNotFound
 	}
 	report := Report{
@@ -276,13 +335,52 @@ func (data *DataSource) UserByName(name string) (*User, error) {
 	return &user, nil
 }
 
+// deleteUnconfirmedQuery marks the users as deleted that have been created before the given time and never confirmed any identity.
+// Users are selected and deleted in one statement, so that users confirming their address in the meantime are kept.
+const deleteUnconfirmedQuery = `
+UPDATE users SET deleted_at = now()
+WHERE deleted_at IS NULL AND created_at < ?
+	AND NOT EXISTS (SELECT 1 FROM identities WHERE identities.user_id = users.id AND identities.confirmed AND identities.deleted_at IS NULL)
+	AND NOT EXISTS (SELECT 1 FROM external_identities WHERE external_identities.user_id = users.id AND external_identities.deleted_at IS NULL)
+RETURNING id`
+
 // DeleteUser deletes a user from the database.
 func (data *DataSource) DeleteUser(id uint) {
 	data.db.Delete(&Identity{}, "user_id = ?", id)
+	// External identities are removed for good, so that the account can be linked to another user
+	data.db.Unscoped().Delete(&ExternalIdentity{}, "user_id = ?", id)
+	data.DisableTwoFactor(id)
+	data.db.Delete(&Follow{}, "follower_id = ? OR followee_id = ?", id, id)
 	data.db.Delete(&Post{}, "user_id = ?", id)
 	data.db.Delete(&User{}, "id = ?", id)
 }
 
+// DeleteUnconfirmedUsers deletes users that have been created before the given time and never confirmed any identity.
+// Users signing in with an external identity are kept.
+// It returns the IDs of the deleted users, so that their data in other services can be removed.
+func (data *DataSource) DeleteUnconfirmedUsers(before time.Time) ([]uint, error) {
+	rows, err := data.db.Raw(deleteUnconfirmedQuery, before).Rows()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not delete unconfirmed users")
+	}
+	defer rows.Close()
+	var ids []uint
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return ids, errors.Wrap(err, "could not read deleted user")
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return ids, errors.Wrap(err, "could not read deleted users")
+	}
+	for _, id := range ids {
+		data.DeleteUser(id)
+	}
+	return ids, nil
+}
+
 // DeletePost deletes a specific post.
 func (data *DataSource) DeletePost(user, id uint) error {
 	var post Post
@@ -329,20 +427,30 @@ func (data *DataSource) ValidateEmail(email string) bool {
 	return false
 }
 
-// PostsByUser retrieves the posts by the given user.
+// PostsByUser retrieves the posts by the given user, excluding comments.
 // It returns the slice of posts and an error if something unexpected occurs.
 func (data *DataSource) PostsByUser(user uint) ([]Post, error) {
 	var posts []Post
-	data.db.Where("user_id = ?", user).Find(&posts)
+	data.db.Where("user_id = ? AND parent_id = 0", user).Where(publishedFilter).Find(&posts)
 	return posts, nil
 }
 
-// RecentPosts fetches the most recent posts.
+// PostsByUserBefore retrieves the posts by the given user published before the cursor, excluding comments.
+// If the cursor is zero, the newest posts are returned. At max count posts are returned, newest first.
+// It returns the slice of posts and an error if something unexpected occurs.
+func (data *DataSource) PostsByUserBefore(user uint, before PostCursor, count int) ([]Post, error) {
+	var posts []Post
+	query := data.db.Where("user_id = ? AND parent_id = 0", user).Where(publishedFilter)
+	postsBefore(query, before, count).Find(&posts)
+	return posts, nil
+}
+
+// RecentPosts fetches the most recent posts, excluding comments.
 // It takes the maximum number of posts to fetch as a parameter.
 // It returns the slice of posts sorted and an error if something unexpected occurs.
 func (data *DataSource) RecentPosts(count int) ([]Post, error) {
 	var posts []Post
-	data.db.Order("created_at DESC").Limit(count).Find(&posts)
+	data.db.Where("parent_id = 0").Where(publishedFilter).Order("created_at DESC").Limit(count).Find(&posts)
 	return posts, nil
 }
 
@@ -355,20 +463,17 @@ func (data *DataSource) RecentUsers(count int) ([]User, error) {
 	return users, nil
 }
 
-// UpdateBiography changes the biography of the given user.
-// It returns an error if the biography is not valid or the user does not exist.
-func (data *DataSource) UpdateBiography(id uint, biography string) error {
-	if !data.ValidateBiography(biography) {
-		return errValidation
-	}
-	var user User
-	data.db.First(&user, id)
-	if user.ID != id {
-		return errUserNotFound
+// RecentUsersBefore fetches the most recent users with an ID lower than before.
+// If before is zero, the newest users are returned. At max count users are returned in descending order.
+// It returns the slice of users and an error if something unexpected occurs.
+func (data *DataSource) RecentUsersBefore(before uint, count int) ([]User, error) {
+	var users []User
+	query := data.db
+	if before != 0 {
+		query = query.Where("id < ?", before)
 	}
-	user.Biography = biography
-	data.db.Save(&user)
-	return nil
+	query.Order("id DESC").Limit(count).Find(&users)
+	return users, nil
 }
 
 // ValidateBiography checks if the biography text is valid.
@@ -387,17 +492,22 @@ func (data *DataSource) ValidatePostContent(content string) bool {
 }
 
 // AddPost creates a new post by the given user and with the given title and content.
+// The post is tagged with the given tags and the hashtags used in its content.
 // It returns the ID of the post and an error if the params are invalid.
-func (data *DataSource) AddPost(author uint, title, content string) (uint, error) {
-	if !data.ValidatePostTitle(title) || !data.ValidatePostContent(content) {
+func (data *DataSource) AddPost(author uint, title, content string, tags []string) (uint, error) {
+	if !data.ValidatePostTitle(title) || !data.ValidatePostContent(content) || !data.ValidatePostTags(content, tags) {
 		return 0, errValidation
 	}
 	post := Post{
 		UserID:  author,
 		Title:   title,
 		Content: content,
+		State:   PostPublished,
 	}
 	data.db.Create(&post)
+	if err := data.setPostTags(post.ID, postTags(content, tags)); err != nil {
+		return post.ID, err
+	}
 	return post.ID, nil
 }
 
@@ -412,14 +522,73 @@ func (data *DataSource) Post(id uint) (*Post, error) {
 	return &post, nil
 }
 
-// CommentsOn returns the comments on the given post.
+// ValidateComment checks if the comment content is valid.
+func (data *DataSource) ValidateComment(content string) bool {
+	return commentMinLength <= len(content) && len(content) <= commentMaxLength
+}
+
+// AddComment creates a new comment by the given user in reply to the given post or comment.
+// It returns the ID of the comment and an error if the params are invalid or the parent does not exist or is unpublished.
+func (data *DataSource) AddComment(author, parent uint, content string) (uint, error) {
+	if !data.ValidateComment(content) {
+		return 0, errValidation
+	}
+	var post Post
+	data.db.First(&post, parent)
+	if post.ID != parent || !post.Published() {
+		return 0, errPostNotFound
+	}
+	comment := Post{
+		UserID:   author,
+		ParentID: parent,
+		Content:  content,
+		State:    PostPublished,
+	}
+	data.db.Create(&comment)
+	return comment.ID, nil
+}
+
+// CommentsOn returns the direct comments on the given post in chronological order.
+// It skips the first offset comments and returns at max count comments.
+// It returns the slice of posts and never an error.
+func (data *DataSource) CommentsOn(id uint, offset, count int) ([]Post, error) {
+	var comments []Post
+	data.db.Where("parent_id = ?", id).Order("created_at ASC").Offset(offset).Limit(count).Find(&comments)
+	return comments, nil
+}
+
+// CommentsAfter returns the direct comments on the given post with an ID greater than after.
+// At max count comments are returned in chronological order.
 // It returns the slice of posts and never an error.
-func (data *DataSource) CommentsOn(id uint) ([]Post, error) {
+func (data *DataSource) CommentsAfter(id, after uint, count int) ([]Post, error) {
 	var comments []Post
-	data.db.Where("parent_id = ?", id).Find(&comments)
+	data.db.Where("parent_id = ? AND id > ?", id, after).Order("id ASC").Limit(count).Find(&comments)
 	return comments, nil
 }
 
+// NumberOfComments retrieves the number of direct comments on the given post.
+// It returns the count and an error if something unexpected occurs.
+func (data *DataSource) NumberOfComments(id uint) (int, error) {
+	var count int
+	data.db.Model(&Post{}).Where("parent_id = ?", id).Count(&count)
+	return count, nil
+}
+
+// ThreadRoot follows the parents of the given post or comment up to the top-level post.
+// It returns the top-level post and an error if any post of the chain does not exist.
+func (data *DataSource) ThreadRoot(id uint) (*Post, error) {
+	post, err := data.Post(id)
+	if err != nil {
+		return nil, err
+	}
+	for post.ParentID != 0 {
+		if post, err = data.Post(post.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	return post, nil
+}
+
 // Identities retrieves the identities associated with the given user.
 // It returns a slice of identities and an error if no identity can be found.
 func (data *DataSource) Identities(user uint) ([]Identity, error) {
@@ -458,6 +627,112 @@ func (data *DataSource) ConfirmIdentity(user uint, email string) error {
 	return nil
 }
 
+// PrimaryIdentity retrieves the identity notifications of the given user are sent to.
+// Users created before identities could be marked as primary use their oldest identity.
+// It returns the identity and an error if the user has no identity.
+func (data *DataSource) PrimaryIdentity(user uint) (*Identity, error) {
+	var identity Identity
+	data.db.Where("user_id = ?", user).Order("is_primary desc, id asc").First(&identity)
+	if identity.UserID != user || user == 0 {
+		return nil, errIdentityNotFound
+	}
+	return &identity, nil
+}
+
+// CheckPassword checks the password of the given user.
+func (data *DataSource) CheckPassword(user uint, password []byte) bool {
+	identity, err := data.PrimaryIdentity(user)
+	if err != nil {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(identity.Hash, password) == nil
+}
+
+// AddIdentity adds an unconfirmed identity sharing the password of the given user.
+// It returns an error if the email is invalid or already used.
+func (data *DataSource) AddIdentity(user uint, email string) error {
+	if !data.ValidateEmail(email) {
+		return errValidation
+	}
+	if data.EmailExists(email) {
+		return errIdentityExists
+	}
+	primary, err := data.PrimaryIdentity(user)
+	if err != nil {
+		return err
+	}
+	identity := Identity{
+		Email:  email,
+		Hash:   primary.Hash,
+		UserID: user,
+	}
+	if err := data.db.Create(&identity).Error; err != nil {
+		return errors.Wrap(err, "could not add identity")
+	}
+	return nil
+}
+
+// SetPrimaryIdentity marks a confirmed identity as primary.
+// It returns an error if the identity does not exist or is not confirmed.
+func (data *DataSource) SetPrimaryIdentity(user uint, email string) error {
+	var identity Identity
+	data.db.Where("user_id = ? AND email = ?", user, email).First(&identity)
+	if identity.UserID != user || user == 0 {
+		return errIdentityNotFound
+	}
+	if !identity.Confirmed {
+		return errIdentityUnconfirmed
+	}
+	tx := data.db.Begin()
+	tx.Model(&Identity{}).Where("user_id = ? AND id <> ?", user, identity.ID).Update("is_primary", false)
+	tx.Model(&identity).Update("is_primary", true)
+	if err := tx.Commit().Error; err != nil {
+		return errors.Wrap(err, "could not set primary identity")
+	}
+	return nil
+}
+
+// ChangeIdentityEmail replaces the address of an identity, the new address counts as confirmed.
+// It returns an error if the identity does not exist or the new address is already used.
+func (data *DataSource) ChangeIdentityEmail(user uint, from, to string) error {
+	var identity Identity
+	data.db.Where("user_id = ? AND email = ?", user, from).First(&identity)
+	if identity.UserID != user || user == 0 {
+		return errIdentityNotFound
+	}
+	if data.EmailExists(to) {
+		return errIdentityExists
+	}
+	data.db.Model(&identity).Updates(map[string]interface{}{"email": to, "confirmed": true})
+	return nil
+}
+
+// RemoveIdentity removes an identity of the given user.
+// It returns an error if the identity is the primary one or the last confirmed one.
+func (data *DataSource) RemoveIdentity(user uint, email string) error {
+	var identity Identity
+	data.db.Where("user_id = ? AND email = ?", user, email).First(&identity)
+	if identity.UserID != user || user == 0 {
+		return errIdentityNotFound
+	}
+	primary, err := data.PrimaryIdentity(user)
+	if err != nil {
+		return err
+	}
+	if primary.ID == identity.ID {
+		return ErrIdentityPrimary
+	}
+	if identity.Confirmed {
+		var confirmed int
+		data.db.Model(&Identity{}).Where("user_id = ? AND confirmed = ?", user, true).Count(&confirmed)
+		if confirmed <= 1 {
+			return ErrLastConfirmedIdentity
+		}
+	}
+	data.db.Delete(&identity)
+	return nil
+}
+
 // NumberOfLikes retrieves the number of likes a post has received.
 // It returns the count and an error if something unexpected occurs.
 func (data *DataSource) NumberOfLikes(id uint) (int, error) {
@@ -499,22 +774,64 @@ func (data *DataSource) HasLiked(user, post uint) bool {
 }
 
 const rankingQuery = `
-SELECT id, created_at, updated_at, title, content, user_id
-FROM posts
-LEFT JOIN (
-	SELECT post_id, COUNT(*) as votes
-	FROM likes
-	WHERE deleted_at IS NULL GROUP BY post_id)
-AS ranking
-ON posts.id = ranking.post_id
-WHERE deleted_at IS NULL AND created_at::date > date '%s'
-ORDER BY ranking.votes ASC, created_at DESC
+SELECT * FROM (
+	SELECT id, created_at, updated_at, title, content, user_id, COALESCE(ranking.votes, 0) AS votes
+	FROM posts
+	LEFT JOIN (
+		SELECT post_id, COUNT(*) as votes
+		FROM likes
+		WHERE deleted_at IS NULL GROUP BY post_id)
+	AS ranking
+	ON posts.id = ranking.post_id
+	WHERE deleted_at IS NULL AND parent_id = 0 AND state = 'published' AND created_at::date > date '%s'%s)
+AS ranked
+WHERE %s
+ORDER BY votes DESC, created_at DESC, id DESC
 LIMIT ?`
 
-// PopularPosts returns the posts created since the given time ranked by their vote count.
-// It returns the slice of posts
-func (data *DataSource) PopularPosts(since time.Time, count int) ([]Post, error) {
-	var posts []Post
-	data.db.Raw(fmt.Sprintf(rankingQuery, since.Format("2006-01-02")), count).Scan(&posts)
-	return posts, nil
+const rankingTagFilter = `
+AND id IN (SELECT post_tags.post_id FROM post_tags JOIN tags ON tags.id = post_tags.tag_id WHERE tags.name = ?)`
+
+// rankingAfter continues the ranking after a cursor.
+const rankingAfter = "(votes, created_at, id) < (?, ?, ?)"
+
+// rankedPost is a post along with the number of votes it is ranked by.
+type rankedPost struct {
+	Post
+	Votes int
+}
+
+// PopularPosts returns the posts created since the given time ranked by their vote count, most votes first.
+// If the tag is not empty, only posts tagged with it are ranked.
+// It continues the ranking after the given cursor, the zero cursor starts at the top, and returns at max count posts.
+// It returns the slice of posts, the position of the last one and an error if something unexpected occurs.
+func (data *DataSource) PopularPosts(since time.Time, tag string, after PopularCursor, count int) ([]Post, PopularCursor, error) {
+	var (
+		args   []interface{}
+		filter = ""
+		where  = "TRUE"
+	)
+	if tag != "" {
+		filter = rankingTagFilter
+		args = append(args, tag)
+	}
+	if after.ID != 0 {
+		where = rankingAfter
+		args = append(args, after.Votes, after.CreatedAt, after.ID)
+	}
+	var ranked []rankedPost
+	query := fmt.Sprintf(rankingQuery, since.Format("2006-01-02"), filter, where)
+	if err := data.db.Raw(query, append(args, count)...).Scan(&ranked).Error; err != nil {
+		return nil, PopularCursor{}, errors.Wrap(err, "could not rank posts")
+	}
+	posts := make([]Post, len(ranked))
+	for i := range ranked {
+		posts[i] = ranked[i].Post
+	}
+	var last PopularCursor
+	if len(ranked) > 0 {
+		post := ranked[len(ranked)-1]
+		last = PopularCursor{Votes: post.Votes, CreatedAt: post.CreatedAt, ID: post.ID}
+	}
+	return posts, last, nil
 }
@@ -0,0 +1,47 @@
+package models
+
+import "strings"
+
+// commonPasswords lists frequently used and breached passwords satisfying the minimum length.
+// It is bundled with the gateway, so that checking a password never leaves the service.
+var commonPasswords = makePasswordSet(`
+12345678 123456789 1234567890 12345678910 123123123 123321123 987654321 0987654321
+11111111 111111111 1111111111 00000000 000000000 0000000000 22222222 55555555
+66666666 77777777 88888888 99999999 12341234 11223344 112233445566 123456654321
+147258369 159753456 741852963 1q2w3e4r 1q2w3e4r5t 1q2w3e4r5t6y 1qaz2wsx 1qazxsw2
+zaq12wsx zaq1zaq1 qwertyui qwertyuiop qwerty123 qwerty12 qwerty1234 qwertz123
+asdfghjk asdfghjkl asdf1234 zxcvbnm1 zxcvbnm123 azertyuiop q1w2e3r4 q1w2e3r4t5
+a1b2c3d4 abc12345 abcd1234 abcdefgh 1234abcd aaaaaaaa password password1
+password12 password123 password1234 passw0rd p@ssw0rd p@ssword pa55word
+passwort passwort1 motdepasse contraseña iloveyou iloveyou1 iloveyou2
+sunshine sunshine1 princess princess1 football football1 baseball basketball
+superman batman123 spiderman starwars pokemon1 trustno1 whatever whatever1
+welcome1 welcome123 letmein1 letmein123 changeme changeme1 administrator
+admin123 admin1234 adminadmin rootroot root1234 test1234 testtest testing123
+guest123 default1 computer internet michael1 jennifer jordan23 charlie1
+mustang1 liverpool chelsea1 arsenal1 barcelona manchester newcastle
+cowboys1 steelers yankees1 lakers24 midnight1 shadow12 master12 monkey12
+dragon12 dragonball butterfly chocolate cheese123 cookie123 flower123
+lovely123 babygirl babygirl1 sweetheart sweety123 loveyou1 lovelove
+fuckyou1 fuckoff1 69696969 asshole1 12qwaszx qazwsxedc qazwsx123
+1234qwer qwer1234 qwe123qwe asd123asd zxc123zxc 123qweasd 123qweasdzxc
+1234567a 12345678a a12345678 aa123456 aa12345678 abc123456 abcd12345
+q1234567 q12345678 qq123456 pass1234 pass12345 summer2019 summer2020
+spring2020 winter2020 autumn2020 january1 december1 freedom1 football12
+microlog microlog1 microlog123
+`)
+
+// makePasswordSet builds a lookup set from a whitespace separated list of passwords.
+func makePasswordSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, password := range strings.Fields(list) {
+		set[password] = true
+	}
+	return set
+}
+
+// CommonPassword checks if the password is on the list of common and breached passwords.
+// The comparison ignores case, since changing the case of a common password hardly makes it safer.
+func (data *DataSource) CommonPassword(password string) bool {
+	return commonPasswords[strings.ToLower(password)]
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+// PostRevision stores a version of the title and content of a post.
+// Revisions are never changed or deleted, so that edits can be followed even after a post has been removed.
+// Once a post has been edited, its first revision is the original version and its last revision the current one.
+type PostRevision struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	PostID    uint `gorm:"index"`
+	Title     string
+	Content   string
+}
+
+// addRevisions stores the new version of the post, preceded by its original version if it has not been edited before.
+// The post must hold the previous version and be locked by the given transaction.
+func addRevisions(tx *gorm.DB, post *Post, title, content string) error {
+	var count int
+	if err := tx.Model(&PostRevision{}).Where("post_id = ?", post.ID).Count(&count).Error; err != nil {
+		return errors.Wrap(err, "could not count revisions")
+	}
+	if count == 0 {
+		original := PostRevision{
+			CreatedAt: post.CreatedAt,
+			PostID:    post.ID,
+			Title:     post.Title,
+			Content:   post.Content,
+		}
+		if err := tx.Create(&original).Error; err != nil {
+			return errors.Wrap(err, "could not store original revision")
+		}
+	}
+	revision := PostRevision{
+		PostID:  post.ID,
+		Title:   title,
+		Content: content,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return errors.Wrap(err, "could not store revision")
+	}
+	return nil
+}
+
+// Revisions returns the revisions of the given post, from the original to the current version.
+// Posts that have never been edited have no revisions.
+func (data *DataSource) Revisions(post uint) ([]PostRevision, error) {
+	var revisions []PostRevision
+	if err := data.db.Where("post_id = ?", post).Order("id ASC").Find(&revisions).Error; err != nil {
+		return nil, errors.Wrap(err, "could not fetch revisions")
+	}
+	return revisions, nil
+}
+
+// NumberOfRevisions returns the number of revisions of the given post.
+func (data *DataSource) NumberOfRevisions(post uint) (int, error) {
+	var count int
+	if err := data.db.Model(&PostRevision{}).Where("post_id = ?", post).Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "could not count revisions")
+	}
+	return count, nil
+}
+
+// LastEdit returns the time the given post has last been edited.
+// It returns false if the post has never been edited.
+func (data *DataSource) LastEdit(post uint) (time.Time, bool) {
+	var revision PostRevision
+	data.db.Where("post_id = ?", post).Order("id DESC").First(&revision)
+	if revision.PostID != post || revision.ID == 0 {
+		return time.Time{}, false
+	}
+	return revision.CreatedAt, true
+}
+
+// LastEdits returns the time of the last edit of each of the given posts.
+// Posts that have never been edited are missing from the result.
+func (data *DataSource) LastEdits(ids []uint) (map[uint]time.Time, error) {
+	edits := make(map[uint]time.Time)
+	if len(ids) == 0 {
+		return edits, nil
+	}
+	var rows []struct {
+		PostID   uint
+		EditedAt time.Time
+	}
+	err := data.db.Model(&PostRevision{}).
+		Select("post_id, MAX(created_at) AS edited_at").
+		Where("post_id IN (?)", ids).
+		Group("post_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch revisions")
+	}
+	for _, row := range rows {
+		edits[row.PostID] = row.EditedAt
+	}
+	return edits, nil
+}
+
+// PostWithDeleted returns the post identified by the given ID, even if it has been deleted.
+// It is used by moderators to review removed posts.
+func (data *DataSource) PostWithDeleted(id uint) (*Post, error) {
+	var post Post
+	data.db.Unscoped().First(&post, id)
+	if post.ID != id {
+		return nil, errPostNotFound
+	}
+	return &post, nil
+}
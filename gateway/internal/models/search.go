@@ -0,0 +1,183 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// searchMaxLength limits the length of search queries.
+	searchMaxLength = 200
+	// highlightStart and highlightStop delimit the matches in snippets returned by the database.
+	// They are control characters, so that they can not be confused with the text of posts.
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+	// searchMinVersion is the first PostgreSQL version supporting the generated columns used by search.
+	searchMinVersion = 120000
+)
+
+// searchSchema adds the generated search documents and their indexes.
+// Titles and names rank higher than contents and biographies, names are not stemmed.
+var searchSchema = []string{
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(content, '')), 'B')) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_posts_search ON posts USING GIN (search)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(biography, '')), 'B')) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_users_search ON users USING GIN (search)`,
+}
+
+// setupSearch adds the search documents and indexes, if the server supports them.
+func setupSearch(db *gorm.DB) error {
+	var version int
+	if err := db.Raw("SHOW server_version_num").Row().Scan(&version); err != nil {
+		return errors.Wrap(err, "could not determine database version")
+	}
+	if version < searchMinVersion {
+		return errors.Errorf("search requires at least PostgreSQL 12, found version %d", version)
+	}
+	for _, statement := range searchSchema {
+		if err := db.Exec(statement).Error; err != nil {
+			return errors.Wrap(err, "could not set up search")
+		}
+	}
+	return nil
+}
+
+const headlineOptions = "MaxFragments=2, MaxWords=25, MinWords=10, StartSel=" + highlightStart + ", StopSel=" + highlightStop
+
+const postSearchQuery = `
+SELECT posts.*, ts_headline('english', content, query, '` + headlineOptions + `') AS snippet
+FROM posts, websearch_to_tsquery('english', ?) query
+WHERE search @@ query AND deleted_at IS NULL AND parent_id = 0 AND state = 'published'%s
+ORDER BY ts_rank(search, query) DESC, id DESC
+OFFSET ? LIMIT ?`
+
+const userSearchQuery = `
+SELECT users.*, ts_headline('simple', biography, query, '` + headlineOptions + `') AS snippet
+FROM users, websearch_to_tsquery('simple', ?) query
+WHERE search @@ query AND deleted_at IS NULL
+ORDER BY ts_rank(search, query) DESC, id DESC
+OFFSET ? LIMIT ?`
+
+// Highlight is a part of a search snippet, which is marked if it matches the query.
+type Highlight struct {
+	Text  string
+	Match bool
+}
+
+// PostFilter restricts the posts found by a search.
+// Zero values do not restrict the results.
+type PostFilter struct {
+	// Author is the ID of the user who wrote the post.
+	Author uint
+	// From and To limit the creation time of the post, From is inclusive and To is exclusive.
+	From, To time.Time
+}
+
+// PostResult is a post found by a search.
+type PostResult struct {
+	Post
+	Snippet []Highlight
+}
+
+// UserResult is a user found by a search.
+type UserResult struct {
+	User
+	Snippet []Highlight
+}
+
+// parseHighlights splits a snippet returned by the database into its highlights.
+func parseHighlights(snippet string) []Highlight {
+	var highlights []Highlight
+	for _, part := range strings.Split(snippet, highlightStart) {
+		match := strings.SplitN(part, highlightStop, 2)
+		if len(match) == 2 {
+			highlights = append(highlights, Highlight{Text: match[0], Match: true})
+			part = match[1]
+		}
+		if part != "" {
+			highlights = append(highlights, Highlight{Text: part})
+		}
+	}
+	return highlights
+}
+
+// ValidateSearch checks if the search query is valid.
+func (data *DataSource) ValidateSearch(query string) bool {
+	query = strings.TrimSpace(query)
+	return query != "" && len(query) <= searchMaxLength && !strings.ContainsAny(query, highlightStart+highlightStop)
+}
+
+// SearchPosts finds the posts matching the query, ranked by relevance and excluding comments.
+// It skips the first offset results and returns at max count posts.
+// It returns the slice of results and an error if something unexpected occurs.
+func (data *DataSource) SearchPosts(query string, filter PostFilter, offset, count int) ([]PostResult, error) {
+	if !data.ValidateSearch(query) {
+		return nil, errValidation
+	}
+	var (
+		conditions strings.Builder
+		args       = []interface{}{query}
+	)
+	if filter.Author != 0 {
+		conditions.WriteString(" AND user_id = ?")
+		args = append(args, filter.Author)
+	}
+	if !filter.From.IsZero() {
+		conditions.WriteString(" AND created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions.WriteString(" AND created_at < ?")
+		args = append(args, filter.To)
+	}
+	args = append(args, offset, count)
+	var rows []struct {
+		Post
+		Snippet string
+	}
+	if err := data.db.Raw(fmt.Sprintf(postSearchQuery, conditions.String()), args...).Scan(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, "could not search posts")
+	}
+	results := make([]PostResult, len(rows))
+	for i, row := range rows {
+		results[i] = PostResult{row.Post, parseHighlights(row.Snippet)}
+	}
+	return results, nil
+}
+
+// SearchUsers finds the users whose name or biography matches the query, ranked by relevance.
+// It skips the first offset results and returns at max count users.
+// It returns the slice of results and an error if something unexpected occurs.
+func (data *DataSource) SearchUsers(query string, offset, count int) ([]UserResult, error) {
+	if !data.ValidateSearch(query) {
+		return nil, errValidation
+	}
+	var rows []struct {
+		User
+		Snippet string
+	}
+	if err := data.db.Raw(userSearchQuery, query, offset, count).Scan(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, "could not search users")
+	}
+	results := make([]UserResult, len(rows))
+	for i, row := range rows {
+		results[i] = UserResult{row.User, parseHighlights(row.Snippet)}
+	}
+	return results, nil
+}
+
+// SetBiography stores a copy of the biography managed by the profile service, so that users can be found by it.
+func (data *DataSource) SetBiography(user uint, biography string) error {
+	if err := data.db.Model(&User{}).Where("id = ?", user).Update("biography", biography).Error; err != nil {
+		return errors.Wrap(err, "could not store biography")
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	tagMaxLength   = 32
+	tagsMaxPerPost = 10
+)
+
+var (
+	tagPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+	// hashtagPattern finds hashtags in post contents, ignoring fragments of URLs and HTML entities.
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\w&/#])#([A-Za-z0-9_]+)`)
+)
+
+// Tag is a topic posts can be tagged with.
+type Tag struct {
+	ID   uint   `gorm:"primary_key"`
+	Name string `gorm:"unique_index"`
+}
+
+// PostTag links a post to one of its tags.
+type PostTag struct {
+	PostID    uint `gorm:"primary_key"`
+	TagID     uint `gorm:"primary_key;index"`
+	CreatedAt time.Time
+}
+
+// TagCount is a tag and the number of posts tagged with it.
+type TagCount struct {
+	Name  string
+	Posts int
+}
+
+// NormalizeTag turns the tag into its canonical lowercase form without a leading hash.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// ParseTags splits a list of tags separated by commas or whitespace.
+// The tags are normalized, duplicates are removed.
+func ParseTags(list string) []string {
+	fields := strings.FieldsFunc(list, func(c rune) bool {
+		return c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r'
+	})
+	return uniqueTags(fields)
+}
+
+// Hashtags returns the normalized hashtags used in the content.
+func Hashtags(content string) []string {
+	var tags []string
+	for _, match := range hashtagPattern.FindAllStringSubmatch(content, -1) {
+		tags = append(tags, match[1])
+	}
+	return uniqueTags(tags)
+}
+
+// uniqueTags normalizes the tags and removes duplicates and empty tags, keeping their order.
+func uniqueTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		unique = append(unique, tag)
+	}
+	return unique
+}
+
+// postTags merges the given tags with the hashtags used in the content.
+func postTags(content string, tags []string) []string {
+	return uniqueTags(append(tags, Hashtags(content)...))
+}
+
+// ValidateTag checks if the tag name is valid.
+func (data *DataSource) ValidateTag(tag string) bool {
+	return len(tag) <= tagMaxLength && tagPattern.MatchString(tag)
+}
+
+// ValidatePostTags checks if the tags of a post with the given content are valid.
+func (data *DataSource) ValidatePostTags(content string, tags []string) bool {
+	merged := postTags(content, tags)
+	if len(merged) > tagsMaxPerPost {
+		return false
+	}
+	for _, tag := range merged {
+		if !data.ValidateTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// setPostTags replaces the tags of the post with the given tags, creating missing tags.
+func (data *DataSource) setPostTags(post uint, tags []string) error {
+	tx := data.db.Begin()
+	if err := replacePostTags(tx, post, tags); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return errors.Wrap(tx.Commit().Error, "could not commit tags")
+}
+
+// replacePostTags replaces the tags of the post within the given transaction, creating missing tags.
+func replacePostTags(tx *gorm.DB, post uint, tags []string) error {
+	if err := tx.Delete(&PostTag{}, "post_id = ?", post).Error; err != nil {
+		return errors.Wrap(err, "could not remove tags")
+	}
+	for _, name := range tags {
+		var tag Tag
+		if err := tx.Where(Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return errors.Wrap(err, "could not create tag")
+		}
+		if err := tx.Create(&PostTag{PostID: post, TagID: tag.ID}).Error; err != nil {
+			return errors.Wrap(err, "could not tag post")
+		}
+	}
+	return nil
+}
+
+// PostTags returns the names of the tags of the given post in alphabetical order.
+func (data *DataSource) PostTags(post uint) ([]string, error) {
+	var names []string
+	err := data.db.Table("tags").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Where("post_tags.post_id = ?", post).
+		Order("tags.name ASC").
+		Pluck("tags.name", &names).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch tags")
+	}
+	return names, nil
+}
+
+// TagsOfPosts returns the names of the tags of each of the given posts in alphabetical order, indexed by the post ID.
+// Posts without tags are missing from the result.
+func (data *DataSource) TagsOfPosts(ids []uint) (map[uint][]string, error) {
+	tags := make(map[uint][]string)
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	var rows []struct {
+		PostID uint
+		Name   string
+	}
+	err := data.db.Table("tags").
+		Select("post_tags.post_id, tags.name").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Where("post_tags.post_id IN (?)", ids).
+		Order("tags.name ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch tags")
+	}
+	for _, row := range rows {
+		tags[row.PostID] = append(tags[row.PostID], row.Name)
+	}
+	return tags, nil
+}
+
+// PostsByTagBefore retrieves the posts tagged with the given tag published before the cursor.
+// If the cursor is zero, the newest posts are returned. At max count posts are returned, newest first.
+// It returns the slice of posts and an error if something unexpected occurs.
+func (data *DataSource) PostsByTagBefore(tag string, before PostCursor, count int) ([]Post, error) {
+	var posts []Post
+	query := data.db.Where(publishedFilter).Where("id IN (SELECT post_tags.post_id FROM post_tags JOIN tags ON tags.id = post_tags.tag_id WHERE tags.name = ?)", tag)
+	if err := postsBefore(query, before, count).Find(&posts).Error; err != nil {
+		return nil, errors.Wrap(err, "could not fetch tagged posts")
+	}
+	return posts, nil
+}
+
+// TrendingTags returns the tags used by most posts created since the given time.
+// At max count tags are returned, ordered by the number of posts.
+func (data *DataSource) TrendingTags(since time.Time, count int) ([]TagCount, error) {
+	var tags []TagCount
+	err := data.db.Table("tags").
+		Select("tags.name AS name, COUNT(*) AS posts").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id").
+		Where("posts.deleted_at IS NULL AND posts.created_at > ?", since).
+		Where(publishedFilter).
+		Group("tags.name").
+		Order("posts DESC, tags.name ASC").
+		Limit(count).
+		Scan(&tags).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch trending tags")
+	}
+	return tags, nil
+}
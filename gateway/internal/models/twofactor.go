@@ -0,0 +1,206 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeSize is the length of a recovery code in bytes, 5 bytes encode to 8 base32 characters.
+	recoveryCodeSize = 5
+	// loginChallengeAttempts is the number of wrong codes after which a login challenge is dropped.
+	loginChallengeAttempts = 5
+)
+
+var (
+	errTwoFactorNotFound       = errors.New("could not find two-factor authentication")
+	errTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	errLoginChallengeNotFound  = errors.New("could not find login challenge")
+)
+
+// TwoFactor stores the TOTP secret of a user.
+// The secret is stored once enrollment starts, but only required for login after it has been enabled.
+type TwoFactor struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	UserID    uint `gorm:"unique_index"`
+	Secret    string
+	Enabled   bool
+	// LastStep is the time step of the last accepted code, so that codes can not be replayed.
+	LastStep int64
+}
+
+// RecoveryCode stores the hash of a one-time recovery code.
+// Recovery codes are random, so a plain SHA-256 hash suffices to protect them.
+type RecoveryCode struct {
+	ID     uint `gorm:"primary_key"`
+	UserID uint `gorm:"index"`
+	Hash   string
+}
+
+// LoginChallenge stores a login which passed the password check but still requires the second factor.
+type LoginChallenge struct {
+	ID        string `gorm:"primary_key"`
+	UserID    uint
+	Email     string
+	Remember  bool
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+// TwoFactor retrieves the two-factor authentication of the given user.
+// It returns an error if the user has never started the enrollment.
+func (data *DataSource) TwoFactor(user uint) (*TwoFactor, error) {
+	var twoFactor TwoFactor
+	data.db.Where("user_id = ?", user).First(&twoFactor)
+	if twoFactor.UserID != user || user == 0 {
+		return nil, errTwoFactorNotFound
+	}
+	return &twoFactor, nil
+}
+
+// TwoFactorEnabled checks if the given user has to provide a second factor on login.
+func (data *DataSource) TwoFactorEnabled(user uint) bool {
+	twoFactor, err := data.TwoFactor(user)
+	return err == nil && twoFactor.Enabled
+}
+
+// BeginTwoFactor stores the secret of a new enrollment, replacing any unfinished one.
+// It returns an error if two-factor authentication is already enabled.
+func (data *DataSource) BeginTwoFactor(user uint, secret string) error {
+	if data.TwoFactorEnabled(user) {
+		return errTwoFactorAlreadyEnabled
+	}
+	data.db.Delete(&TwoFactor{}, "user_id = ?", user)
+	if err := data.db.Create(&TwoFactor{UserID: user, Secret: secret}).Error; err != nil {
+		return errors.Wrap(err, "could not store secret")
+	}
+	return nil
+}
+
+// EnableTwoFactor finishes the enrollment using the time step of the first valid code.
+// It returns a fresh set of recovery codes and an error if no enrollment has been started.
+func (data *DataSource) EnableTwoFactor(user uint, step int64) ([]string, error) {
+	update := data.db.Model(&TwoFactor{}).Where("user_id = ? AND enabled = ?", user, false).
+		Updates(map[string]interface{}{"enabled": true, "last_step": step})
+	if update.Error != nil {
+		return nil, errors.Wrap(update.Error, "could not enable two-factor authentication")
+	}
+	if update.RowsAffected != 1 {
+		return nil, errTwoFactorNotFound
+	}
+	return data.RegenerateRecoveryCodes(user)
+}
+
+// DisableTwoFactor removes the secret and recovery codes of the given user.
+func (data *DataSource) DisableTwoFactor(user uint) {
+	data.db.Delete(&TwoFactor{}, "user_id = ?", user)
+	data.db.Delete(&RecoveryCode{}, "user_id = ?", user)
+}
+
+// UseTwoFactorStep marks the time step of a valid code as used.
+// It returns false if a code of the same or a later time step has been accepted before.
+func (data *DataSource) UseTwoFactorStep(user uint, step int64) bool {
+	update := data.db.Model(&TwoFactor{}).Where("user_id = ? AND enabled = ? AND last_step < ?", user, true, step).
+		Update("last_step", step)
+	return update.Error == nil && update.RowsAffected == 1
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the given user.
+// It returns the new codes, which can not be retrieved again afterwards.
+func (data *DataSource) RegenerateRecoveryCodes(user uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, errors.Wrap(err, "could not generate recovery code")
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+		codes[i] = code[:4] + "-" + code[4:]
+	}
+	tx := data.db.Begin()
+	tx.Delete(&RecoveryCode{}, "user_id = ?", user)
+	for _, code := range codes {
+		tx.Create(&RecoveryCode{UserID: user, Hash: hashRecoveryCode(code)})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.Wrap(err, "could not store recovery codes")
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode consumes one of the recovery codes of the given user.
+// It returns false if the code is unknown or has already been used.
+func (data *DataSource) UseRecoveryCode(user uint, code string) bool {
+	deletion := data.db.Delete(&RecoveryCode{}, "user_id = ? AND hash = ?", user, hashRecoveryCode(code))
+	return deletion.Error == nil && deletion.RowsAffected == 1
+}
+
+// RecoveryCodesLeft counts the unused recovery codes of the given user.
+func (data *DataSource) RecoveryCodesLeft(user uint) int {
+	var count int
+	data.db.Model(&RecoveryCode{}).Where("user_id = ?", user).Count(&count)
+	return count
+}
+
+// hashRecoveryCode hashes the code, ignoring case, spaces and dashes.
+func hashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddLoginChallenge stores a pending login of the given user and identity, expired challenges are dropped.
+// It returns the random challenge ID and an error if the challenge could not be stored.
+func (data *DataSource) AddLoginChallenge(user uint, email string, remember bool, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Wrap(err, "could not generate challenge")
+	}
+	data.db.Delete(&LoginChallenge{}, "expires_at < ?", time.Now())
+	challenge := LoginChallenge{
+		ID:        hex.EncodeToString(raw),
+		UserID:    user,
+		Email:     email,
+		Remember:  remember,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := data.db.Create(&challenge).Error; err != nil {
+		return "", errors.Wrap(err, "could not store challenge")
+	}
+	return challenge.ID, nil
+}
+
+// LoginChallenge retrieves a pending login.
+// It returns an error if the challenge does not exist or has expired.
+func (data *DataSource) LoginChallenge(id string) (*LoginChallenge, error) {
+	var challenge LoginChallenge
+	data.db.Where("id = ? AND expires_at > ?", id, time.Now()).First(&challenge)
+	if challenge.ID != id || id == "" {
+		return nil, errLoginChallengeNotFound
+	}
+	return &challenge, nil
+}
+
+// FailLoginChallenge records a wrong code for the pending login.
+// It returns false if the challenge has been dropped because of too many attempts.
+func (data *DataSource) FailLoginChallenge(id string) bool {
+	data.db.Model(&LoginChallenge{}).Where("id = ?", id).UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	deletion := data.db.Delete(&LoginChallenge{}, "id = ? AND attempts >= ?", id, loginChallengeAttempts)
+	return deletion.RowsAffected == 0
+}
+
+// DeleteLoginChallenge drops a pending login.
+// It returns false if the challenge has already been used.
+func (data *DataSource) DeleteLoginChallenge(id string) bool {
+	deletion := data.db.Delete(&LoginChallenge{}, "id = ?", id)
+	return deletion.Error == nil && deletion.RowsAffected == 1
+}
@@ -0,0 +1,290 @@
+// Package oidc implements the authorization code flow of OpenID Connect against a single provider.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/lnsp/microlog/common/keyring"
+	"github.com/pkg/errors"
+)
+
+const (
+	// discoveryPath is appended to the issuer to retrieve the provider metadata.
+	discoveryPath = "/.well-known/openid-configuration"
+	// keysRefreshDelay is the minimum time between two downloads of the provider keys.
+	keysRefreshDelay = time.Minute
+	// clockSkew is the tolerated difference between the clocks of provider and gateway.
+	clockSkew = time.Minute
+)
+
+// ErrInvalidToken is returned if the ID token of the provider can not be trusted.
+var ErrInvalidToken = errors.New("invalid id token")
+
+// Config describes the provider and how the gateway is registered with it.
+type Config struct {
+	// Issuer is the URL identifying the provider, its metadata is discovered from it.
+	Issuer string
+	// ClientID and ClientSecret are the credentials of the gateway.
+	ClientID, ClientSecret string
+	// RedirectURL is the callback URL registered with the provider.
+	RedirectURL string
+	// Scopes are requested in addition to openid.
+	Scopes []string
+}
+
+// audience is the aud claim, which is either a single string or an array of strings.
+type audience []string
+
+// UnmarshalJSON accepts both forms of the aud claim.
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// contains checks if the audience includes the given client.
+func (a audience) contains(client string) bool {
+	for _, aud := range a {
+		if aud == client {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the claims of an ID token used to identify the user.
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	Name              string   `json:"name"`
+}
+
+// Valid is required by jwt.Claims, the claims are checked once the signature has been verified.
+func (c *Claims) Valid() error {
+	return nil
+}
+
+// metadata is the subset of the provider metadata needed for the authorization code flow.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// tokenResponse is the response of the token endpoint.
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Provider talks to an OpenID Connect provider.
+// The provider metadata is discovered on first use, so that the gateway starts while the provider is unavailable.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+	keys   *keyring.Keyring
+
+	mu          sync.Mutex
+	meta        *metadata
+	keysFetched time.Time
+}
+
+// New creates a provider using the given configuration.
+func New(cfg Config) (*Provider, error) {
+	keys, err := keyring.New(keyring.Config{VerifyOnly: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create keyring")
+	}
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   keys,
+	}, nil
+}
+
+// Issuer returns the issuer of the provider, which together with the subject identifies a user.
+func (p *Provider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// metadata returns the provider metadata, discovering it if necessary.
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var meta metadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+discoveryPath, &meta); err != nil {
+		return nil, errors.Wrap(err, "failed to discover provider")
+	}
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, errors.Errorf("provider claims to be issuer %q", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("provider metadata is incomplete")
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// refreshKeys downloads the signing keys of the provider, unless they have been downloaded just now.
+func (p *Provider) refreshKeys(ctx context.Context, meta *metadata) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.keysFetched) < keysRefreshDelay {
+		return nil
+	}
+	var jwks keyring.JSONWebKeySet
+	if err := p.getJSON(ctx, meta.JWKSURI, &jwks); err != nil {
+		return errors.Wrap(err, "failed to fetch provider keys")
+	}
+	// Providers commonly omit the optional alg and use parameters of their keys
+	for i := range jwks.Keys {
+		if jwks.Keys[i].Algorithm == "" {
+			jwks.Keys[i].Algorithm = jwt.SigningMethodRS256.Alg()
+		}
+		if jwks.Keys[i].Use == "" {
+			jwks.Keys[i].Use = "sig"
+		}
+	}
+	if err := p.keys.SetPublicKeys(jwks.Keys); err != nil {
+		return errors.Wrap(err, "failed to load provider keys")
+	}
+	p.keysFetched = time.Now()
+	return nil
+}
+
+// AuthURL returns the URL the user is sent to for signing in at the provider.
+// The state is passed back to the callback, the nonce is embedded in the ID token.
+func (p *Provider) AuthURL(ctx context.Context, state, nonce string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.cfg.ClientID},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"scope":         {strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return meta.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code and verifies the returned ID token.
+// It returns the claims of the token, if it has been issued for the given nonce.
+func (p *Provider) Exchange(ctx context.Context, code, nonce string) (*Claims, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.cfg.RedirectURL},
+	}
+	req, err := http.NewRequest(http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request token")
+	}
+	defer resp.Body.Close()
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, errors.Wrap(err, "failed to decode token response")
+	}
+	if token.Error != "" {
+		return nil, errors.Errorf("provider rejected code: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || token.IDToken == "" {
+		return nil, errors.Errorf("provider returned no id token, status %d", resp.StatusCode)
+	}
+	return p.verify(ctx, meta, token.IDToken, nonce)
+}
+
+// verify checks the signature and claims of the ID token.
+func (p *Provider) verify(ctx context.Context, meta *metadata, idToken, nonce string) (*Claims, error) {
+	var claims Claims
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Alg()}, SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(idToken, &claims, p.keys.Keyfunc)
+	if validation, ok := err.(*jwt.ValidationError); ok && errors.Cause(validation.Inner) == keyring.ErrUnknownKey {
+		// The provider may have rotated its keys since they have been fetched
+		if err := p.refreshKeys(ctx, meta); err != nil {
+			return nil, err
+		}
+		_, err = parser.ParseWithClaims(idToken, &claims, p.keys.Keyfunc)
+	}
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, err.Error())
+	}
+	now := time.Now()
+	switch {
+	case claims.Issuer != meta.Issuer:
+		return nil, errors.Wrap(ErrInvalidToken, "issuer does not match")
+	case !claims.Audience.contains(p.cfg.ClientID):
+		return nil, errors.Wrap(ErrInvalidToken, "audience does not match")
+	case claims.Subject == "":
+		return nil, errors.Wrap(ErrInvalidToken, "subject is missing")
+	case claims.Nonce == "" || claims.Nonce != nonce:
+		return nil, errors.Wrap(ErrInvalidToken, "nonce does not match")
+	case now.Add(-clockSkew).Unix() > claims.ExpiresAt:
+		return nil, errors.Wrap(ErrInvalidToken, "token has expired")
+	case claims.IssuedAt > now.Add(clockSkew).Unix():
+		return nil, errors.Wrap(ErrInvalidToken, "token has been issued in the future")
+	}
+	return &claims, nil
+}
+
+// getJSON retrieves and decodes the JSON document at the given URL.
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
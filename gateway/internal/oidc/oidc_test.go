@@ -0,0 +1,313 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/lnsp/microlog/common/keyring"
+)
+
+const (
+	testClientID     = "microlog"
+	testClientSecret = "secret"
+	testCode         = "code"
+	testNonce        = "nonce"
+)
+
+// mockProvider is a minimal OpenID Connect provider issuing ID tokens signed by generated RSA keys.
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu sync.Mutex
+	// published are the keys listed in the JWKS, signing is the key ID tokens are signed with.
+	published map[string]*rsa.PrivateKey
+	signing   string
+	// claims are embedded in the next ID token, tokenError makes the token endpoint fail instead.
+	claims       jwt.MapClaims
+	tokenError   string
+	jwksRequests int
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	mock := &mockProvider{
+		t:         t,
+		published: make(map[string]*rsa.PrivateKey),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, mock.discovery)
+	mux.HandleFunc("/jwks", mock.jwks)
+	mux.HandleFunc("/token", mock.token)
+	mock.server = httptest.NewServer(mux)
+	mock.addKey("first")
+	mock.claims = mock.validClaims()
+	return mock
+}
+
+func (mock *mockProvider) close() {
+	mock.server.Close()
+}
+
+// addKey generates a key, publishes it and signs further tokens with it.
+func (mock *mockProvider) addKey(id string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		mock.t.Fatal(err)
+	}
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.published[id] = key
+	mock.signing = id
+	return key
+}
+
+// validClaims returns the claims of a token the gateway accepts.
+func (mock *mockProvider) validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":                mock.server.URL,
+		"sub":                "alice-subject",
+		"aud":                testClientID,
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              testNonce,
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+	}
+}
+
+func (mock *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 mock.server.URL,
+		"authorization_endpoint": mock.server.URL + "/authorize",
+		"token_endpoint":         mock.server.URL + "/token",
+		"jwks_uri":               mock.server.URL + "/jwks",
+	})
+}
+
+func (mock *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.jwksRequests++
+	// alg and use are optional and left out, like many providers do
+	var set keyring.JSONWebKeySet
+	for id, key := range mock.published {
+		set.Keys = append(set.Keys, keyring.JSONWebKey{
+			KeyID:   id,
+			KeyType: "RSA",
+			N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+func (mock *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if mock.tokenError != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": mock.tokenError, "error_description": "denied by test"})
+		return
+	}
+	client, secret, ok := r.BasicAuth()
+	if !ok || client != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code") != testCode {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mock.claims)
+	token.Header["kid"] = mock.signing
+	key, ok := mock.published[mock.signing]
+	if !ok {
+		// Tokens signed with an unpublished key are signed by a throwaway key
+		var err error
+		if key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			mock.t.Fatal(err)
+		}
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		mock.t.Fatal(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+}
+
+// provider creates a provider registered with the mock.
+func (mock *mockProvider) provider() *Provider {
+	provider, err := New(Config{
+		Issuer:       mock.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  "http://localhost:8080/auth/external/callback",
+		Scopes:       []string{"email", "profile"},
+	})
+	if err != nil {
+		mock.t.Fatal(err)
+	}
+	return provider
+}
+
+func TestAuthURL(t *testing.T) {
+	mock := newMockProvider(t)
+	defer mock.close()
+	authURL, err := mock.provider().AuthURL(context.Background(), "state", testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, mock.server.URL+"/authorize?") {
+		t.Errorf("auth url %q does not point to the authorization endpoint", authURL)
+	}
+	query := parsed.Query()
+	expected := map[string]string{
+		"response_type": "code",
+		"client_id":     testClientID,
+		"scope":         "openid email profile",
+		"state":         "state",
+		"nonce":         testNonce,
+	}
+	for param, value := range expected {
+		if query.Get(param) != value {
+			t.Errorf("expected %s to be %q, got %q", param, value, query.Get(param))
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+		nonce  string
+		valid  bool
+	}{
+		{"valid", func(jwt.MapClaims) {}, testNonce, true},
+		{"audience list", func(c jwt.MapClaims) { c["aud"] = []string{"other", testClientID} }, testNonce, true},
+		{"wrong nonce", func(jwt.MapClaims) {}, "other-nonce", false},
+		{"missing nonce", func(c jwt.MapClaims) { delete(c, "nonce") }, "", false},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other" }, testNonce, false},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, testNonce, false},
+		{"missing subject", func(c jwt.MapClaims) { delete(c, "sub") }, testNonce, false},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-clockSkew - time.Minute).Unix() }, testNonce, false},
+		{"issued in the future", func(c jwt.MapClaims) { c["iat"] = time.Now().Add(clockSkew + time.Minute).Unix() }, testNonce, false},
+	}
+	mock := newMockProvider(t)
+	defer mock.close()
+	provider := mock.provider()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := mock.validClaims()
+			test.modify(claims)
+			mock.mu.Lock()
+			mock.claims = claims
+			mock.mu.Unlock()
+			result, err := provider.Exchange(context.Background(), testCode, test.nonce)
+			if !test.valid {
+				if err == nil {
+					t.Fatal("expected token to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Subject != "alice-subject" || result.Email != "alice@example.com" || !result.EmailVerified || result.PreferredUsername != "alice" {
+				t.Errorf("unexpected claims %+v", result)
+			}
+		})
+	}
+}
+
+func TestExchangeRefreshesKeys(t *testing.T) {
+	mock := newMockProvider(t)
+	defer mock.close()
+	provider := mock.provider()
+	if _, err := provider.Exchange(context.Background(), testCode, testNonce); err != nil {
+		t.Fatal(err)
+	}
+	// The provider rotates its key, the gateway has to download the new one
+	mock.addKey("second")
+	provider.mu.Lock()
+	provider.keysFetched = time.Now().Add(-keysRefreshDelay)
+	provider.mu.Unlock()
+	if _, err := provider.Exchange(context.Background(), testCode, testNonce); err != nil {
+		t.Fatal(err)
+	}
+	if mock.jwksRequests != 2 {
+		t.Errorf("expected keys to be fetched twice, got %d", mock.jwksRequests)
+	}
+	// Unknown keys right after a refresh do not cause another download
+	mock.mu.Lock()
+	mock.signing = "unpublished"
+	mock.mu.Unlock()
+	if _, err := provider.Exchange(context.Background(), testCode, testNonce); err == nil {
+		t.Fatal("expected token signed by an unknown key to be rejected")
+	}
+	if mock.jwksRequests != 2 {
+		t.Errorf("expected keys not to be fetched again, got %d downloads", mock.jwksRequests)
+	}
+}
+
+func TestExchangeRejectsForgedSignature(t *testing.T) {
+	mock := newMockProvider(t)
+	defer mock.close()
+	provider := mock.provider()
+	// A token claiming a published key ID but signed by another key
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, mock.validClaims())
+	forged.Header["kid"] = "first"
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := forged.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := provider.metadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.verify(context.Background(), meta, signed, testNonce); err == nil {
+		t.Fatal("expected forged token to be rejected")
+	}
+}
+
+func TestExchangeProviderError(t *testing.T) {
+	mock := newMockProvider(t)
+	defer mock.close()
+	mock.tokenError = "access_denied"
+	_, err := mock.provider().Exchange(context.Background(), testCode, testNonce)
+	if err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+}
+
+func TestExchangeInvalidCode(t *testing.T) {
+	mock := newMockProvider(t)
+	defer mock.close()
+	if _, err := mock.provider().Exchange(context.Background(), "wrong-code", testNonce); err == nil {
+		t.Fatal("expected invalid code to be rejected")
+	}
+}
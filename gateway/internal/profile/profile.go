@@ -0,0 +1,238 @@
+// Package profile provides a client for the profile service.
+package profile
+
+import (
+	"context"
+
+	"github.com/lnsp/microlog/common/logger"
+	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/lnsp/microlog/gateway/internal/rpc"
+	"github.com/lnsp/microlog/profile/api"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var log = logger.New()
+
+// migrateBatchSize is the number of users fetched at once during migration.
+const migrateBatchSize = 100
+
+var (
+	// ErrNotFound is returned if the user has no profile.
+	ErrNotFound = errors.New("profile not found")
+	// ErrInvalid is returned if the profile service rejected the given values.
+	ErrInvalid = errors.New("invalid profile values")
+)
+
+// Profile stores the public profile information of a user.
+type Profile struct {
+	ID           uint
+	DisplayName  string
+	Biography    string
+	ImageURL     string
+	ThumbnailURL string
+}
+
+type Client struct {
+	data       *models.DataSource
+	client     api.ProfileClient
+	thumbnails *thumbnailCache
+}
+
+// NewClient creates a profile service client using the given connection.
+func NewClient(dataSource *models.DataSource, conn *grpc.ClientConn) *Client {
+	return &Client{
+		data:       dataSource,
+		client:     api.NewProfileClient(conn),
+		thumbnails: &thumbnailCache{entries: make(map[uint]thumbnailEntry)},
+	}
+}
+
+// translateError maps well-known GRPC status codes to package errors.
+func translateError(err error, msg string) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return ErrNotFound
+	case codes.InvalidArgument:
+		return ErrInvalid
+	default:
+		return errors.Wrap(err, msg)
+	}
+}
+
+func fromResponse(resp *api.ProfileResponse) *Profile {
+	return &Profile{
+		ID:           uint(resp.Id),
+		DisplayName:  resp.DisplayName,
+		Biography:    resp.Biography,
+		ImageURL:     resp.ImageURL,
+		ThumbnailURL: resp.ThumbnailURL,
+	}
+}
+
+// Get retrieves the profile of the given user.
+// It returns ErrNotFound if the user has no profile yet, profiles are only created when migrating or changing them.
+func (profile *Client) Get(ctx context.Context, userID uint) (*Profile, error) {
+	resp, err := profile.client.Get(ctx, &api.ProfileGetRequest{
+		Id: uint32(userID),
+	}, rpc.Idempotent)
+	if err != nil {
+		return nil, translateError(err, "failed to get profile")
+	}
+	profile.thumbnails.put(userID, resp.ThumbnailURL)
+	return fromResponse(resp), nil
+}
+
+// migrate creates a new profile for the given user and copies over the legacy biography.
+// Existing profiles are left untouched, so that changes made in the meantime are kept.
+func (profile *Client) migrate(ctx context.Context, userID uint) error {
+	user, err := profile.data.User(userID)
+	if err != nil {
+		return errors.Wrap(err, "failed to find user")
+	}
+	_, err = profile.client.Create(ctx, &api.ProfileCreateRequest{
+		Id: uint32(userID),
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	} else if err != nil {
+		return translateError(err, "failed to create profile")
+	}
+	if user.Biography != "" {
+		if _, err := profile.client.UpdateBiography(ctx, &api.ProfileUpdateRequest{
+			Id:        uint32(userID),
+			Biography: user.Biography,
+		}, rpc.Idempotent); err != nil {
+			return translateError(err, "failed to migrate biography")
+		}
+	}
+	log.WithFields(logrus.Fields{
+		"id": userID,
+	}).Info("migrated user profile")
+	return nil
+}
+
+// update applies the change to the profile of the given user, migrating the profile first if the user has none yet.
+func (profile *Client) update(ctx context.Context, userID uint, change func() (*api.ProfileResponse, error)) (*api.ProfileResponse, error) {
+	resp, err := change()
+	if status.Code(err) != codes.NotFound {
+		return resp, err
+	}
+	if err := profile.migrate(ctx, userID); err != nil {
+		return nil, err
+	}
+	return change()
+}
+
+// MigrateAll creates profiles for all users that do not have one yet and copies the biographies
+// from the profile service into the gateway database, where they are used for search.
+// It returns the number of users whose biography has been copied.
+func (profile *Client) MigrateAll(ctx context.Context) (int, error) {
+	migrated := 0
+	var before uint
+	for {
+		users, err := profile.data.RecentUsersBefore(before, migrateBatchSize)
+		if err != nil {
+			return migrated, errors.Wrap(err, "failed to find users")
+		}
+		if len(users) == 0 {
+			return migrated, nil
+		}
+		for _, user := range users {
+			details, err := profile.Get(ctx, user.ID)
+			if err == ErrNotFound {
+				if err := profile.migrate(ctx, user.ID); err != nil {
+					return migrated, errors.Wrapf(err, "failed to migrate user %d", user.ID)
+				}
+				details, err = profile.Get(ctx, user.ID)
+			}
+			if err != nil {
+				return migrated, errors.Wrapf(err, "failed to migrate user %d", user.ID)
+			}
+			if details.Biography == user.Biography {
+				continue
+			}
+			if err := profile.data.SetBiography(user.ID, details.Biography); err != nil {
+				return migrated, errors.Wrapf(err, "failed to copy biography of user %d", user.ID)
+			}
+			migrated++
+		}
+		before = users[len(users)-1].ID
+	}
+}
+
+// Create creates a new empty profile for the given user.
+func (profile *Client) Create(ctx context.Context, userID uint, displayName string) error {
+	_, err := profile.client.Create(ctx, &api.ProfileCreateRequest{
+		Id:          uint32(userID),
+		DisplayName: displayName,
+	})
+	if err != nil {
+		return translateError(err, "failed to create profile")
+	}
+	return nil
+}
+
+// Delete deletes the profile of the given user.
+func (profile *Client) Delete(ctx context.Context, userID uint) error {
+	_, err := profile.client.Delete(ctx, &api.ProfileDeleteRequest{
+		Id: uint32(userID),
+	}, rpc.Idempotent)
+	profile.thumbnails.forget(userID)
+	if err != nil {
+		return translateError(err, "failed to delete profile")
+	}
+	return nil
+}
+
+// UpdateBiography changes the biography of the given user.
+func (profile *Client) UpdateBiography(ctx context.Context, userID uint, biography string) (*Profile, error) {
+	resp, err := profile.update(ctx, userID, func() (*api.ProfileResponse, error) {
+		return profile.client.UpdateBiography(ctx, &api.ProfileUpdateRequest{
+			Id:        uint32(userID),
+			Biography: biography,
+		}, rpc.Idempotent)
+	})
+	if err != nil {
+		return nil, translateError(err, "failed to update biography")
+	}
+	if err := profile.data.SetBiography(userID, resp.Biography); err != nil {
+		log.WithFields(logrus.Fields{
+			"id": userID,
+		}).WithError(err).Error("failed to store biography for search")
+	}
+	return fromResponse(resp), nil
+}
+
+// UpdateDisplayName changes the display name of the given user.
+func (profile *Client) UpdateDisplayName(ctx context.Context, userID uint, displayName string) (*Profile, error) {
+	resp, err := profile.update(ctx, userID, func() (*api.ProfileResponse, error) {
+		return profile.client.UpdateDisplayName(ctx, &api.ProfileUpdateRequest{
+			Id:          uint32(userID),
+			DisplayName: displayName,
+		}, rpc.Idempotent)
+	})
+	if err != nil {
+		return nil, translateError(err, "failed to update display name")
+	}
+	return fromResponse(resp), nil
+}
+
+// UpdateImage uploads a new profile image for the given user.
+// It returns ErrInvalid if the image has an unsupported format or is too large.
+func (profile *Client) UpdateImage(ctx context.Context, userID uint, image []byte) (*Profile, error) {
+	resp, err := profile.update(ctx, userID, func() (*api.ProfileResponse, error) {
+		return profile.client.UpdateImage(ctx, &api.ProfileUpdateRequest{
+			Id:    uint32(userID),
+			Image: image,
+		})
+	})
+	if err != nil {
+		return nil, translateError(err, "failed to update image")
+	}
+	profile.thumbnails.put(userID, resp.ThumbnailURL)
+	return fromResponse(resp), nil
+}
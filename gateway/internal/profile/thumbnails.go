@@ -0,0 +1,122 @@
+package profile
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// thumbnailTTL is the time the thumbnail URL of a profile is cached for.
+	thumbnailTTL = time.Minute
+	// thumbnailPruneSize is the number of cached thumbnails above which expired entries are pruned.
+	thumbnailPruneSize = 10000
+	// thumbnailFetches limits the number of profiles fetched concurrently.
+	thumbnailFetches = 8
+)
+
+// thumbnailEntry stores the thumbnail URL of a profile, which is empty if the user has no image.
+type thumbnailEntry struct {
+	url   string
+	until time.Time
+}
+
+// thumbnailCache remembers the thumbnail URLs of profiles for a short time,
+// so that listings showing the same users again and again do not ask the profile service for each of them.
+type thumbnailCache struct {
+	mu      sync.Mutex
+	entries map[uint]thumbnailEntry
+}
+
+// get returns the cached thumbnail URL of the user.
+// The second return value is false if the URL is unknown or outdated.
+func (c *thumbnailCache) get(userID uint) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[userID]
+	if !ok || time.Now().After(entry.until) {
+		return "", false
+	}
+	return entry.url, true
+}
+
+// put stores the thumbnail URL of the user.
+func (c *thumbnailCache) put(userID uint, url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= thumbnailPruneSize {
+		for key, entry := range c.entries {
+			if now.After(entry.until) {
+				delete(c.entries, key)
+			}
+		}
+	}
+	c.entries[userID] = thumbnailEntry{url: url, until: now.Add(thumbnailTTL)}
+}
+
+// forget drops the cached thumbnail URL of the user.
+func (c *thumbnailCache) forget(userID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+}
+
+// Thumbnails returns the thumbnail URLs of the profile images of the given users, indexed by their ID.
+// Users without an image or whose profile is unavailable are missing from the result.
+// Profiles that are not cached are fetched concurrently.
+func (profile *Client) Thumbnails(ctx context.Context, userIDs []uint) map[uint]string {
+	var (
+		result  = make(map[uint]string)
+		missing []uint
+		seen    = make(map[uint]bool)
+	)
+	for _, id := range userIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if url, ok := profile.thumbnails.get(id); ok {
+			if url != "" {
+				result[id] = url
+			}
+			continue
+		}
+		missing = append(missing, id)
+	}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		fetches = make(chan struct{}, thumbnailFetches)
+	)
+	for _, id := range missing {
+		wg.Add(1)
+		fetches <- struct{}{}
+		go func(id uint) {
+			defer func() {
+				<-fetches
+				wg.Done()
+			}()
+			// Successful lookups are cached by Get
+			details, err := profile.Get(ctx, id)
+			if err == ErrNotFound {
+				profile.thumbnails.put(id, "")
+				return
+			} else if err != nil {
+				log.WithFields(logrus.Fields{
+					"id": id,
+				}).WithError(err).Debug("failed to get profile")
+				return
+			}
+			if details.ThumbnailURL != "" {
+				mu.Lock()
+				result[id] = details.ThumbnailURL
+				mu.Unlock()
+			}
+		}(id)
+	}
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,56 @@
+package profile
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/lnsp/microlog/profile/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeProfiles serves profiles with a thumbnail for even user IDs, odd user IDs have no profile.
+type fakeProfiles struct {
+	api.ProfileClient
+
+	mu    sync.Mutex
+	calls map[uint32]int
+}
+
+func (fake *fakeProfiles) Get(ctx context.Context, req *api.ProfileGetRequest, opts ...grpc.CallOption) (*api.ProfileResponse, error) {
+	fake.mu.Lock()
+	fake.calls[req.Id]++
+	fake.mu.Unlock()
+	if req.Id%2 == 1 {
+		return nil, status.Error(codes.NotFound, "profile not found")
+	}
+	return &api.ProfileResponse{Id: req.Id, ThumbnailURL: "/thumbnail"}, nil
+}
+
+func TestThumbnails(t *testing.T) {
+	fake := &fakeProfiles{calls: make(map[uint32]int)}
+	client := &Client{
+		client:     fake,
+		thumbnails: &thumbnailCache{entries: make(map[uint]thumbnailEntry)},
+	}
+	ids := []uint{2, 3, 2, 4, 3}
+	for i := 0; i < 2; i++ {
+		thumbnails := client.Thumbnails(context.Background(), ids)
+		if len(thumbnails) != 2 || thumbnails[2] != "/thumbnail" || thumbnails[4] != "/thumbnail" {
+			t.Fatalf("unexpected thumbnails %v", thumbnails)
+		}
+	}
+	// Every profile is fetched once, missing profiles are cached too
+	for _, id := range []uint32{2, 3, 4} {
+		if fake.calls[id] != 1 {
+			t.Errorf("expected profile %d to be fetched once, got %d", id, fake.calls[id])
+		}
+	}
+	client.thumbnails.forget(2)
+	client.Thumbnails(context.Background(), ids)
+	if fake.calls[2] != 2 {
+		t.Errorf("expected forgotten profile to be fetched again, got %d", fake.calls[2])
+	}
+}
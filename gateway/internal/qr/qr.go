@@ -0,0 +1,45 @@
+// Package qr encodes short texts as QR codes and renders them as SVG images.
+// Encoding is done by github.com/skip2/go-qrcode, this package only renders the modules.
+package qr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// quietZone is the width of the light border around the code in modules.
+const quietZone = 4
+
+// Code is an encoded QR code.
+type Code struct {
+	modules [][]bool
+}
+
+// Encode encodes the text at error correction level M using the smallest version it fits into.
+func Encode(text string) (*Code, error) {
+	code, err := qrcode.New(text, qrcode.Medium)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode qr code")
+	}
+	code.DisableBorder = true
+	return &Code{modules: code.Bitmap()}, nil
+}
+
+// SVG renders the code as SVG image with a quiet zone around it.
+// The image scales with its container.
+func (code *Code) SVG() string {
+	var path strings.Builder
+	for y, row := range code.modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+	size := len(code.modules) + 2*quietZone
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path d="%s" fill="#000"/></svg>`, size, size, path.String())
+}
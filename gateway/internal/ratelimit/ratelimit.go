@@ -0,0 +1,200 @@
+// Package ratelimit throttles repeated attempts using fixed windows and progressive lockouts.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// strikeMemory is the time lockouts are remembered for, each further lockout within it lasts twice as long.
+	strikeMemory = 24 * time.Hour
+	// memoryPruneSize is the number of keys above which the memory store drops expired keys.
+	memoryPruneSize = 10000
+)
+
+// Store keeps expiring counters.
+type Store interface {
+	// Incr increments the counter stored at the key and returns its new value.
+	// A new counter expires after the given time.
+	Incr(key string, expiry time.Duration) (int64, error)
+	// Set stores the key for the given time.
+	Set(key string, expiry time.Duration) error
+	// TTL returns the time until the key expires, or zero if it does not exist.
+	TTL(key string) (time.Duration, error)
+	// Delete removes the key.
+	Delete(key string) error
+}
+
+// Rule limits the number of attempts within a window.
+// A rule with a limit of zero allows all attempts.
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Limiter locks keys out once they exceed the limit of their rule.
+type Limiter struct {
+	store      Store
+	lockout    time.Duration
+	maxLockout time.Duration
+}
+
+// New creates a limiter using the given store.
+// The first lockout of a key lasts for the given time and doubles with each further lockout up to the maximum.
+func New(store Store, lockout, maxLockout time.Duration) *Limiter {
+	return &Limiter{
+		store:      store,
+		lockout:    lockout,
+		maxLockout: maxLockout,
+	}
+}
+
+// Attempt records an attempt of the key.
+// It returns the remaining lockout, which is zero if the attempt is allowed.
+// Attempts of a locked out key are not counted, so waiting for the lockout always suffices.
+func (l *Limiter) Attempt(key string, rule Rule) (time.Duration, error) {
+	if rule.Limit <= 0 {
+		return 0, nil
+	}
+	locked, err := l.store.TTL(lockKey(key))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to check lockout")
+	}
+	if locked > 0 {
+		return locked, nil
+	}
+	count, err := l.store.Incr(countKey(key), rule.Window)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count attempt")
+	}
+	if count <= int64(rule.Limit) {
+		return 0, nil
+	}
+	strikes, err := l.store.Incr(strikesKey(key), strikeMemory)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count lockout")
+	}
+	lockout := l.lockout
+	for i := int64(1); i < strikes && lockout < l.maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > l.maxLockout {
+		lockout = l.maxLockout
+	}
+	if err := l.store.Set(lockKey(key), lockout); err != nil {
+		return 0, errors.Wrap(err, "failed to lock out")
+	}
+	if err := l.store.Delete(countKey(key)); err != nil {
+		return 0, errors.Wrap(err, "failed to reset attempts")
+	}
+	return lockout, nil
+}
+
+// Reset forgets the attempts of the key, for example after a successful login.
+// Running lockouts and the lockout history are kept.
+func (l *Limiter) Reset(key string) error {
+	if err := l.store.Delete(countKey(key)); err != nil {
+		return errors.Wrap(err, "failed to reset attempts")
+	}
+	return nil
+}
+
+func countKey(key string) string {
+	return "ratelimit:" + key + ":count"
+}
+
+func lockKey(key string) string {
+	return "ratelimit:" + key + ":lock"
+}
+
+func strikesKey(key string) string {
+	return "ratelimit:" + key + ":strikes"
+}
+
+// memoryEntry is an expiring counter.
+type memoryEntry struct {
+	value   int64
+	expires time.Time
+}
+
+// MemoryStore keeps counters in memory.
+// It is only suitable for a single gateway, since replicas do not share their counters.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+// NewMemoryStore creates an empty memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+// get returns the entry of the key if it has not expired yet.
+func (m *MemoryStore) get(key string, now time.Time) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+// Incr increments the counter stored at the key.
+func (m *MemoryStore) Incr(key string, expiry time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	entry, ok := m.get(key, now)
+	if !ok {
+		if len(m.entries) >= memoryPruneSize {
+			m.prune(now)
+		}
+		entry.expires = now.Add(expiry)
+	}
+	entry.value++
+	m.entries[key] = entry
+	return entry.value, nil
+}
+
+// Set stores the key for the given time.
+func (m *MemoryStore) Set(key string, expiry time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{value: 1, expires: m.now().Add(expiry)}
+	return nil
+}
+
+// TTL returns the time until the key expires.
+func (m *MemoryStore) TTL(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	entry, ok := m.get(key, now)
+	if !ok {
+		return 0, nil
+	}
+	return entry.expires.Sub(now), nil
+}
+
+// Delete removes the key.
+func (m *MemoryStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// prune drops all expired keys.
+func (m *MemoryStore) prune(now time.Time) {
+	for key, entry := range m.entries {
+		if !now.Before(entry.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
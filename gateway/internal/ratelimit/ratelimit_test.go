@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// clock is a manually advanced time source for memory stores.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestStore() (*MemoryStore, *clock) {
+	c := &clock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = c.Now
+	return store, c
+}
+
+func TestAttemptLockouts(t *testing.T) {
+	rule := Rule{Limit: 3, Window: time.Minute}
+	tests := []struct {
+		name     string
+		lockouts int
+		want     time.Duration
+	}{
+		{"first lockout", 1, time.Minute},
+		{"second lockout doubles", 2, 2 * time.Minute},
+		{"third lockout doubles again", 3, 4 * time.Minute},
+		{"capped at maximum", 5, 10 * time.Minute},
+		{"stays at maximum", 8, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, c := newTestStore()
+			limiter := New(store, time.Minute, 10*time.Minute)
+			var lockout time.Duration
+			for i := 0; i < tt.lockouts; i++ {
+				for j := 0; j < rule.Limit; j++ {
+					if wait, err := limiter.Attempt("key", rule); err != nil || wait != 0 {
+						t.Fatalf("expected attempt %d to be allowed, got %v (%v)", j+1, wait, err)
+					}
+				}
+				var err error
+				if lockout, err = limiter.Attempt("key", rule); err != nil {
+					t.Fatal(err)
+				}
+				c.Advance(lockout)
+			}
+			if lockout != tt.want {
+				t.Errorf("expected lockout %v, got %v", tt.want, lockout)
+			}
+		})
+	}
+}
+
+func TestAttemptWhileLockedOut(t *testing.T) {
+	store, c := newTestStore()
+	limiter := New(store, time.Minute, time.Hour)
+	rule := Rule{Limit: 1, Window: time.Hour}
+	limiter.Attempt("key", rule)
+	if wait, _ := limiter.Attempt("key", rule); wait != time.Minute {
+		t.Fatalf("expected lockout of a minute, got %v", wait)
+	}
+	c.Advance(20 * time.Second)
+	// Attempts during a lockout report the remaining time and are not counted
+	for i := 0; i < 5; i++ {
+		if wait, _ := limiter.Attempt("key", rule); wait != 40*time.Second {
+			t.Fatalf("expected remaining lockout of 40s, got %v", wait)
+		}
+	}
+	c.Advance(40 * time.Second)
+	if wait, _ := limiter.Attempt("key", rule); wait != 0 {
+		t.Errorf("expected attempt after lockout to be allowed, got %v", wait)
+	}
+}
+
+func TestAttemptStrikeMemory(t *testing.T) {
+	tests := []struct {
+		name  string
+		pause time.Duration
+		want  time.Duration
+	}{
+		{"remembered", strikeMemory - time.Hour, 2 * time.Minute},
+		{"forgotten", strikeMemory, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, c := newTestStore()
+			limiter := New(store, time.Minute, time.Hour)
+			rule := Rule{Limit: 1, Window: time.Minute}
+			limiter.Attempt("key", rule)
+			limiter.Attempt("key", rule)
+			c.Advance(tt.pause)
+			limiter.Attempt("key", rule)
+			if wait, _ := limiter.Attempt("key", rule); wait != tt.want {
+				t.Errorf("expected lockout %v, got %v", tt.want, wait)
+			}
+		})
+	}
+}
+
+func TestAttemptWindowAndReset(t *testing.T) {
+	store, c := newTestStore()
+	limiter := New(store, time.Minute, time.Hour)
+	rule := Rule{Limit: 2, Window: time.Minute}
+	limiter.Attempt("key", rule)
+	limiter.Attempt("key", rule)
+	c.Advance(time.Minute)
+	// The window has passed, so the attempts start over
+	limiter.Attempt("key", rule)
+	if err := limiter.Reset("key"); err != nil {
+		t.Fatal(err)
+	}
+	limiter.Attempt("key", rule)
+	if wait, _ := limiter.Attempt("key", rule); wait != 0 {
+		t.Errorf("expected attempts to be allowed after reset, got %v", wait)
+	}
+	if wait, _ := limiter.Attempt("other", Rule{}); wait != 0 {
+		t.Errorf("expected rule without limit to allow attempts, got %v", wait)
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	store, c := newTestStore()
+	for i := 0; i < memoryPruneSize-1; i++ {
+		store.Incr("old:"+strconv.Itoa(i), time.Minute)
+	}
+	store.Incr("fresh", time.Hour)
+	c.Advance(time.Minute)
+	// The store is full, so adding a key drops the expired ones
+	store.Incr("new", time.Minute)
+	if len(store.entries) != 2 {
+		t.Fatalf("expected 2 keys after pruning, got %d", len(store.entries))
+	}
+	if value, _ := store.Incr("fresh", time.Hour); value != 2 {
+		t.Errorf("expected unexpired counter to be kept, got %d", value)
+	}
+	if ttl, _ := store.TTL("old:0"); ttl != 0 {
+		t.Errorf("expected expired key to be gone, got %v", ttl)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	store, c := newTestStore()
+	store.Incr("key", time.Minute)
+	c.Advance(30 * time.Second)
+	if value, _ := store.Incr("key", time.Minute); value != 2 {
+		t.Errorf("expected counter to keep counting, got %d", value)
+	}
+	if ttl, _ := store.TTL("key"); ttl != 30*time.Second {
+		t.Errorf("expected counter to keep its expiry, got %v", ttl)
+	}
+	c.Advance(30 * time.Second)
+	if value, _ := store.Incr("key", time.Minute); value != 1 {
+		t.Errorf("expected expired counter to restart, got %d", value)
+	}
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// incrScript increments a counter and sets its expiry when it is created.
+// Running it as a script prevents counters without expiry if the gateway fails in between.
+var incrScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// RedisStore keeps counters in redis, so that they are shared by all gateway replicas.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a store using the redis server at the given address.
+func NewRedisStore(addr, password string) *RedisStore {
+	return &RedisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+		}),
+	}
+}
+
+// Incr increments the counter stored at the key.
+func (s *RedisStore) Incr(key string, expiry time.Duration) (int64, error) {
+	return incrScript.Run(s.client, []string{key}, expiry.Nanoseconds()/int64(time.Millisecond)).Int64()
+}
+
+// Set stores the key for the given time.
+func (s *RedisStore) Set(key string, expiry time.Duration) error {
+	return s.client.Set(key, 1, expiry).Err()
+}
+
+// TTL returns the time until the key expires.
+func (s *RedisStore) TTL(key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Missing keys are reported using negative durations
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Delete removes the key.
+func (s *RedisStore) Delete(key string) error {
+	return s.client.Del(key).Err()
+}
@@ -0,0 +1,1226 @@
+package router
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lnsp/microlog/common/logger"
+	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	apiDefaultLimit = 20
+	apiMaxLimit     = 100
+)
+
+var popularIntervals = map[string]time.Duration{
+	"week":  timeWeek,
+	"month": timeMonth,
+	"year":  timeYear,
+}
+
+type apiErrorBody struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type apiList struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+type apiPost struct {
+	ID        uint       `json:"id"`
+	ParentID  uint       `json:"parent_id,omitempty"`
+	Author    string     `json:"author"`
+	Title     string     `json:"title,omitempty"`
+	Content   string     `json:"content"`
+	Tags      []string   `json:"tags,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	State     string     `json:"state"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	Likes     int        `json:"likes"`
+	Comments  int        `json:"comments"`
+	Liked     bool       `json:"liked"`
+}
+
+type apiUser struct {
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Biography   string    `json:"biography"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	MemberSince time.Time `json:"member_since"`
+	Followers   int       `json:"followers"`
+	Following   int       `json:"following"`
+}
+
+type apiRevision struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiTag struct {
+	Name  string `json:"name"`
+	Posts int    `json:"posts"`
+}
+
+type apiHighlight struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+type apiSearchPost struct {
+	apiPost
+	Snippet []apiHighlight `json:"snippet"`
+}
+
+type apiSearchUser struct {
+	apiUser
+	Snippet []apiHighlight `json:"snippet"`
+}
+
+type apiToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type apiCaller struct {
+	UserID    uint
+	Moderator bool
+}
+
+func (router *Router) registerAPI(api *mux.Router) {
+	api.HandleFunc("/auth/token", router.apiTokenCreate).Methods("POST")
+	api.HandleFunc("/auth/token", router.apiTokenDelete).Methods("DELETE")
+	api.HandleFunc("/auth/token/refresh", router.apiTokenRefresh).Methods("POST")
+	api.HandleFunc("/me", router.apiMe).Methods("GET")
+	api.HandleFunc("/me/drafts", router.apiDrafts).Methods("GET")
+	api.HandleFunc("/feed/popular", router.apiPopular).Methods("GET")
+	api.HandleFunc("/feed/members", router.apiMembers).Methods("GET")
+	api.HandleFunc("/feed/following", router.apiFollowing).Methods("GET")
+	api.HandleFunc("/tags/trending", router.apiTrendingTags).Methods("GET")
+	api.HandleFunc("/tags/{tag}/posts", router.apiTagPosts).Methods("GET")
+	api.HandleFunc("/search/posts", router.apiSearchPosts).Methods("GET")
+	api.HandleFunc("/search/users", router.apiSearchUsers).Methods("GET")
+	api.HandleFunc("/posts", router.apiPostCreate).Methods("POST")
+	api.HandleFunc("/posts/{post:[0-9]+}", router.apiPost).Methods("GET")
+	api.HandleFunc("/posts/{post:[0-9]+}", router.apiPostUpdate).Methods("PUT")
+	api.HandleFunc("/posts/{post:[0-9]+}", router.apiPostDelete).Methods("DELETE")
+	api.HandleFunc("/posts/{post:[0-9]+}/revisions", router.apiRevisions).Methods("GET")
+	api.HandleFunc("/posts/{post:[0-9]+}/comments", router.apiComments).Methods("GET")
+	api.HandleFunc("/posts/{post:[0-9]+}/comments", router.apiCommentCreate).Methods("POST")
+	api.HandleFunc("/posts/{post:[0-9]+}/like", router.apiLike).Methods("PUT")
+	api.HandleFunc("/posts/{post:[0-9]+}/like", router.apiUnlike).Methods("DELETE")
+	api.HandleFunc("/posts/{post:[0-9]+}/reports", router.apiReport).Methods("POST")
+	api.HandleFunc("/users/{user}", router.apiUser).Methods("GET")
+	api.HandleFunc("/users/{user}/posts", router.apiUserPosts).Methods("GET")
+	api.HandleFunc("/users/{user}/follow", router.apiFollow).Methods("PUT")
+	api.HandleFunc("/users/{user}/follow", router.apiUnfollow).Methods("DELETE")
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.apiError(w, http.StatusNotFound, "not_found", "The requested resource does not exist.")
+	})
+	api.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.apiError(w, http.StatusMethodNotAllowed, "method_not_allowed", "The method is not allowed on this resource.")
+	})
+}
+
+func (router *Router) apiJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		status = http.StatusInternalServerError
+		data = []byte(`{"error":{"code":"internal","message":"Unexpected internal error, please try again."}}`)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func (router *Router) apiError(w http.ResponseWriter, status int, code, message string) {
+	router.apiJSON(w, status, apiErrorBody{apiErrorDetail{code, message}})
+}
+
+func (router *Router) apiInternalError(w http.ResponseWriter) {
+	router.apiError(w, http.StatusInternalServerError, "internal", "Unexpected internal error, please try again.")
+}
+
+// apiAuthenticate verifies the bearer token of the request.
+// It returns nil if the request does not carry a valid token.
+func (router *Router) apiAuthenticate(r *http.Request) *apiCaller {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	info, err := router.Session.Verify(r.Context(), token)
+	if err != nil {
+		log.WithRequest(r).WithError(err).Debug("failed to verify bearer token")
+		return nil
+	}
+	return &apiCaller{UserID: info.UserID, Moderator: info.Moderator}
+}
+
+// apiRequireAuth verifies the bearer token of the request and writes an error response if it is missing or invalid.
+func (router *Router) apiRequireAuth(w http.ResponseWriter, r *http.Request) (*apiCaller, bool) {
+	caller := router.apiAuthenticate(r)
+	if caller == nil {
+		router.apiError(w, http.StatusUnauthorized, "unauthorized", "A valid bearer token is required.")
+		return nil, false
+	}
+	return caller, true
+}
+
+func (router *Router) apiDecode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		router.apiError(w, http.StatusBadRequest, "bad_request", "The request body must be valid JSON.")
+		return false
+	}
+	return true
+}
+
+// encodeCursor converts a position in a listing into an opaque cursor.
+func encodeCursor(position uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(position), 10)))
+}
+
+// decodeCursor converts an opaque cursor back into a listing position.
+// An empty cursor decodes to the zero position.
+func decodeCursor(cursor string) (uint, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.Wrap(err, "bad cursor encoding")
+	}
+	position, err := strconv.ParseUint(string(raw), 10, 32)
+	if err != nil {
+		return 0, errors.Wrap(err, "bad cursor value")
+	}
+	return uint(position), nil
+}
+
+// encodePostCursor converts a position in a listing of posts into an opaque cursor.
+func encodePostCursor(cursor models.PostCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor.String()))
+}
+
+// decodePostCursor converts an opaque cursor back into a position in a listing of posts.
+// An empty cursor decodes to the zero position.
+func decodePostCursor(cursor string) (models.PostCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return models.PostCursor{}, errors.Wrap(err, "bad cursor encoding")
+	}
+	position, err := models.ParsePostCursor(string(raw))
+	if err != nil {
+		return models.PostCursor{}, errors.Wrap(err, "bad cursor value")
+	}
+	return position, nil
+}
+
+// encodePopularCursor converts a position in the ranking of popular posts into an opaque cursor.
+func encodePopularCursor(cursor models.PopularCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor.String()))
+}
+
+// decodePopularCursor converts an opaque cursor back into a position in the ranking of popular posts.
+// An empty cursor decodes to the top of the ranking.
+func decodePopularCursor(cursor string) (models.PopularCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return models.PopularCursor{}, errors.Wrap(err, "bad cursor encoding")
+	}
+	position, err := models.ParsePopularCursor(string(raw))
+	if err != nil {
+		return models.PopularCursor{}, errors.Wrap(err, "bad cursor value")
+	}
+	return position, nil
+}
+
+// apiPage parses the cursor and limit query parameters and writes an error response if they are invalid.
+func (router *Router) apiPage(w http.ResponseWriter, r *http.Request) (uint, int, bool) {
+	cursor, err := decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		router.apiError(w, http.StatusBadRequest, "bad_cursor", "The cursor is invalid.")
+		return 0, 0, false
+	}
+	limit, ok := router.apiLimit(w, r)
+	return cursor, limit, ok
+}
+
+// apiPostPage is like apiPage for listings of posts ordered by the time they were published.
+func (router *Router) apiPostPage(w http.ResponseWriter, r *http.Request) (models.PostCursor, int, bool) {
+	cursor, err := decodePostCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		router.apiError(w, http.StatusBadRequest, "bad_cursor", "The cursor is invalid.")
+		return models.PostCursor{}, 0, false
+	}
+	limit, ok := router.apiLimit(w, r)
+	return cursor, limit, ok
+}
+
+// apiLimit parses the limit query parameter and writes an error response if it is invalid.
+func (router *Router) apiLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return apiDefaultLimit, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > apiMaxLimit {
+		router.apiError(w, http.StatusBadRequest, "bad_limit", "The limit must be between 1 and 100.")
+		return 0, false
+	}
+	return limit, true
+}
+
+// apiPostByVar looks up the post referenced in the request path and writes an error response if it does not exist.
+// Unpublished posts are only found by their author.
+func (router *Router) apiPostByVar(w http.ResponseWriter, r *http.Request) (*models.Post, bool) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["post"], 10, 32)
+	post, err := router.Data.Post(uint(id))
+	if err == nil && !post.Published() {
+		if caller := router.apiAuthenticate(r); caller == nil || caller.UserID != post.UserID {
+			err = errors.New("post not published")
+		}
+	}
+	if err != nil {
+		router.apiError(w, http.StatusNotFound, "not_found", "Post does not exist.")
+		return nil, false
+	}
+	return post, true
+}
+
+// apiPublishedPostByVar looks up the post referenced in the request path and writes an error response if it does not exist or is unpublished.
+func (router *Router) apiPublishedPostByVar(w http.ResponseWriter, r *http.Request) (*models.Post, bool) {
+	post, ok := router.apiPostByVar(w, r)
+	if ok && !post.Published() {
+		router.apiError(w, http.StatusUnprocessableEntity, "unpublished", "The post has not been published yet.")
+		return nil, false
+	}
+	return post, ok
+}
+
+// apiPostDetails holds the authors, counters, tags and edits of a list of posts, fetched at once.
+type apiPostDetails struct {
+	authors         map[uint]models.User
+	likes, comments map[uint]int
+	liked           map[uint]bool
+	tags            map[uint][]string
+	edits           map[uint]time.Time
+}
+
+// apiPostDetailsOf fetches the details of the given posts with one query per kind of detail.
+// The likes of the caller are only fetched if the caller is signed in.
+func (router *Router) apiPostDetailsOf(caller *apiCaller, posts []models.Post) (*apiPostDetails, error) {
+	var (
+		ids      = make([]uint, len(posts))
+		topLevel []uint
+		details  = &apiPostDetails{liked: make(map[uint]bool)}
+		err      error
+	)
+	for i := range posts {
+		ids[i] = posts[i].ID
+		if posts[i].ParentID == 0 {
+			topLevel = append(topLevel, posts[i].ID)
+		}
+	}
+	if details.authors, err = router.Data.UsersByID(postAuthors(posts)); err != nil {
+		return nil, errors.Wrap(err, "failed to find authors")
+	}
+	if details.likes, err = router.Data.LikeCounts(ids); err != nil {
+		return nil, errors.Wrap(err, "failed to count likes")
+	}
+	if details.comments, err = router.Data.CommentCounts(ids); err != nil {
+		return nil, errors.Wrap(err, "failed to count comments")
+	}
+	if caller != nil {
+		if details.liked, err = router.Data.LikedPosts(caller.UserID, ids); err != nil {
+			return nil, errors.Wrap(err, "failed to fetch likes")
+		}
+	}
+	if details.tags, err = router.Data.TagsOfPosts(topLevel); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch tags")
+	}
+	if details.edits, err = router.Data.LastEdits(topLevel); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch edits")
+	}
+	return details, nil
+}
+
+// convert converts one of the posts the details have been fetched for.
+func (details *apiPostDetails) convert(post *models.Post) (apiPost, error) {
+	author, ok := details.authors[post.UserID]
+	if !ok {
+		return apiPost{}, errors.New("failed to find author")
+	}
+	var editedAt *time.Time
+	if edited, ok := details.edits[post.ID]; ok {
+		editedAt = &edited
+	}
+	return apiPost{
+		ID:        post.ID,
+		ParentID:  post.ParentID,
+		Author:    author.Name,
+		Title:     post.Title,
+		Content:   post.Content,
+		Tags:      details.tags[post.ID],
+		CreatedAt: post.CreatedAt,
+		UpdatedAt: post.UpdatedAt,
+		EditedAt:  editedAt,
+		State:     post.State,
+		PublishAt: post.PublishAt,
+		Likes:     details.likes[post.ID],
+		Comments:  details.comments[post.ID],
+		Liked:     details.liked[post.ID],
+	}, nil
+}
+
+func (router *Router) apiPostFrom(caller *apiCaller, post *models.Post) (apiPost, error) {
+	details, err := router.apiPostDetailsOf(caller, []models.Post{*post})
+	if err != nil {
+		return apiPost{}, err
+	}
+	return details.convert(post)
+}
+
+// apiPostsFrom converts the given posts, skipping and logging posts that can not be converted.
+// The details of all posts are fetched at once.
+func (router *Router) apiPostsFrom(r *http.Request, caller *apiCaller, posts []models.Post) []apiPost {
+	result := make([]apiPost, 0, len(posts))
+	details, err := router.apiPostDetailsOf(caller, posts)
+	if err != nil {
+		log.WithRequest(r).WithError(err).Error("failed to fetch post details")
+		return result
+	}
+	for i := range posts {
+		post, err := details.convert(&posts[i])
+		if err != nil {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"post": posts[i].ID,
+			}).WithError(err).Error("failed to convert post")
+			continue
+		}
+		result = append(result, post)
+	}
+	return result
+}
+
+func (router *Router) apiUserFrom(ctx context.Context, user *models.User) apiUser {
+	result := apiUser{
+		Name:        user.Name,
+		MemberSince: user.CreatedAt,
+		Followers:   router.Data.FollowerCount(user.ID),
+		Following:   router.Data.FollowingCount(user.ID),
+	}
+	details, err := router.profileDetails(ctx, user)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"id": user.ID,
+		}).WithError(err).Error("failed to get profile")
+		return result
+	}
+	result.DisplayName = details.DisplayName
+	result.Biography = details.Biography
+	result.AvatarURL = details.ImageURL
+	return result
+}
+
+func (router *Router) apiTokenCreate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if !router.apiDecode(w, r, &req) {
+		return
+	}
+	if lockout := router.throttle(r, "login", req.Email, router.RateLimits.Login, router.RateLimits.LoginHost); lockout > 0 {
+		setRetryAfter(w, lockout)
+		router.apiError(w, http.StatusTooManyRequests, "rate_limited", "Too many attempts, please try again later.")
+		return
+	}
+	id, confirmed, err := router.Data.HasUser(req.Email, []byte(req.Password))
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"email": req.Email,
+		}).WithError(err).Debug("failed api login attempt")
+		router.apiError(w, http.StatusUnauthorized, "invalid_credentials", "User identity does not exist.")
+		return
+	}
+	if !confirmed {
+		router.apiError(w, http.StatusForbidden, "unconfirmed", "User identity is not confirmed.")
+		return
+	}
+	if router.Data.TwoFactorEnabled(id) {
+		if req.Code == "" {
+			router.apiError(w, http.StatusUnauthorized, "two_factor_required", "An authentication or recovery code is required.")
+			return
+		}
+		if !router.verifySecondFactor(id, req.Code) {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"id": id,
+			}).Debug("failed api second factor attempt")
+			router.apiError(w, http.StatusUnauthorized, "invalid_code", "Invalid authentication code.")
+			return
+		}
+	}
+	router.resetThrottle(r, "login", req.Email)
+	token, expires, err := router.Session.Create(r.Context(), id, r.UserAgent(), logger.RemoteHost(r), false)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": id,
+		}).WithError(err).Error("failed to create session")
+		router.apiInternalError(w)
+		return
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id": id,
+	}).Info("user api login")
+	router.apiJSON(w, http.StatusCreated, apiToken{token, expires})
+}
+
+// apiTokenRefresh extends the lifetime of the bearer token and returns its replacement.
+func (router *Router) apiTokenRefresh(w http.ResponseWriter, r *http.Request) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token, expires, err := router.Session.Refresh(r.Context(), token)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": caller.UserID,
+		}).WithError(err).Warn("failed to refresh session")
+		router.apiInternalError(w)
+		return
+	}
+	router.apiJSON(w, http.StatusOK, apiToken{token, expires})
+}
+
+func (router *Router) apiTokenDelete(w http.ResponseWriter, r *http.Request) {
+	if _, ok := router.apiRequireAuth(w, r); !ok {
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if err := router.Session.Delete(r.Context(), token); err != nil {
+		log.WithRequest(r).WithError(err).Warn("failed to delete session")
+		router.apiInternalError(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *Router) apiMe(w http.ResponseWriter, r *http.Request) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	user, err := router.Data.User(caller.UserID)
+	if err != nil {
+		router.apiError(w, http.StatusNotFound, "not_found", "User does not exist.")
+		return
+	}
+	router.apiJSON(w, http.StatusOK, struct {
+		apiUser
+		Moderator bool `json:"moderator"`
+	}{router.apiUserFrom(r.Context(), user), caller.Moderator})
+}
+
+func (router *Router) apiPopular(w http.ResponseWriter, r *http.Request) {
+	after, err := decodePopularCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		router.apiError(w, http.StatusBadRequest, "bad_cursor", "The cursor is invalid.")
+		return
+	}
+	limit, ok := router.apiLimit(w, r)
+	if !ok {
+		return
+	}
+	mode := r.URL.Query().Get("interval")
+	if mode == "" {
+		mode = "week"
+	}
+	interval, ok := popularIntervals[mode]
+	if !ok {
+		router.apiError(w, http.StatusBadRequest, "bad_interval", "The interval must be one of week, month or year.")
+		return
+	}
+	tag := models.NormalizeTag(r.URL.Query().Get("tag"))
+	if tag != "" && !router.Data.ValidateTag(tag) {
+		router.apiError(w, http.StatusBadRequest, "bad_tag", "The tag must only consist of letters, digits and underscores.")
+		return
+	}
+	posts, last, err := router.Data.PopularPosts(time.Now().Add(-interval), tag, after, limit)
+	if err != nil {
+		log.WithRequest(r).WithError(err).Error("failed to fetch popular posts")
+		router.apiInternalError(w)
+		return
+	}
+	list := apiList{Data: router.apiPostsFrom(r, router.apiAuthenticate(r), posts)}
+	if len(posts) == limit {
+		list.NextCursor = encodePopularCursor(last)
+	}
+	router.apiJSON(w, http.StatusOK, list)
+}
+
+func (router *Router) apiMembers(w http.ResponseWriter, r *http.Request) {
+	before, limit, ok := router.apiPage(w, r)
+	if !ok {
+		return
+	}
+	users, err := router.Data.RecentUsersBefore(before, limit)
+	if err != nil {
+		log.WithRequest(r).WithError(err).Error("failed to fetch new users")
+		router.apiInternalError(w)
+		return
+	}
+	data := make([]apiUser, len(users))
+	for i := range users {
+		data[i] = router.apiUserFrom(r.Context(), &users[i])
+	}
+	list := apiList{Data: data}
+	if len(users) == limit {
+		list.NextCursor = encodeCursor(users[len(users)-1].ID)
+	}
+	router.apiJSON(w, http.StatusOK, list)
+}
+
+func (router *Router) apiFollowing(w http.ResponseWriter, r *http.Request) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	before, limit, ok := router.apiPostPage(w, r)
+	if !ok {
+		return
+	}
+	posts, err := router.Data.FollowingTimeline(caller.UserID, before, limit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": caller.UserID,
+		}).WithError(err).Error("failed to fetch following timeline")
+		router.apiInternalError(w)
+		return
+	}
+	list := apiList{Data: router.apiPostsFrom(r, caller, posts)}
+	if len(posts) == limit {
+		list.NextCursor = encodePostCursor(posts[len(posts)-1].Cursor())
+	}
+	router.apiJSON(w, http.StatusOK, list)
+}
+
+func (router *Router) apiPost(w http.ResponseWriter, r *http.Request) {
+	post, ok := router.apiPostByVar(w, r)
+	if !ok {
+		return
+	}
+	result, err := router.apiPostFrom(router.apiAuthenticate(r), post)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"post": post.ID,
+		}).WithError(err).Error("failed to convert post")
+		router.apiInternalError(w)
+		return
+	}
+	router.apiJSON(w, http.StatusOK, result)
+}
+
+type apiPostRequest struct {
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Tags      []string   `json:"tags"`
+	State     string     `json:"state"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+func (router *Router) validatePostRequest(w http.ResponseWriter, req *apiPostRequest) bool {
+	if !router.Data.ValidatePostTitle(req.Title) {
+		router.apiError(w, http.StatusUnprocessableEntity, "invalid_title", "Your title must have between 3 and 80 characters.")
+		return false
+	}
+	if !router.Data.ValidatePostContent(req.Content) {
+		router.apiError(w, http.StatusUnprocessableEntity, "invalid_content", "Your content must have between 10 and 80000 characters.")
+		return false
+	}
+	if !router.Data.ValidatePostTags(req.Content, req.Tags) {
+		router.apiError(w, http.StatusUnprocessableEntity, "invalid_tags", "Your post can have at max 10 tags of at max 32 letters, digits or underscores.")
+		return false
+	}
+	return true
+}
+
+func (router *Router) apiPostCreate(w http.ResponseWriter, r *http.Request) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	var req apiPostRequest
+	if !router.apiDecode(w, r, &req) {
+		return
+	}
+	if req.State != "" && req.State != models.PostPublished {
+		if id, ok := router.apiSaveDraft(w, r, caller, 0, &req); ok {
+			router.apiCreated(w, r, caller, id)
+		}
+		return
+	}
+	if !router.validatePostRequest(w, &req) {
+		return
+	}
+	id, err := router.Data.AddPost(caller.UserID, req.Title, req.Content, req.Tags)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": caller.UserID,
+		}).WithError(err).Error("failed to add post")
+		router.apiInternalError(w)
+		return
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":   caller.UserID,
+		"post": id,
+	}).Debug("added new post")
+	router.apiCreated(w, r, caller, id)
+}
+
+// apiCreated responds with the newly created post identified by the given ID.
+func (router *Router) apiCreated(w http.ResponseWriter, r *http.Request, caller *apiCaller, id uint) {
+	post, err := router.Data.Post(id)
+	if err != nil {
+		router.apiInternalError(w)
+		return
+	}
+	result, err := router.apiPostFrom(caller, post)
+	if err != nil {
+		router.apiInternalError(w)
+		return
+	}
+	router.apiJSON(w, http.StatusCreated, result)
+}
+
+func (router *Router) apiPostUpdate(w http.ResponseWriter, r *http.Request) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	post, ok := router.apiPostByVar(w, r)
+	if !ok {
+		return
+	}
+	if post.UserID != caller.UserID {
+		router.apiError(w, http.StatusForbidden, "forbidden", "You can only edit your own posts.")
+		return
+	}
+	if post.ParentID != 0 {
+		router.apiError(w, http.StatusUnprocessableEntity, "is_comment", "Comments can not be edited.")
+		return
+	}
+	var req apiPostRequest
+	if !router.apiDecode(w, r, &req) {
+		return
+	}
+	if !post.Published() {
+		if req.State == "" {
+			req.State = post.State
+		}
+		if req.PublishAt == nil {
+			req.PublishAt = post.PublishAt
+		}
+		if _, ok := router.apiSaveDraft(w, r, caller, post.ID, &req); ok {
+			router.apiPostUpdated(w, caller, post.ID)
+		}
+		return
+	}
+	if req.State != "" && req.State != models.PostPublished {
+		router.apiError(w, http.StatusConflict, "published", "Published posts can not be turned back into drafts.")
+		return
+	}
+	if !router.validatePostRequest(w, &req) {
+		return
+	}
+	if err := router.Data.UpdatePost(caller.UserID, post.ID, req.Title, req.Content, req.Tags); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   caller.UserID,
+			"post": post.ID,
+		}).WithError(err).Error("failed to update post")
+		router.apiInternalError(w)
+		return
+	}
+	router.apiPostUpdated(w, caller, post.ID)
+}
+
+// apiPostUpdated responds with the updated post identified by the given ID.
+func (router *Router) apiPostUpdated(w http.ResponseWriter, caller *apiCaller, id uint) {
+	post, err := router.Data.Post(id)
+	if err != nil {
+		router.apiInternalError(w)
+		return
+	}
+	result, err := router.apiPostFrom(caller, post)
+	if err != nil {
+		router.apiInternalError(w)
+		return
+	}
+	router.apiJSON(w, http.StatusOK, result)
+}
+
+// apiSaveDraft saves a new or unpublished post and moves it into the requested state.
+// It returns the ID of the post or writes an error response if the request is invalid.
+func (router *Router) apiSaveDraft(w http.ResponseWriter, r *http.Request, caller *apiCaller, id uint, req *apiPostRequest) (uint, bool) {
+	switch req.State {
+	case models.PostDraft:
+		if !router.Data.ValidateDraft(req.Title, req.Content) {
+			router.apiError(w, http.StatusUnprocessableEntity, "invalid_draft", "Your title must have at max 80 characters and your content at max 80000 characters.")
+			return 0, false
+		}
+		if !router.Data.ValidatePostTags(req.Content, req.Tags) {
+			router.apiError(w, http.StatusUnprocessableEntity, "invalid_tags", "Your post can have at max 10 tags of at max 32 letters, digits or underscores.")
+			return 0, false
+		}
+	case models.PostScheduled, models.PostPublished:
+		if !router.validatePostRequest(w, req) {
+			return 0, false
+		}
+	default:
+		router.apiError(w, http.StatusUnprocessableEntity, "invalid_state", "The state must be one of draft, scheduled or published.")
+		return 0, false
+	}
+	var publishAt time.Time
+	if req.State == models.PostScheduled {
+		if req.PublishAt == nil || !req.PublishAt.After(time.Now()) {
+			router.apiError(w, http.StatusUnprocessableEntity, "invalid_publish_at", "Scheduled posts must be published in the future.")
+			return 0, false
+		}
+		publishAt = *req.PublishAt
+	}
+	id, err := router.Data.SaveDraft(caller.UserID, id, req.Title, req.Content, req.Tags)
+	if err == nil {
+		err = router.Data.SetPostState(caller.UserID, id, req.State, publishAt)
+	}
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":    caller.UserID,
+			"post":  id,
+			"state": req.State,
+		}).WithError(err).Error("failed to save draft")
+		router.apiInternalError(w)
+		return 0, false
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":    caller.UserID,
+		"post":  id,
+		"state": req.State,
+	}).Debug("saved draft")
+	return id, true
+}
+
+func (router *Router) apiDrafts(w http.ResponseWriter, r *http.Request) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	drafts, err := router.Data.Drafts(caller.UserID)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": caller.UserID,
+		}).WithError(err).Error("failed to fetch drafts")
+		router.apiInternalError(w)
+		return
+	}
+	router.apiJSON(w, http.StatusOK, apiList{Data: router.apiPostsFrom(r, caller, drafts)})
+}
+
+func (router *Router) apiPostDelete(w http.ResponseWriter, r *http.Request) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	post, ok := router.apiPostByVar(w, r)
+	if !ok {
+		return
+	}
+	if post.UserID != caller.UserID {
+		router.apiError(w, http.StatusForbidden, "forbidden", "You can only delete your own posts.")
+		return
+	}
+	if err := router.Data.DeletePost(caller.UserID, post.ID); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   caller.UserID,
+			"post": post.ID,
+		}).WithError(err).Error("failed to delete post")
+		router.apiInternalError(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *Router) apiComments(w http.ResponseWriter, r *http.Request) {
+	post, ok := router.apiPostByVar(w, r)
+	if !ok {
+		return
+	}
+	after, limit, ok := router.apiPage(w, r)
+	if !ok {
+		return
+	}
+	comments, err := router.Data.CommentsAfter(post.ID, after, limit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"post": post.ID,
+		}).WithError(err).Error("failed to fetch comments")
+		router.apiInternalError(w)
+		return
+	}
+	list := apiList{Data: router.apiPostsFrom(r, router.apiAuthenticate(r), comments)}
+	if len(comments) == limit {
+		list.NextCursor = encodeCursor(comments[len(comments)-1].ID)
+	}
+	router.apiJSON(w, http.StatusOK, list)
+}
+
+func (router *Router) apiCommentCreate(w http.ResponseWriter, r *http.Request) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	post, ok := router.apiPublishedPostByVar(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Content string `json:"content"`
+	}
+	if !router.apiDecode(w, r, &req) {
+		return
+	}
+	if !router.Data.ValidateComment(req.Content) {
+		router.apiError(w, http.StatusUnprocessableEntity, "invalid_content", "Your comment must not be empty and have at max 4000 characters.")
+		return
+	}
+	id, err := router.Data.AddComment(caller.UserID, post.ID, req.Content)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   caller.UserID,
+			"post": post.ID,
+		}).WithError(err).Error("failed to add comment")
+		router.apiInternalError(w)
+		return
+	}
+	router.apiCreated(w, r, caller, id)
+}
+
+// apiSetLike ensures that the like state of the post referenced in the request matches the given state.
+func (router *Router) apiSetLike(w http.ResponseWriter, r *http.Request, liked bool) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	post, ok := router.apiPublishedPostByVar(w, r)
+	if !ok {
+		return
+	}
+	if router.Data.HasLiked(caller.UserID, post.ID) != liked {
+		if err := router.Data.ToggleLike(caller.UserID, post.ID); err != nil {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"id":   caller.UserID,
+				"post": post.ID,
+			}).WithError(err).Error("failed to toggle like")
+			router.apiInternalError(w)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *Router) apiLike(w http.ResponseWriter, r *http.Request) {
+	router.apiSetLike(w, r, true)
+}
+
+func (router *Router) apiUnlike(w http.ResponseWriter, r *http.Request) {
+	router.apiSetLike(w, r, false)
+}
+
+func (router *Router) apiReport(w http.ResponseWriter, r *http.Request) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	post, ok := router.apiPublishedPostByVar(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if !router.apiDecode(w, r, &req) {
+		return
+	}
+	if !router.Data.ValidateReportReason(req.Reason) {
+		router.apiError(w, http.StatusUnprocessableEntity, "invalid_reason", "The reasoning must be at max 240 characters.")
+		return
+	}
+	if err := router.Data.AddReport(post.ID, caller.UserID, req.Reason); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   caller.UserID,
+			"post": post.ID,
+		}).WithError(err).Error("failed to add report")
+		router.apiInternalError(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *Router) apiUser(w http.ResponseWriter, r *http.Request) {
+	user, err := router.Data.UserByName(mux.Vars(r)["user"])
+	if err != nil {
+		router.apiError(w, http.StatusNotFound, "not_found", "User does not exist.")
+		return
+	}
+	router.apiJSON(w, http.StatusOK, router.apiUserFrom(r.Context(), user))
+}
+
+func (router *Router) apiUserPosts(w http.ResponseWriter, r *http.Request) {
+	user, err := router.Data.UserByName(mux.Vars(r)["user"])
+	if err != nil {
+		router.apiError(w, http.StatusNotFound, "not_found", "User does not exist.")
+		return
+	}
+	before, limit, ok := router.apiPostPage(w, r)
+	if !ok {
+		return
+	}
+	posts, err := router.Data.PostsByUserBefore(user.ID, before, limit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   user.ID,
+			"name": user.Name,
+		}).WithError(err).Error("failed to get posts")
+		router.apiInternalError(w)
+		return
+	}
+	list := apiList{Data: router.apiPostsFrom(r, router.apiAuthenticate(r), posts)}
+	if len(posts) == limit {
+		list.NextCursor = encodePostCursor(posts[len(posts)-1].Cursor())
+	}
+	router.apiJSON(w, http.StatusOK, list)
+}
+
+// apiSetFollow ensures that the caller follows the user referenced in the request, if follow is set.
+func (router *Router) apiSetFollow(w http.ResponseWriter, r *http.Request, follow bool) {
+	caller, ok := router.apiRequireAuth(w, r)
+	if !ok {
+		return
+	}
+	user, err := router.Data.UserByName(mux.Vars(r)["user"])
+	if err != nil {
+		router.apiError(w, http.StatusNotFound, "not_found", "User does not exist.")
+		return
+	}
+	if user.ID == caller.UserID {
+		router.apiError(w, http.StatusUnprocessableEntity, "invalid_followee", "You can not follow yourself.")
+		return
+	}
+	if follow {
+		err = router.Data.Follow(caller.UserID, user.ID)
+	} else {
+		err = router.Data.Unfollow(caller.UserID, user.ID)
+	}
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":       caller.UserID,
+			"followee": user.ID,
+		}).WithError(err).Error("failed to change follow")
+		router.apiInternalError(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *Router) apiFollow(w http.ResponseWriter, r *http.Request) {
+	router.apiSetFollow(w, r, true)
+}
+
+func (router *Router) apiUnfollow(w http.ResponseWriter, r *http.Request) {
+	router.apiSetFollow(w, r, false)
+}
+
+func apiHighlightsFrom(highlights []models.Highlight) []apiHighlight {
+	result := make([]apiHighlight, len(highlights))
+	for i, highlight := range highlights {
+		result[i] = apiHighlight{highlight.Text, highlight.Match}
+	}
+	return result
+}
+
+// apiSearchQuery reads the search query and writes an error response if it is invalid.
+func (router *Router) apiSearchQuery(w http.ResponseWriter, r *http.Request) (string, bool) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if !router.Data.ValidateSearch(query) {
+		router.apiError(w, http.StatusBadRequest, "bad_query", "The search query must not be empty and have at max 200 characters.")
+		return "", false
+	}
+	return query, true
+}
+
+func (router *Router) apiSearchPosts(w http.ResponseWriter, r *http.Request) {
+	query, ok := router.apiSearchQuery(w, r)
+	if !ok {
+		return
+	}
+	values := r.URL.Query()
+	filter, message := router.parseSearchFilter(values.Get("author"), values.Get("from"), values.Get("to"))
+	if message != "" {
+		router.apiError(w, http.StatusBadRequest, "bad_filter", message)
+		return
+	}
+	offset, limit, ok := router.apiPage(w, r)
+	if !ok {
+		return
+	}
+	results, err := router.Data.SearchPosts(query, filter, int(offset), limit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"query": query,
+		}).WithError(err).Error("failed to search posts")
+		router.apiInternalError(w)
+		return
+	}
+	posts := make([]models.Post, len(results))
+	for i := range results {
+		posts[i] = results[i].Post
+	}
+	details, err := router.apiPostDetailsOf(router.apiAuthenticate(r), posts)
+	if err != nil {
+		log.WithRequest(r).WithError(err).Error("failed to fetch post details")
+		router.apiInternalError(w)
+		return
+	}
+	data := make([]apiSearchPost, 0, len(results))
+	for i := range results {
+		post, err := details.convert(&results[i].Post)
+		if err != nil {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"post": results[i].ID,
+			}).WithError(err).Error("failed to convert post")
+			continue
+		}
+		data = append(data, apiSearchPost{post, apiHighlightsFrom(results[i].Snippet)})
+	}
+	list := apiList{Data: data}
+	if len(results) == limit {
+		list.NextCursor = encodeCursor(offset + uint(limit))
+	}
+	router.apiJSON(w, http.StatusOK, list)
+}
+
+func (router *Router) apiSearchUsers(w http.ResponseWriter, r *http.Request) {
+	query, ok := router.apiSearchQuery(w, r)
+	if !ok {
+		return
+	}
+	offset, limit, ok := router.apiPage(w, r)
+	if !ok {
+		return
+	}
+	results, err := router.Data.SearchUsers(query, int(offset), limit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"query": query,
+		}).WithError(err).Error("failed to search users")
+		router.apiInternalError(w)
+		return
+	}
+	data := make([]apiSearchUser, len(results))
+	for i := range results {
+		data[i] = apiSearchUser{router.apiUserFrom(r.Context(), &results[i].User), apiHighlightsFrom(results[i].Snippet)}
+	}
+	list := apiList{Data: data}
+	if len(results) == limit {
+		list.NextCursor = encodeCursor(offset + uint(limit))
+	}
+	router.apiJSON(w, http.StatusOK, list)
+}
+
+func (router *Router) apiTrendingTags(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("interval")
+	if mode == "" {
+		mode = "week"
+	}
+	interval, ok := popularIntervals[mode]
+	if !ok {
+		router.apiError(w, http.StatusBadRequest, "bad_interval", "The interval must be one of week, month or year.")
+		return
+	}
+	tags, err := router.Data.TrendingTags(time.Now().Add(-interval), apiDefaultLimit)
+	if err != nil {
+		log.WithRequest(r).WithError(err).Error("failed to fetch trending tags")
+		router.apiInternalError(w)
+		return
+	}
+	data := make([]apiTag, len(tags))
+	for i, tag := range tags {
+		data[i] = apiTag{tag.Name, tag.Posts}
+	}
+	router.apiJSON(w, http.StatusOK, apiList{Data: data})
+}
+
+func (router *Router) apiTagPosts(w http.ResponseWriter, r *http.Request) {
+	tag := models.NormalizeTag(mux.Vars(r)["tag"])
+	if !router.Data.ValidateTag(tag) {
+		router.apiError(w, http.StatusNotFound, "not_found", "Tag does not exist.")
+		return
+	}
+	before, limit, ok := router.apiPostPage(w, r)
+	if !ok {
+		return
+	}
+	posts, err := router.Data.PostsByTagBefore(tag, before, limit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"tag": tag,
+		}).WithError(err).Error("failed to fetch tagged posts")
+		router.apiInternalError(w)
+		return
+	}
+	list := apiList{Data: router.apiPostsFrom(r, router.apiAuthenticate(r), posts)}
+	if len(posts) == limit {
+		list.NextCursor = encodePostCursor(posts[len(posts)-1].Cursor())
+	}
+	router.apiJSON(w, http.StatusOK, list)
+}
+
+func (router *Router) apiRevisions(w http.ResponseWriter, r *http.Request) {
+	post, ok := router.apiPostByVar(w, r)
+	if !ok {
+		return
+	}
+	revisions, err := router.Data.Revisions(post.ID)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"post": post.ID,
+		}).WithError(err).Error("failed to fetch revisions")
+		router.apiInternalError(w)
+		return
+	}
+	data := make([]apiRevision, len(revisions))
+	for i, revision := range revisions {
+		data[i] = apiRevision{i + 1, revision.Title, revision.Content, revision.CreatedAt}
+	}
+	router.apiJSON(w, http.StatusOK, apiList{Data: data})
+}
@@ -5,6 +5,9 @@ import (
 	"time"
 
 	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+	"github.com/lnsp/microlog/common/logger"
+	"github.com/lnsp/microlog/gateway/internal/email"
 
 	"github.com/sirupsen/logrus"
 )
@@ -15,42 +18,29 @@ type emailContext struct {
 }
 
 func (router *Router) defaultContext(r *http.Request) *Context {
-	csrfToken := csrf.TemplateField(r)
-	sessionCookie, err := r.Cookie(sessionCookieName)
-	if err != nil {
-		return &Context{
-			SignedIn:     false,
-			HeadControls: true,
-			CurrentYear:  time.Now().Year(),
-			CSRFToken:    csrfToken,
-		}
-	}
-	id, mod, err := router.Session.Verify(sessionCookie.Value)
-	if err != nil {
-		log.WithFields(logrus.Fields{
-			"token": sessionCookie.Value,
-			"type":  "failed to verify token",
-		}).WithError(err).Error("failed to create context")
-		return &Context{
-			SignedIn:     false,
-			HeadControls: true,
-			CurrentYear:  time.Now().Year(),
-			CSRFToken:    csrfToken,
-		}
-	}
-	return &Context{
-		SignedIn:     true,
-		UserID:       id,
+	ctx := &Context{
+		SignedIn:     false,
 		HeadControls: true,
-		Moderator:    mod,
 		CurrentYear:  time.Now().Year(),
-		CSRFToken:    csrfToken,
+		CSRFToken:    csrf.TemplateField(r),
 	}
+	if router.OIDC != nil {
+		ctx.ExternalLogin = router.OIDCName
+	}
+	info := router.sessionInfo(r)
+	if info == nil {
+		return ctx
+	}
+	ctx.SignedIn = true
+	ctx.UserID = info.UserID
+	ctx.SessionID = info.ID
+	ctx.Moderator = info.Moderator
+	return ctx
 }
 
 func (router *Router) confirm(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query()["token"][0]
-	email, userID, err := router.Email.VerifyConfirmationToken(token)
+	email, userID, err := router.Email.ConsumeConfirmationToken(r.Context(), token)
 	ctx := emailContext{
 		Context: *router.defaultContext(r),
 		Success: false,
@@ -97,7 +87,7 @@ func (router *Router) forgotSubmit(w http.ResponseWriter, r *http.Request) {
 	email := r.FormValue("email")
 	id, err := router.Data.IdentityByEmail(email)
 	if err == nil && id.Confirmed {
-		if err := router.Email.SendPasswordReset(id.UserID, email); err != nil {
+		if _, err := router.Email.SendPasswordReset(r.Context(), id.UserID, email); err != nil {
 			ctx.Success = false
 			ctx.ErrorMessage = "Unexpected internal error, please try again."
 			log.WithRequest(r).WithFields(logrus.Fields{
@@ -124,9 +114,61 @@ func (router *Router) forgotSubmit(w http.ResponseWriter, r *http.Request) {
 	router.render(forgotTemplate, w, ctx)
 }
 
+type resendContext struct {
+	Context
+	Success bool
+	Email   string
+}
+
+func (router *Router) confirmResend(w http.ResponseWriter, r *http.Request) {
+	ctx := resendContext{
+		Context: *router.defaultContext(r),
+		Email:   r.URL.Query().Get("email"),
+	}
+	router.render(confirmResendTemplate, w, ctx)
+}
+
+// confirmResendSubmit sends another confirmation link to an unconfirmed identity.
+// The response does not tell whether the address is known, so that it can not be used to look up accounts.
+func (router *Router) confirmResendSubmit(w http.ResponseWriter, r *http.Request) {
+	ctx := resendContext{
+		Context: *router.defaultContext(r),
+		Success: true,
+	}
+	email := r.FormValue("email")
+	id, err := router.Data.IdentityByEmail(email)
+	if err == nil && !id.Confirmed {
+		if _, err := router.Email.SendConfirmation(r.Context(), id.UserID, email); err != nil {
+			ctx.Success = false
+			ctx.Email = email
+			ctx.ErrorMessage = "Unexpected internal error, please try again."
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"email": email,
+				"id":    id.UserID,
+			}).WithError(err).Error("failed to resend confirmation email")
+		} else {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"email": email,
+				"id":    id.UserID,
+			}).Debug("resent confirmation email")
+		}
+	} else if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"email": email,
+			"type":  "unknown identity",
+		}).WithError(err).Debug("attempt to resend confirmation")
+	} else {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"email": email,
+			"type":  "confirmed identity",
+		}).Debug("attempt to resend confirmation")
+	}
+	router.render(confirmResendTemplate, w, ctx)
+}
+
 func (router *Router) reset(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query()["token"][0]
-	_, _, err := router.Email.VerifyPasswordResetToken(token)
+	_, _, err := router.Email.VerifyPasswordResetToken(r.Context(), token)
 	ctx := emailContext{
 		Context: *router.defaultContext(r),
 		Success: false,
@@ -141,7 +183,7 @@ func (router *Router) reset(w http.ResponseWriter, r *http.Request) {
 
 func (router *Router) resetSubmit(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query()["token"][0]
-	email, userID, err := router.Email.VerifyPasswordResetToken(token)
+	email, userID, err := router.Email.VerifyPasswordResetToken(r.Context(), token)
 	ctx := emailContext{
 		Context: *router.defaultContext(r),
 		Success: false,
@@ -179,6 +221,27 @@ func (router *Router) resetSubmit(w http.ResponseWriter, r *http.Request) {
 		router.render(resetTemplate, w, ctx)
 		return
 	}
+	if router.Data.CommonPassword(password) {
+		ctx.ErrorMessage = "Password is too common, please choose another one."
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":    userID,
+			"token": token,
+			"type":  "common password",
+		}).Debug("attempt to reset password")
+		router.render(resetTemplate, w, ctx)
+		return
+	}
+	// Use up the token right before the reset, so that it can only be used once.
+	if _, _, err := router.Email.ConsumePasswordResetToken(r.Context(), token); err != nil {
+		ctx.Success = false
+		log.WithRequest(r).WithError(err).WithFields(logrus.Fields{
+			"id":    userID,
+			"token": token,
+			"type":  "token already used",
+		}).Debug("attempt to reset password")
+		router.render(resetTemplate, w, ctx)
+		return
+	}
 	if err := router.Data.ResetPassword(userID, email, []byte(password)); err != nil {
 		ctx.ErrorMessage = "Unexpected internal error, please try again."
 		log.WithRequest(r).WithFields(logrus.Fields{
@@ -189,6 +252,18 @@ func (router *Router) resetSubmit(w http.ResponseWriter, r *http.Request) {
 		router.render(resetTemplate, w, ctx)
 		return
 	}
+	// Outstanding reset links must not work with the new password in place.
+	if err := router.Email.RevokeTokens(r.Context(), userID); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": userID,
+		}).WithError(err).Error("failed to revoke email tokens")
+	}
+	// Whoever knew the old password may still be signed in.
+	if err := router.Session.DeleteAll(r.Context(), userID, ""); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": userID,
+		}).WithError(err).Error("failed to revoke sessions")
+	}
 	ctx.ErrorMessage = "You can now log in with your new password."
 	ctx.HeadControls = false
 	router.render(loginTemplate, w, ctx)
@@ -204,6 +279,7 @@ func (router *Router) loginSubmit(w http.ResponseWriter, r *http.Request) {
 	var (
 		email    = r.FormValue("email")
 		password = r.FormValue("password")
+		remember = r.FormValue("remember") == "on"
 	)
 	id, confirmed, err := router.Data.HasUser(email, []byte(password))
 	if err != nil {
@@ -217,7 +293,7 @@ func (router *Router) loginSubmit(w http.ResponseWriter, r *http.Request) {
 	}
 	if !confirmed {
 		ctx := router.defaultContext(r)
-		ctx.ErrorMessage = "User identity is not confirmed."
+		ctx.ErrorMessage = "User identity is not confirmed, follow the link in the confirmation email or request a new one below."
 		log.WithRequest(r).WithFields(logrus.Fields{
 			"id":    id,
 			"email": email,
@@ -225,6 +301,17 @@ func (router *Router) loginSubmit(w http.ResponseWriter, r *http.Request) {
 		router.render(loginTemplate, w, ctx)
 		return
 	}
+	// Accounts using two-factor authentication are reset once the second factor has been verified as well.
+	if router.Data.TwoFactorEnabled(id) {
+		router.beginTwoFactorLogin(w, r, id, email, remember)
+		return
+	}
+	router.resetThrottle(r, "login", email)
+	router.startSession(w, r, id, remember)
+}
+
+// startSession signs in the user after all authentication steps have been passed.
+func (router *Router) startSession(w http.ResponseWriter, r *http.Request, id uint, remember bool) {
 	user, err := router.Data.User(id)
 	if err != nil {
 		ctx := router.defaultContext(r)
@@ -235,7 +322,7 @@ func (router *Router) loginSubmit(w http.ResponseWriter, r *http.Request) {
 		router.render(loginTemplate, w, ctx)
 		return
 	}
-	token, err := router.Session.Create(id)
+	token, expires, err := router.Session.Create(r.Context(), id, r.UserAgent(), logger.RemoteHost(r), remember)
 	if err != nil {
 		ctx := router.defaultContext(r)
 		ctx.ErrorMessage = "Unexpected internal error, please try again."
@@ -245,25 +332,11 @@ func (router *Router) loginSubmit(w http.ResponseWriter, r *http.Request) {
 		router.render(loginTemplate, w, ctx)
 		return
 	}
-	if err != nil {
-		ctx := router.defaultContext(r)
-		ctx.ErrorMessage = "Unexpected internal error, please try again."
-		log.WithRequest(r).WithFields(logrus.Fields{
-			"id": id,
-		}).WithError(err).Error("failed to login user")
-		router.render(loginTemplate, w, ctx)
-		return
-	}
-	cookie := http.Cookie{
-		Path:    "/",
-		Name:    sessionCookieName,
-		Value:   token,
-		Expires: time.Now().Add(time.Hour),
-	}
-	http.SetCookie(w, &cookie)
+	setSessionCookie(w, token, expires, remember)
 	log.WithRequest(r).WithFields(logrus.Fields{
-		"id":   user.ID,
-		"name": user.Name,
+		"id":       user.ID,
+		"name":     user.Name,
+		"remember": remember,
 	}).Info("user login")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
@@ -276,6 +349,49 @@ type signupContext struct {
 	AcceptTOS bool
 }
 
+type signupSuccessContext struct {
+	signupContext
+	MailID string
+}
+
+type mailStatusContext struct {
+	Context
+	MailID  string
+	Pending bool
+	Sent    bool
+	Failed  bool
+}
+
+// mailStatus shows the delivery status of a queued email.
+// The page refreshes itself as long as the email is still pending.
+func (router *Router) mailStatus(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	state, err := router.Email.Status(r.Context(), id)
+	if err == email.ErrUnknownMessage {
+		router.renderNotFound(w, r, "email")
+		return
+	}
+	ctx := mailStatusContext{
+		Context: *router.defaultContext(r),
+		MailID:  id,
+	}
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"message": id,
+		}).WithError(err).Error("failed to get email status")
+		ctx.ErrorMessage = "Unexpected internal error, please try again."
+	}
+	switch state {
+	case email.StatusSent:
+		ctx.Sent = true
+	case email.StatusFailed:
+		ctx.Failed = true
+	default:
+		ctx.Pending = err == nil
+	}
+	router.render(mailStatusTemplate, w, ctx)
+}
+
 func (router *Router) signup(w http.ResponseWriter, r *http.Request) {
 	ctx := &signupContext{
 		Context: *router.defaultContext(r),
@@ -291,7 +407,7 @@ func (router *Router) logout(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
-	err = router.Session.Delete(sessionCookie.Value)
+	err = router.Session.Delete(r.Context(), sessionCookie.Value)
 	if err != nil {
 		log.WithRequest(r).WithError(err).Warn("failed to delete session")
 	}
@@ -327,6 +443,9 @@ func (router *Router) signupSubmit(w http.ResponseWriter, r *http.Request) {
 	} else if !router.Data.ValidatePassword(password) {
 		errMessage = "Password must have a minimum length of 8 characters."
 		ctx.Password = ""
+	} else if router.Data.CommonPassword(password) {
+		errMessage = "Password is too common, please choose another one."
+		ctx.Password = ""
 	} else if !router.Data.ValidateName(name) {
 		errMessage = "Username must only consist of lowercase alphanumerics."
 		ctx.Name = ""
@@ -356,7 +475,16 @@ func (router *Router) signupSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := router.Email.SendConfirmation(userID, email); err != nil {
+	if err := router.Profile.Create(r.Context(), userID, ""); err != nil {
+		// The profile will be created on first access, so this is not fatal.
+		log.WithError(err).WithFields(logrus.Fields{
+			"name":   name,
+			"userID": userID,
+		}).Warn("failed to create profile")
+	}
+
+	messageID, err := router.Email.SendConfirmation(r.Context(), userID, email)
+	if err != nil {
 		ctx.ErrorMessage = "Internal error occurred, please try again."
 		log.WithError(err).WithFields(logrus.Fields{
 			"name":   name,
@@ -372,7 +500,10 @@ func (router *Router) signupSubmit(w http.ResponseWriter, r *http.Request) {
 		"user":  name,
 		"email": email,
 	}).Debug("new user signed up")
-	router.render(signupSuccessTemplate, w, ctx)
+	router.render(signupSuccessTemplate, w, &signupSuccessContext{
+		signupContext: *ctx,
+		MailID:        messageID,
+	})
 }
 
 func (router *Router) delete(w http.ResponseWriter, r *http.Request) {
@@ -391,6 +522,21 @@ func (router *Router) deleteSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	router.Data.DeleteUser(ctx.UserID)
+	if err := router.Profile.Delete(r.Context(), ctx.UserID); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Warn("failed to delete profile")
+	}
+	if err := router.Email.RevokeTokens(r.Context(), ctx.UserID); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Warn("failed to revoke email tokens")
+	}
+	if err := router.Session.DeleteAll(r.Context(), ctx.UserID, ""); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Warn("failed to revoke sessions")
+	}
 	log.WithRequest(r).WithFields(logrus.Fields{
 		"id": ctx.UserID,
 	}).Info("deleted user account")
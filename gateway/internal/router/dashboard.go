@@ -6,16 +6,23 @@ import (
 	"time"
 
 	"github.com/dustin/go-humanize"
+	"github.com/lnsp/microlog/gateway/internal/models"
 	"github.com/sirupsen/logrus"
 )
 
 type dashboardPost struct {
 	Title, Author, ID, Date string
+	Avatar                  string
 	Likes                   int
 }
 
 type dashboardUser struct {
-	Name, MemberSince string
+	Name, MemberSince, Avatar string
+}
+
+type dashboardTag struct {
+	Name  string
+	Posts int
 }
 
 type dashboardOption struct {
@@ -29,6 +36,13 @@ type dashboardContext struct {
 	LatestUsers    []dashboardUser
 	PopularOptions []dashboardOption
 	PopularMode    string
+	// PopularTag restricts the popular posts to a tag, if not empty.
+	PopularTag   string
+	TrendingTags []dashboardTag
+	// FollowingPosts is the timeline of users followed by the signed-in user.
+	// FollowingNext is the cursor of the next page, if there is one.
+	FollowingPosts []dashboardPost
+	FollowingNext  string
 }
 
 const (
@@ -62,13 +76,27 @@ func (router *Router) dashboardContext(r *http.Request) *dashboardContext {
 		}
 		ctx.PopularMode = "week"
 	}
-	popularPosts, err := router.Data.PopularPosts(time.Now().Add(timeInterval), dashboardPostsLimit)
+	if tag := models.NormalizeTag(r.URL.Query().Get("tag")); router.Data.ValidateTag(tag) {
+		ctx.PopularTag = tag
+	}
+	popularPosts, _, err := router.Data.PopularPosts(time.Now().Add(timeInterval), ctx.PopularTag, models.PopularCursor{}, dashboardPostsLimit)
 	if err != nil {
 		log.WithRequest(r).WithFields(logrus.Fields{
 			"id": ctx.UserID,
 		}).WithError(err).Error("failed to fetch popular posts")
 		ctx.ErrorMessage = "An internal error occured, please try again."
 	}
+	trendingTags, err := router.Data.TrendingTags(time.Now().Add(timeInterval), dashboardTagsLimit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to fetch trending tags")
+		ctx.ErrorMessage = "An internal error occured, please try again."
+	}
+	ctx.TrendingTags = make([]dashboardTag, len(trendingTags))
+	for i, tag := range trendingTags {
+		ctx.TrendingTags[i] = dashboardTag{tag.Name, tag.Posts}
+	}
 	recentUsers, err := router.Data.RecentUsers(dashboardUsersLimit)
 	if err != nil {
 		log.WithRequest(r).WithFields(logrus.Fields{
@@ -76,44 +104,83 @@ func (router *Router) dashboardContext(r *http.Request) *dashboardContext {
 		}).WithError(err).Error("failed to fetch new users")
 		ctx.ErrorMessage = "An internal error occured, please try again."
 	}
-	j := 0
-	ctx.PopularPosts = make([]dashboardPost, len(popularPosts))
-	for _, post := range popularPosts {
-		user, err := router.Data.User(post.UserID)
-		if err != nil {
-			log.WithRequest(r).WithFields(logrus.Fields{
-				"id":   ctx.UserID,
-				"post": post.ID,
-			}).WithError(err).Error("failed to fetch user")
-			continue
-		}
-		likes, err := router.Data.NumberOfLikes(post.ID)
+	var followingPosts []models.Post
+	if ctx.SignedIn {
+		// Malformed cursors start at the newest post
+		before, _ := models.ParsePostCursor(r.URL.Query().Get("before"))
+		followingPosts, err = router.Data.FollowingTimeline(ctx.UserID, before, dashboardTimelineLimit)
 		if err != nil {
 			log.WithRequest(r).WithFields(logrus.Fields{
-				"id":   ctx.UserID,
-				"post": post.ID,
-			}).WithError(err).Error("failed to retrieve number of likes")
-			continue
+				"id": ctx.UserID,
+			}).WithError(err).Error("failed to fetch following timeline")
+			ctx.ErrorMessage = "An internal error occured, please try again."
 		}
-		ctx.PopularPosts[j] = dashboardPost{
-			Title:  post.Title,
-			Author: user.Name,
-			ID:     strconv.FormatUint(uint64(post.ID), 10),
-			Date:   humanize.Time(post.CreatedAt),
-			Likes:  likes,
+		if len(followingPosts) == dashboardTimelineLimit {
+			ctx.FollowingNext = followingPosts[len(followingPosts)-1].Cursor().String()
 		}
-		j++
 	}
+	// The avatars of all users on the dashboard are resolved at once
+	users := append(postAuthors(popularPosts), postAuthors(followingPosts)...)
+	for _, user := range recentUsers {
+		users = append(users, user.ID)
+	}
+	avatars := router.Profile.Thumbnails(r.Context(), users)
+	ctx.PopularPosts = router.dashboardPosts(r, ctx.UserID, popularPosts, avatars)
+	ctx.FollowingPosts = router.dashboardPosts(r, ctx.UserID, followingPosts, avatars)
 	ctx.LatestUsers = make([]dashboardUser, len(recentUsers))
 	for i, user := range recentUsers {
 		ctx.LatestUsers[i] = dashboardUser{
 			Name:        user.Name,
 			MemberSince: humanize.Time(user.CreatedAt),
+			Avatar:      avatars[user.ID],
 		}
 	}
 	return ctx
 }
 
+// dashboardPosts converts the given posts, skipping and logging posts that can not be converted.
+// The authors and likes of all posts are fetched at once, their avatars are taken from the given thumbnail URLs.
+func (router *Router) dashboardPosts(r *http.Request, userID uint, posts []models.Post, avatars map[uint]string) []dashboardPost {
+	result := make([]dashboardPost, 0, len(posts))
+	users, err := router.Data.UsersByID(postAuthors(posts))
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": userID,
+		}).WithError(err).Error("failed to fetch users")
+		return result
+	}
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	likes, err := router.Data.LikeCounts(ids)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": userID,
+		}).WithError(err).Error("failed to retrieve number of likes")
+		return result
+	}
+	for _, post := range posts {
+		user, ok := users[post.UserID]
+		if !ok {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"id":   userID,
+				"post": post.ID,
+			}).Error("failed to fetch user")
+			continue
+		}
+		result = append(result, dashboardPost{
+			Title:  post.Title,
+			Author: user.Name,
+			Avatar: avatars[user.ID],
+			ID:     strconv.FormatUint(uint64(post.ID), 10),
+			Date:   humanize.Time(post.CreatedAt),
+			Likes:  likes[post.ID],
+		})
+	}
+	return result
+}
+
 func (router *Router) dashboard(w http.ResponseWriter, r *http.Request) {
 	ctx := router.dashboardContext(r)
 	router.render(dashboardTemplate, w, ctx)
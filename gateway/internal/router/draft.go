@@ -0,0 +1,137 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// The post editor submits the label of the clicked button as its action.
+const (
+	postActionDraft    = "Save draft"
+	postActionPublish  = "Publish"
+	postActionSchedule = "Schedule"
+)
+
+// publishAtFormat is the format of datetime-local inputs.
+// The editor sends the offset of the browser to UTC in minutes with them, without it they are given in UTC.
+const publishAtFormat = "2006-01-02T15:04"
+
+// publishMaxOffset limits the offset of scheduled times to UTC in minutes.
+const publishMaxOffset = 14 * 60
+
+// publishLocation returns the time zone of a scheduled time with the given offset to UTC.
+func publishLocation(offset int) *time.Location {
+	if offset == 0 || offset < -publishMaxOffset || offset > publishMaxOffset {
+		return time.UTC
+	}
+	return time.FixedZone("", offset*60)
+}
+
+// draftSubmit saves a new or unpublished post and publishes or schedules it depending on the action.
+func (router *Router) draftSubmit(w http.ResponseWriter, r *http.Request, postCtx postContext, action string, tags []string) {
+	var (
+		state     = models.PostDraft
+		publishAt time.Time
+	)
+	if !router.Data.ValidateDraft(postCtx.Title, postCtx.Content) {
+		postCtx.ErrorMessage = "Your title must have at max 80 characters and your content at max 80000 characters."
+	} else if !router.Data.ValidatePostTags(postCtx.Content, tags) {
+		postCtx.ErrorMessage = "Your post can have at max 10 tags of at max 32 letters, digits or underscores."
+	} else if action == postActionPublish || action == postActionSchedule {
+		state = models.PostPublished
+		if !router.Data.ValidatePostTitle(postCtx.Title) {
+			postCtx.ErrorMessage = "Your title must have between 3 and 80 characters."
+		} else if !router.Data.ValidatePostContent(postCtx.Content) {
+			postCtx.ErrorMessage = "Your content must have between 10 and 80000 characters."
+		}
+	}
+	if action == postActionSchedule && postCtx.ErrorMessage == "" {
+		state = models.PostScheduled
+		var err error
+		publishAt, err = time.ParseInLocation(publishAtFormat, postCtx.PublishAt, publishLocation(postCtx.PublishOffset))
+		if err != nil || !publishAt.After(time.Now()) {
+			postCtx.ErrorMessage = "Please pick a time in the future to publish your post at."
+		}
+	}
+	if postCtx.ErrorMessage != "" {
+		router.render(postEditTemplate, w, postCtx)
+		return
+	}
+	id, err := router.Data.SaveDraft(postCtx.UserID, postCtx.ID, postCtx.Title, postCtx.Content, tags)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   postCtx.UserID,
+			"post": postCtx.ID,
+		}).WithError(err).Error("failed to save draft")
+		postCtx.ErrorMessage = "Unexpected internal error, please try again."
+		router.render(postEditTemplate, w, postCtx)
+		return
+	}
+	postCtx.ID = id
+	if state != models.PostDraft || postCtx.State == models.PostScheduled {
+		if err := router.Data.SetPostState(postCtx.UserID, id, state, publishAt); err != nil {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"id":    postCtx.UserID,
+				"post":  id,
+				"state": state,
+			}).WithError(err).Error("failed to change post state")
+			postCtx.ErrorMessage = "Unexpected internal error, please try again."
+			router.render(postEditTemplate, w, postCtx)
+			return
+		}
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":    postCtx.UserID,
+		"post":  id,
+		"state": state,
+	}).Debug("saved draft")
+	http.Redirect(w, r, fmt.Sprintf("/%s/%d/", postCtx.Author, id), http.StatusSeeOther)
+}
+
+// postAutosave stores the editor contents as a draft without leaving the editor.
+// It responds with the ID of the draft, so that the editor keeps saving to the same draft.
+func (router *Router) postAutosave(w http.ResponseWriter, r *http.Request) {
+	var (
+		title   = r.FormValue("title")
+		content = r.FormValue("content")
+		tags    = models.ParseTags(r.FormValue("tags"))
+	)
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		router.apiError(w, http.StatusUnauthorized, "unauthorized", "You have to be logged in to save drafts.")
+		return
+	}
+	id, _ := strconv.ParseUint(r.FormValue("id"), 10, 32)
+	if id != 0 {
+		post, err := router.Data.Post(uint(id))
+		if err != nil || post.UserID != ctx.UserID {
+			router.apiError(w, http.StatusNotFound, "not_found", "Post does not exist.")
+			return
+		}
+		if post.Published() {
+			router.apiError(w, http.StatusConflict, "published", "Published posts are not saved automatically.")
+			return
+		}
+	}
+	if !router.Data.ValidateDraft(title, content) || !router.Data.ValidatePostTags(content, tags) {
+		router.apiError(w, http.StatusUnprocessableEntity, "invalid_draft", "The draft can not be saved in its current form.")
+		return
+	}
+	draft, err := router.Data.SaveDraft(ctx.UserID, uint(id), title, content, tags)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   ctx.UserID,
+			"post": id,
+		}).WithError(err).Error("failed to autosave draft")
+		router.apiInternalError(w)
+		return
+	}
+	router.apiJSON(w, http.StatusOK, struct {
+		ID uint `json:"id"`
+	}{draft})
+}
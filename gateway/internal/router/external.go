@@ -0,0 +1,286 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	externalLoginCookie   = "external_login"
+	externalLoginLifetime = 10 * time.Minute
+)
+
+type externalSignupContext struct {
+	Context
+	Name      string
+	Email     string
+	AcceptTOS bool
+}
+
+// suggestName turns the username suggested by the provider into a valid name, if possible.
+func suggestName(name string) string {
+	var suggestion strings.Builder
+	for _, c := range strings.ToLower(name) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			suggestion.WriteRune(c)
+		}
+	}
+	return suggestion.String()
+}
+
+// clearExternalLogin removes the external login cookie.
+func clearExternalLogin(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Path: "/auth/external", Name: externalLoginCookie, Value: "", MaxAge: -1})
+}
+
+// renderExternalError shows the login form with the given error message.
+func (router *Router) renderExternalError(w http.ResponseWriter, r *http.Request, message string) {
+	ctx := router.defaultContext(r)
+	ctx.ErrorMessage = message
+	ctx.HeadControls = false
+	router.render(loginTemplate, w, ctx)
+}
+
+// beginExternalLogin sends the user to the identity provider.
+// If a user ID is given, the account is linked to that user instead of signing in.
+func (router *Router) beginExternalLogin(w http.ResponseWriter, r *http.Request, userID uint) {
+	login, err := router.Data.AddExternalLogin(userID, externalLoginLifetime)
+	if err != nil {
+		log.WithRequest(r).WithError(err).Error("failed to create external login")
+		router.renderExternalError(w, r, "Unexpected internal error, please try again.")
+		return
+	}
+	authURL, err := router.OIDC.AuthURL(r.Context(), login.ID, login.Nonce)
+	if err != nil {
+		log.WithRequest(r).WithError(err).Error("failed to reach identity provider")
+		router.renderExternalError(w, r, router.OIDCName+" is not available, please try again later.")
+		return
+	}
+	// The cookie binds the login to this browser, so that a callback can not be forged into another one
+	http.SetCookie(w, &http.Cookie{
+		Path:     "/auth/external",
+		Name:     externalLoginCookie,
+		Value:    login.ID,
+		MaxAge:   int(externalLoginLifetime / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusSeeOther)
+}
+
+func (router *Router) externalLogin(w http.ResponseWriter, r *http.Request) {
+	router.beginExternalLogin(w, r, 0)
+}
+
+func (router *Router) externalLink(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	router.beginExternalLogin(w, r, ctx.UserID)
+}
+
+// externalCallback completes the sign in at the identity provider.
+// Known accounts are signed in, unknown accounts continue with picking a username.
+func (router *Router) externalCallback(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	cookie, err := r.Cookie(externalLoginCookie)
+	if err != nil || cookie.Value != state {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"type": "state mismatch",
+		}).Debug("failed external login")
+		router.renderExternalError(w, r, "Your sign in has expired, please try again.")
+		return
+	}
+	login, err := router.Data.ExternalLogin(state)
+	if err != nil || login.Nonce == "" {
+		clearExternalLogin(w)
+		router.renderExternalError(w, r, "Your sign in has expired, please try again.")
+		return
+	}
+	if providerErr := r.URL.Query().Get("error"); providerErr != "" {
+		router.Data.DeleteExternalLogin(login.ID)
+		clearExternalLogin(w)
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"error": providerErr,
+		}).Debug("identity provider denied login")
+		router.renderExternalError(w, r, "Sign in with "+router.OIDCName+" has been cancelled.")
+		return
+	}
+	claims, err := router.OIDC.Exchange(r.Context(), r.URL.Query().Get("code"), login.Nonce)
+	if err != nil {
+		router.Data.DeleteExternalLogin(login.ID)
+		clearExternalLogin(w)
+		log.WithRequest(r).WithError(err).Warn("failed to verify external login")
+		router.renderExternalError(w, r, "Sign in with "+router.OIDCName+" failed, please try again.")
+		return
+	}
+	if login.UserID != 0 {
+		router.linkExternalIdentity(w, r, login, claims.Subject, claims.Email)
+		return
+	}
+	issuer := router.OIDC.Issuer()
+	if identity, err := router.Data.ExternalIdentity(issuer, claims.Subject); err == nil {
+		if !router.Data.DeleteExternalLogin(login.ID) {
+			router.renderExternalError(w, r, "Your sign in has expired, please try again.")
+			return
+		}
+		clearExternalLogin(w)
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":      identity.UserID,
+			"subject": claims.Subject,
+		}).Debug("external login")
+		if router.Data.TwoFactorEnabled(identity.UserID) {
+			router.beginTwoFactorLogin(w, r, identity.UserID, "", false)
+			return
+		}
+		router.startSession(w, r, identity.UserID, false)
+		return
+	}
+	name := claims.PreferredUsername
+	if name == "" {
+		name = strings.Split(claims.Email, "@")[0]
+	}
+	if err := router.Data.AuthenticateExternalLogin(login.ID, suggestName(name), issuer, claims.Subject, claims.Email, claims.EmailVerified); err != nil {
+		clearExternalLogin(w)
+		log.WithRequest(r).WithError(err).Warn("failed to store external login")
+		router.renderExternalError(w, r, "Your sign in has expired, please try again.")
+		return
+	}
+	http.Redirect(w, r, "/auth/external/signup", http.StatusSeeOther)
+}
+
+// linkExternalIdentity links the account authenticated by the provider to the user who started the login.
+func (router *Router) linkExternalIdentity(w http.ResponseWriter, r *http.Request, login *models.ExternalLogin, subject, email string) {
+	router.Data.DeleteExternalLogin(login.ID)
+	clearExternalLogin(w)
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn || ctx.UserID != login.UserID {
+		router.renderExternalError(w, r, "Your sign in has expired, please try again.")
+		return
+	}
+	switch err := router.Data.LinkExternalIdentity(ctx.UserID, router.OIDC.Issuer(), subject, email); err {
+	case nil:
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":      ctx.UserID,
+			"subject": subject,
+		}).Info("linked external identity")
+		http.Redirect(w, r, "/profile/identities", http.StatusSeeOther)
+		return
+	case models.ErrExternalIdentityLinked:
+		ctx.ErrorMessage = "This " + router.OIDCName + " account is already linked to a microlog account."
+	default:
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":      ctx.UserID,
+			"subject": subject,
+		}).WithError(err).Error("failed to link external identity")
+		ctx.ErrorMessage = "Unexpected internal error, please try again."
+	}
+	router.renderIdentities(w, r, ctx, "")
+}
+
+// authenticatedExternalLogin returns the login of an unknown account waiting for a username.
+func (router *Router) authenticatedExternalLogin(r *http.Request) (*models.ExternalLogin, bool) {
+	cookie, err := r.Cookie(externalLoginCookie)
+	if err != nil {
+		return nil, false
+	}
+	login, err := router.Data.ExternalLogin(cookie.Value)
+	if err != nil || login.Subject == "" || login.UserID != 0 {
+		return nil, false
+	}
+	return login, true
+}
+
+func (router *Router) externalSignup(w http.ResponseWriter, r *http.Request) {
+	login, ok := router.authenticatedExternalLogin(r)
+	if !ok {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	router.render(externalSignupTemplate, w, externalSignupContext{
+		Context: *router.defaultContext(r),
+		Name:    login.Name,
+		Email:   login.Email,
+	})
+}
+
+// externalSignupSubmit creates the account of a user signing in with an unknown external account.
+func (router *Router) externalSignupSubmit(w http.ResponseWriter, r *http.Request) {
+	login, ok := router.authenticatedExternalLogin(r)
+	if !ok {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	var (
+		name      = r.FormValue("username")
+		acceptTOS = r.FormValue("accept_tos") == "on"
+		ctx       = externalSignupContext{
+			Context:   *router.defaultContext(r),
+			Name:      name,
+			Email:     login.Email,
+			AcceptTOS: acceptTOS,
+		}
+	)
+	if !acceptTOS {
+		ctx.ErrorMessage = "You have to accept the Terms of Service and Privacy Policy."
+	} else if !router.Data.ValidateName(name) {
+		ctx.ErrorMessage = "Username must only consist of lowercase alphanumerics."
+	} else if router.Data.NameExists(name) {
+		ctx.ErrorMessage = "Name already exists."
+	}
+	if ctx.ErrorMessage != "" {
+		router.render(externalSignupTemplate, w, ctx)
+		return
+	}
+	// Use up the login first, so that concurrent submissions create a single user
+	if !router.Data.DeleteExternalLogin(login.ID) {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	clearExternalLogin(w)
+	userID, err := router.Data.AddExternalUser(name, login.Issuer, login.Subject, login.Email, login.EmailVerified)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"name":    name,
+			"subject": login.Subject,
+		}).WithError(err).Error("failed to add external user")
+		router.renderExternalError(w, r, "Your account could not be created, please try again.")
+		return
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":      userID,
+		"name":    name,
+		"subject": login.Subject,
+	}).Info("external signup")
+	router.startSession(w, r, userID, false)
+}
+
+func (router *Router) externalUnlink(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	id, _ := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	switch err := router.Data.UnlinkExternalIdentity(ctx.UserID, uint(id)); err {
+	case nil:
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":       ctx.UserID,
+			"external": id,
+		}).Info("unlinked external identity")
+		http.Redirect(w, r, "/profile/identities", http.StatusSeeOther)
+		return
+	case models.ErrLastSignInMethod:
+		ctx.ErrorMessage = "Add a confirmed email address before unlinking your last account."
+	default:
+		ctx.ErrorMessage = "Account could not be unlinked."
+	}
+	router.renderIdentities(w, r, ctx, "")
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// setFollow ensures that the signed-in user follows the user referenced in the request, if follow is set.
+func (router *Router) setFollow(w http.ResponseWriter, r *http.Request, follow bool) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	name := mux.Vars(r)["user"]
+	user, err := router.Data.UserByName(name)
+	if err != nil {
+		router.renderNotFound(w, r, "profile")
+		return
+	}
+	if user.ID == ctx.UserID {
+		http.Redirect(w, r, "/"+user.Name, http.StatusSeeOther)
+		return
+	}
+	if follow {
+		err = router.Data.Follow(ctx.UserID, user.ID)
+	} else {
+		err = router.Data.Unfollow(ctx.UserID, user.ID)
+	}
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":       ctx.UserID,
+			"followee": user.ID,
+		}).WithError(err).Error("failed to change follow")
+	} else {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":       ctx.UserID,
+			"followee": user.ID,
+			"follow":   follow,
+		}).Debug("changed follow")
+	}
+	http.Redirect(w, r, "/"+user.Name, http.StatusSeeOther)
+}
+
+func (router *Router) follow(w http.ResponseWriter, r *http.Request) {
+	router.setFollow(w, r, true)
+}
+
+func (router *Router) unfollow(w http.ResponseWriter, r *http.Request) {
+	router.setFollow(w, r, false)
+}
@@ -0,0 +1,299 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+type identityEntry struct {
+	Email     string
+	Confirmed bool
+	Primary   bool
+}
+
+type identitiesContext struct {
+	Context
+	Identities []identityEntry
+	External   []models.ExternalIdentity
+	MailID     string
+}
+
+// renderIdentities lists the identities of the signed in user.
+// The message is shown above the list, the mail ID links to the delivery status of a sent confirmation.
+func (router *Router) renderIdentities(w http.ResponseWriter, r *http.Request, ctx *Context, mailID string) {
+	idCtx := identitiesContext{
+		Context: *ctx,
+		MailID:  mailID,
+	}
+	identities, err := router.Data.Identities(ctx.UserID)
+	if err == nil {
+		idCtx.External, err = router.Data.ExternalIdentities(ctx.UserID)
+	}
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to list identities")
+		idCtx.ErrorMessage = "Unexpected internal error, please try again."
+		router.render(identitiesTemplate, w, idCtx)
+		return
+	}
+	// Users signing in with an external identity may not have an email address
+	primary, _ := router.Data.PrimaryIdentity(ctx.UserID)
+	for _, identity := range identities {
+		idCtx.Identities = append(idCtx.Identities, identityEntry{
+			Email:     identity.Email,
+			Confirmed: identity.Confirmed,
+			Primary:   primary != nil && identity.ID == primary.ID,
+		})
+	}
+	router.render(identitiesTemplate, w, idCtx)
+}
+
+func (router *Router) identities(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	router.renderIdentities(w, r, ctx, "")
+}
+
+func (router *Router) identitiesAdd(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	var (
+		email    = r.FormValue("email")
+		password = r.FormValue("password")
+	)
+	if !router.Data.CheckPassword(ctx.UserID, []byte(password)) {
+		ctx.ErrorMessage = "Wrong password."
+	} else if !router.Data.ValidateEmail(email) {
+		ctx.ErrorMessage = "Email must be an egligible email address."
+	} else if router.Data.EmailExists(email) {
+		ctx.ErrorMessage = "Email already exists."
+	} else if err := router.Data.AddIdentity(ctx.UserID, email); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":    ctx.UserID,
+			"email": email,
+		}).WithError(err).Error("failed to add identity")
+		ctx.ErrorMessage = "Unexpected internal error, please try again."
+	}
+	if ctx.ErrorMessage != "" {
+		router.renderIdentities(w, r, ctx, "")
+		return
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":    ctx.UserID,
+		"email": email,
+	}).Info("added identity")
+	router.sendIdentityConfirmation(w, r, ctx, email)
+}
+
+func (router *Router) identitiesResend(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	email := r.FormValue("email")
+	identity, err := router.Data.IdentityByEmail(email)
+	if err != nil || identity.UserID != ctx.UserID || identity.Confirmed {
+		http.Redirect(w, r, "/profile/identities", http.StatusSeeOther)
+		return
+	}
+	router.sendIdentityConfirmation(w, r, ctx, email)
+}
+
+// sendIdentityConfirmation sends a confirmation link to the identity and shows the delivery status.
+func (router *Router) sendIdentityConfirmation(w http.ResponseWriter, r *http.Request, ctx *Context, email string) {
+	mailID, err := router.Email.SendConfirmation(r.Context(), ctx.UserID, email)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":    ctx.UserID,
+			"email": email,
+		}).WithError(err).Error("failed to send confirmation email")
+		ctx.ErrorMessage = "The confirmation email could not be sent, please try again."
+		router.renderIdentities(w, r, ctx, "")
+		return
+	}
+	ctx.ErrorMessage = "We sent a confirmation link to " + email + "."
+	router.renderIdentities(w, r, ctx, mailID)
+}
+
+func (router *Router) identitiesPrimary(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	email := r.FormValue("email")
+	if err := router.Data.SetPrimaryIdentity(ctx.UserID, email); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":    ctx.UserID,
+			"email": email,
+		}).WithError(err).Debug("failed to set primary identity")
+		ctx.ErrorMessage = "Only confirmed addresses can be made primary."
+		router.renderIdentities(w, r, ctx, "")
+		return
+	}
+	http.Redirect(w, r, "/profile/identities", http.StatusSeeOther)
+}
+
+func (router *Router) identitiesRemove(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	email := r.FormValue("email")
+	switch err := router.Data.RemoveIdentity(ctx.UserID, email); err {
+	case nil:
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":    ctx.UserID,
+			"email": email,
+		}).Info("removed identity")
+		http.Redirect(w, r, "/profile/identities", http.StatusSeeOther)
+		return
+	case models.ErrIdentityPrimary:
+		ctx.ErrorMessage = "Make another address primary before removing this one."
+	case models.ErrLastConfirmedIdentity:
+		ctx.ErrorMessage = "You can not remove your last confirmed address."
+	default:
+		ctx.ErrorMessage = "Address could not be removed."
+	}
+	router.renderIdentities(w, r, ctx, "")
+}
+
+func (router *Router) identitiesChange(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	var (
+		email    = r.FormValue("email")
+		newEmail = r.FormValue("new_email")
+		password = r.FormValue("password")
+	)
+	identity, err := router.Data.IdentityByEmail(email)
+	if err != nil || identity.UserID != ctx.UserID || !identity.Confirmed {
+		ctx.ErrorMessage = "Only confirmed addresses can be changed."
+	} else if !router.Data.CheckPassword(ctx.UserID, []byte(password)) {
+		ctx.ErrorMessage = "Wrong password."
+	} else if !router.Data.ValidateEmail(newEmail) {
+		ctx.ErrorMessage = "Email must be an egligible email address."
+	} else if router.Data.EmailExists(newEmail) {
+		ctx.ErrorMessage = "Email already exists."
+	}
+	if ctx.ErrorMessage != "" {
+		router.renderIdentities(w, r, ctx, "")
+		return
+	}
+	mailID, err := router.Email.SendEmailChange(r.Context(), ctx.UserID, email, newEmail)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":       ctx.UserID,
+			"email":    newEmail,
+			"previous": email,
+		}).WithError(err).Error("failed to send email change")
+		ctx.ErrorMessage = "The confirmation email could not be sent, please try again."
+		router.renderIdentities(w, r, ctx, "")
+		return
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":       ctx.UserID,
+		"email":    newEmail,
+		"previous": email,
+	}).Info("requested email change")
+	ctx.ErrorMessage = "We sent a confirmation link to " + newEmail + ", the address changes once you confirm it."
+	router.renderIdentities(w, r, ctx, mailID)
+}
+
+type emailChangeContext struct {
+	Context
+	Success bool
+	Undo    bool
+}
+
+// emailChange swaps the address of an identity once the new address has been confirmed.
+func (router *Router) emailChange(w http.ResponseWriter, r *http.Request) {
+	ctx := emailChangeContext{
+		Context: *router.defaultContext(r),
+	}
+	email, previous, userID, err := router.Email.ConsumeEmailChangeToken(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		log.WithRequest(r).WithError(err).WithFields(logrus.Fields{
+			"type": "invalid token",
+		}).Debug("attempt to change email")
+		router.render(emailChangeTemplate, w, ctx)
+		return
+	}
+	if err := router.Data.ChangeIdentityEmail(userID, previous, email); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":       userID,
+			"email":    email,
+			"previous": previous,
+		}).WithError(err).Warn("failed to change email")
+		router.render(emailChangeTemplate, w, ctx)
+		return
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":       userID,
+		"email":    email,
+		"previous": previous,
+	}).Info("changed email")
+	ctx.Success = true
+	router.render(emailChangeTemplate, w, ctx)
+}
+
+// emailChangeUndo restores the previous address of an identity, whether or not the change has been confirmed.
+// Since the change may not have been requested by the user, all sessions and outstanding email links are revoked.
+// The link keeps working after a password change or reset, since those do not revoke it.
+func (router *Router) emailChangeUndo(w http.ResponseWriter, r *http.Request) {
+	ctx := emailChangeContext{
+		Context: *router.defaultContext(r),
+		Undo:    true,
+	}
+	previous, email, userID, err := router.Email.ConsumeEmailChangeUndoToken(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		log.WithRequest(r).WithError(err).WithFields(logrus.Fields{
+			"type": "invalid token",
+		}).Debug("attempt to undo email change")
+		router.render(emailChangeTemplate, w, ctx)
+		return
+	}
+	// The change has not been applied if the identity still uses the previous address
+	if identity, err := router.Data.IdentityByEmail(previous); err != nil || identity.UserID != userID {
+		if err := router.Data.ChangeIdentityEmail(userID, email, previous); err != nil {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"id":       userID,
+				"email":    email,
+				"previous": previous,
+			}).WithError(err).Warn("failed to undo email change")
+			router.render(emailChangeTemplate, w, ctx)
+			return
+		}
+	}
+	if err := router.Email.RevokeTokens(r.Context(), userID); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": userID,
+		}).WithError(err).Error("failed to revoke email tokens")
+	}
+	if err := router.Session.DeleteAll(r.Context(), userID, ""); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": userID,
+		}).WithError(err).Error("failed to revoke sessions")
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":       userID,
+		"email":    email,
+		"previous": previous,
+	}).Info("undid email change")
+	ctx.Success = true
+	router.render(emailChangeTemplate, w, ctx)
+}
@@ -10,6 +10,26 @@ func (router *Router) Error(w http.ResponseWriter, r *http.Request, msg string,
 	http.Error(w, msg, status)
 }
 
+// moderatorContext returns the context of a request made by a moderator.
+// It responds with an error or redirects to the two-factor enrollment and returns nil if the request may not moderate.
+func (router *Router) moderatorContext(w http.ResponseWriter, r *http.Request) *Context {
+	ctx := router.defaultContext(r)
+	if !ctx.Moderator {
+		router.Error(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	if !router.mayModerate(ctx) {
+		http.Redirect(w, r, "/profile/2fa?required=moderate", http.StatusSeeOther)
+		return nil
+	}
+	return ctx
+}
+
+// mayModerate checks if the moderator has enabled two-factor authentication, if moderation requires it.
+func (router *Router) mayModerate(ctx *Context) bool {
+	return ctx.Moderator && (!router.ModeratorTwoFactor || router.Data.TwoFactorEnabled(ctx.UserID))
+}
+
 type moderationReport struct {
 	ID        uint
 	PostTitle string
@@ -18,6 +38,8 @@ type moderationReport struct {
 	Reporter  string
 	Reason    string
 	Status    bool
+	Deleted   bool
+	Revisions int
 }
 
 type moderationContext struct {
@@ -26,9 +48,8 @@ type moderationContext struct {
 }
 
 func (router *Router) ModerateDelete(w http.ResponseWriter, r *http.Request) {
-	ctx := router.defaultContext(r)
-	if !ctx.Moderator {
-		router.Error(w, r, "Unauthorized", http.StatusUnauthorized)
+	ctx := router.moderatorContext(w, r)
+	if ctx == nil {
 		return
 	}
 	reportID, err := strconv.Atoi(mux.Vars(r)["report"])
@@ -58,9 +79,8 @@ func (router *Router) ModerateDelete(w http.ResponseWriter, r *http.Request) {
 }
 
 func (router *Router) ModerateClose(w http.ResponseWriter, r *http.Request) {
-	ctx := router.defaultContext(r)
-	if !ctx.Moderator {
-		router.Error(w, r, "Unauthorized", http.StatusUnauthorized)
+	ctx := router.moderatorContext(w, r)
+	if ctx == nil {
 		return
 	}
 	reportID, err := strconv.Atoi(mux.Vars(r)["report"])
@@ -76,9 +96,8 @@ func (router *Router) ModerateClose(w http.ResponseWriter, r *http.Request) {
 }
 
 func (router *Router) Moderate(w http.ResponseWriter, r *http.Request) {
-	ctx := router.defaultContext(r)
-	if !ctx.Moderator {
-		router.Error(w, r, "Unauthorized", http.StatusUnauthorized)
+	ctx := router.moderatorContext(w, r)
+	if ctx == nil {
 		return
 	}
 	reports, err := router.Data.Reports()
@@ -95,7 +114,8 @@ func (router *Router) Moderate(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			continue
 		}
-		post, err := router.Data.Post(report.PostID)
+		// Reports on deleted posts are kept, so that their revisions can still be reviewed
+		post, err := router.Data.PostWithDeleted(report.PostID)
 		if err != nil {
 			continue
 		}
@@ -103,14 +123,22 @@ func (router *Router) Moderate(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			continue
 		}
+		title := post.Title
+		if post.ParentID != 0 {
+			title = "Comment #" + strconv.FormatUint(uint64(post.ID), 10)
+		}
 		modContext.Reports[index] = moderationReport{
 			ID:        report.ID,
 			Reason:    report.Reason,
 			Reporter:  reporter.Name,
 			PostUser:  user.Name,
 			PostID:    post.ID,
-			PostTitle: post.Title,
+			PostTitle: title,
 			Status:    report.Open,
+			Deleted:   post.DeletedAt != nil,
+		}
+		if post.ParentID == 0 {
+			modContext.Reports[index].Revisions, _ = router.Data.NumberOfRevisions(post.ID)
 		}
 	}
 	router.render(moderationTemplate, w, modContext)
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+type passwordContext struct {
+	Context
+	Success bool
+}
+
+func (router *Router) password(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	router.render(passwordTemplate, w, passwordContext{Context: *ctx})
+}
+
+// passwordSubmit changes the password of the signed in user after checking the current one.
+// Other sessions and outstanding email links are revoked and the user is notified by email.
+func (router *Router) passwordSubmit(w http.ResponseWriter, r *http.Request) {
+	ctx := passwordContext{
+		Context: *router.defaultContext(r),
+	}
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	primary, err := router.Data.PrimaryIdentity(ctx.UserID)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to find primary identity")
+		ctx.ErrorMessage = "Unexpected internal error, please try again."
+		router.render(passwordTemplate, w, ctx)
+		return
+	}
+	// Guessing the current password is throttled with the login rules, but counted separately,
+	// so that a session guessing wrong does not lock the owner out of logging in
+	if lockout := router.throttle(r, "password", primary.Email, router.RateLimits.Login, router.RateLimits.LoginHost); lockout > 0 {
+		router.renderRateLimited(w, r, lockout)
+		return
+	}
+	var (
+		current         = r.FormValue("current_password")
+		password        = r.FormValue("password")
+		passwordConfirm = r.FormValue("password_confirm")
+	)
+	if !router.Data.CheckPassword(ctx.UserID, []byte(current)) {
+		ctx.ErrorMessage = "Wrong password."
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   ctx.UserID,
+			"type": "wrong password",
+		}).Debug("attempt to change password")
+		router.render(passwordTemplate, w, ctx)
+		return
+	}
+	router.resetThrottle(r, "password", primary.Email)
+	if password != passwordConfirm {
+		ctx.ErrorMessage = "Passwords do not match."
+	} else if !router.Data.ValidatePassword(password) {
+		ctx.ErrorMessage = "Password must have a minimum length of 8 characters."
+	} else if router.Data.CommonPassword(password) {
+		ctx.ErrorMessage = "Password is too common, please choose another one."
+	}
+	if ctx.ErrorMessage != "" {
+		router.render(passwordTemplate, w, ctx)
+		return
+	}
+	if err := router.Data.ResetPassword(ctx.UserID, primary.Email, []byte(password)); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to change password")
+		ctx.ErrorMessage = "Unexpected internal error, please try again."
+		router.render(passwordTemplate, w, ctx)
+		return
+	}
+	// Outstanding reset links must not work with the new password in place.
+	if err := router.Email.RevokeTokens(r.Context(), ctx.UserID); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to revoke email tokens")
+	}
+	// Whoever knew the old password may still be signed in elsewhere.
+	if err := router.Session.DeleteAll(r.Context(), ctx.UserID, ctx.SessionID); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to revoke sessions")
+	}
+	if _, err := router.Email.SendPasswordChanged(r.Context(), ctx.UserID, primary.Email); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":    ctx.UserID,
+			"email": primary.Email,
+		}).WithError(err).Error("failed to send password change notice")
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id": ctx.UserID,
+	}).Info("changed password")
+	ctx.Success = true
+	router.render(passwordTemplate, w, ctx)
+}
@@ -5,24 +5,70 @@ import (
 	"html/template"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/dustin/go-humanize"
 	"github.com/gorilla/mux"
+	"github.com/lnsp/microlog/gateway/internal/models"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/russross/blackfriday"
 	"github.com/sirupsen/logrus"
 )
 
-type postContext struct {
-	Context
+const (
+	commentsPageSize = 20
+	// commentsMaxDepth is the number of comment levels shown on a post page.
+	commentsMaxDepth = 2
+	// commentsRepliesLimit is the number of replies shown below a comment.
+	commentsRepliesLimit = 3
+)
+
+var sanitizer = bluemonday.UGCPolicy()
+
+// renderMarkdown renders the given markdown source to sanitized HTML.
+func renderMarkdown(content string) template.HTML {
+	rendered := blackfriday.MarkdownCommon([]byte(content))
+	return template.HTML(sanitizer.SanitizeBytes(rendered))
+}
+
+type postComment struct {
 	ID          uint
 	Author      string
-	Title       string
-	Content     string
+	Avatar      string
 	HTMLContent template.HTML
 	Date        string
 	Self        bool
 	Liked       bool
 	LikeCount   int
+	Replies     []postComment
+	MoreReplies bool
+}
+
+type postContext struct {
+	Context
+	ID            uint
+	Author        string
+	Avatar        string
+	Title         string
+	Content       string
+	HTMLContent   template.HTML
+	Tags          []string
+	TagInput      string
+	Date          string
+	Edited        string
+	State         string
+	PublishAt     string
+	PublishOffset int
+	Self          bool
+	Liked         bool
+	LikeCount     int
+	ParentID      uint
+	ParentAuthor  string
+	Comments      []postComment
+	CommentCount  int
+	Page          int
+	PrevPage      int
+	NextPage      int
 }
 
 func (router *Router) postRedirect(w http.ResponseWriter, r *http.Request) {
@@ -32,6 +78,12 @@ func (router *Router) postRedirect(w http.ResponseWriter, r *http.Request) {
 
 func (router *Router) postDelete(w http.ResponseWriter, r *http.Request) {
 	ctx := router.postContext(r)
+	redirect := "/"
+	if ctx.ParentID != 0 {
+		if url, err := router.threadURL(ctx.ParentID); err == nil {
+			redirect = url
+		}
+	}
 	if err := router.Data.DeletePost(ctx.UserID, ctx.ID); err != nil {
 		log.WithRequest(r).WithFields(logrus.Fields{
 			"id":   ctx.UserID,
@@ -40,7 +92,7 @@ func (router *Router) postDelete(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
 		return
 	}
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
 }
 
 func (router *Router) postNew(w http.ResponseWriter, r *http.Request) {
@@ -60,15 +112,24 @@ func (router *Router) postEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	postCtx := router.postContext(r)
+	if postCtx.ParentID != 0 {
+		http.Redirect(w, r, fmt.Sprintf("/%s/%d/", postCtx.Author, postCtx.ID), http.StatusSeeOther)
+		return
+	}
 	router.render(postEditTemplate, w, postCtx)
 }
 
 func (router *Router) postSubmit(w http.ResponseWriter, r *http.Request) {
 	var (
-		postID  = r.FormValue("id")
-		title   = r.FormValue("title")
-		content = r.FormValue("content")
+		postID    = r.FormValue("id")
+		title     = r.FormValue("title")
+		content   = r.FormValue("content")
+		tagInput  = r.FormValue("tags")
+		tags      = models.ParseTags(tagInput)
+		action    = r.FormValue("action")
+		publishAt = r.FormValue("publish_at")
 	)
+	publishOffset, _ := strconv.Atoi(r.FormValue("publish_offset"))
 	ctx := router.defaultContext(r)
 	if !ctx.SignedIn {
 		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
@@ -86,7 +147,7 @@ func (router *Router) postSubmit(w http.ResponseWriter, r *http.Request) {
 	if postID != "" {
 		id, _ := strconv.ParseUint(postID, 10, 64)
 		post, err := router.Data.Post(uint(id))
-		if err != nil {
+		if err != nil || (!post.Published() && post.UserID != user.ID) {
 			log.WithRequest(r).WithFields(logrus.Fields{
 				"id":   ctx.UserID,
 				"post": id,
@@ -95,21 +156,32 @@ func (router *Router) postSubmit(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		postCtx := postContext{
-			Context: *ctx,
-			Author:  user.Name,
-			Title:   title,
-			Content: content,
+			Context:       *ctx,
+			ID:            post.ID,
+			Author:        user.Name,
+			Title:         title,
+			Content:       content,
+			TagInput:      tagInput,
+			State:         post.State,
+			PublishAt:     publishAt,
+			PublishOffset: publishOffset,
+		}
+		if !post.Published() {
+			router.draftSubmit(w, r, postCtx, action, tags)
+			return
 		}
 		if !router.Data.ValidatePostTitle(title) {
 			postCtx.ErrorMessage = "Your title must have at max 80 characters."
 		} else if !router.Data.ValidatePostContent(content) {
 			postCtx.ErrorMessage = "Your content must have at max 80000 characters."
+		} else if !router.Data.ValidatePostTags(content, tags) {
+			postCtx.ErrorMessage = "Your post can have at max 10 tags of at max 32 letters, digits or underscores."
 		}
 		if postCtx.ErrorMessage != "" {
 			router.render(postEditTemplate, w, postCtx)
 			return
 		}
-		if err := router.Data.UpdatePost(user.ID, post.ID, title, content); err != nil {
+		if err := router.Data.UpdatePost(user.ID, post.ID, title, content, tags); err != nil {
 			postCtx.ErrorMessage = "Unexpected internal error, please try again."
 			log.WithRequest(r).WithFields(logrus.Fields{
 				"id":   user.ID,
@@ -125,21 +197,30 @@ func (router *Router) postSubmit(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, fmt.Sprintf("/%s/%d/", user.Name, id), http.StatusSeeOther)
 	} else {
 		postCtx := postContext{
-			Context: *ctx,
-			Author:  user.Name,
-			Title:   title,
-			Content: content,
+			Context:       *ctx,
+			Author:        user.Name,
+			Title:         title,
+			Content:       content,
+			TagInput:      tagInput,
+			PublishAt:     publishAt,
+			PublishOffset: publishOffset,
+		}
+		if action == postActionDraft || action == postActionSchedule {
+			router.draftSubmit(w, r, postCtx, action, tags)
+			return
 		}
 		if !router.Data.ValidatePostTitle(title) {
 			postCtx.ErrorMessage = "Your title must have at max 80 characters."
 		} else if !router.Data.ValidatePostContent(content) {
 			postCtx.ErrorMessage = "Your content must have at max 80000 characters."
+		} else if !router.Data.ValidatePostTags(content, tags) {
+			postCtx.ErrorMessage = "Your post can have at max 10 tags of at max 32 letters, digits or underscores."
 		}
 		if postCtx.ErrorMessage != "" {
 			router.render(postEditTemplate, w, postCtx)
 			return
 		}
-		id, err := router.Data.AddPost(user.ID, title, content)
+		id, err := router.Data.AddPost(user.ID, title, content, tags)
 		if err != nil {
 			log.WithRequest(r).WithFields(logrus.Fields{
 				"id": user.ID,
@@ -160,7 +241,7 @@ func (router *Router) postSubmit(w http.ResponseWriter, r *http.Request) {
 func (router *Router) postContextWithID(r *http.Request, username string, id uint) postContext {
 	ctx := router.defaultContext(r)
 	post, err := router.Data.Post(id)
-	if err != nil {
+	if err != nil || (!post.Published() && !(ctx.SignedIn && ctx.UserID == post.UserID)) {
 		ctx.ErrorMessage = "Post does not exist."
 	}
 	user, err := router.Data.UserByName(username)
@@ -177,20 +258,216 @@ func (router *Router) postContextWithID(r *http.Request, username string, id uin
 		ctx.ErrorMessage = "Number of likes missing."
 		likes = 0
 	}
-	rendered := blackfriday.MarkdownCommon([]byte(post.Content))
-	safe := bluemonday.UGCPolicy().SanitizeBytes(rendered)
-	return postContext{
-		Context:     *ctx,
-		Self:        ctx.SignedIn && ctx.UserID == post.UserID,
-		Author:      user.Name,
-		ID:          post.ID,
-		Title:       post.Title,
-		Content:     post.Content,
-		HTMLContent: template.HTML(safe),
-		Date:        post.CreatedAt.Format(timeFormat),
-		Liked:       ctx.SignedIn && router.Data.HasLiked(ctx.UserID, post.ID),
-		LikeCount:   likes,
+	comments, err := router.Data.NumberOfComments(id)
+	if err != nil {
+		ctx.ErrorMessage = "Number of comments missing."
+		comments = 0
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 || (page-1)*commentsPageSize >= comments {
+		page = 1
+	}
+	postCtx := postContext{
+		Context:      *ctx,
+		Self:         ctx.SignedIn && ctx.UserID == post.UserID,
+		Author:       user.Name,
+		Avatar:       router.avatar(r.Context(), user.ID),
+		ID:           post.ID,
+		Title:        post.Title,
+		Content:      post.Content,
+		HTMLContent:  renderMarkdown(post.Content),
+		Date:         post.CreatedAt.Format(timeFormat),
+		State:        post.State,
+		Liked:        ctx.SignedIn && router.Data.HasLiked(ctx.UserID, post.ID),
+		LikeCount:    likes,
+		ParentID:     post.ParentID,
+		CommentCount: comments,
+		Page:         page,
+	}
+	if post.ParentID == 0 {
+		tags, err := router.Data.PostTags(post.ID)
+		if err != nil {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"post": post.ID,
+			}).WithError(err).Error("failed to fetch tags")
+		}
+		postCtx.Tags = tags
+		postCtx.TagInput = strings.Join(tags, ", ")
+		if edited, ok := router.Data.LastEdit(post.ID); ok {
+			postCtx.Edited = edited.Format(timeFormat)
+		}
+		if post.PublishAt != nil {
+			postCtx.PublishAt = post.PublishAt.UTC().Format(publishAtFormat)
+		}
+	}
+	if page > 1 {
+		postCtx.PrevPage = page - 1
+	}
+	if page*commentsPageSize < comments {
+		postCtx.NextPage = page + 1
+	}
+	if post.ParentID != 0 {
+		parent, err := router.Data.Post(post.ParentID)
+		if err == nil {
+			parentAuthor, err := router.Data.User(parent.UserID)
+			if err == nil {
+				postCtx.ParentAuthor = parentAuthor.Name
+			}
+		}
+	}
+	postCtx.Comments = router.comments(r, ctx, post.ID, (page-1)*commentsPageSize)
+	return postCtx
+}
+
+// comments fetches a page of comments on the given post along with their replies.
+// Each level of replies is loaded in batches, so that the number of queries does not grow with the number of comments.
+// Replies nested deeper than commentsMaxDepth or beyond commentsRepliesLimit are not fetched, instead MoreReplies is set on their parent.
+func (router *Router) comments(r *http.Request, ctx *Context, id uint, offset int) []postComment {
+	comments, err := router.Data.CommentsOn(id, offset, commentsPageSize)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   ctx.UserID,
+			"post": id,
+		}).WithError(err).Error("failed to fetch comments")
+		return nil
+	}
+	var (
+		replies = make(map[uint][]models.Post)
+		all     = comments
+		level   = comments
+	)
+	for depth := 1; depth < commentsMaxDepth && len(level) > 0; depth++ {
+		next, err := router.Data.RepliesOn(postIDs(level), commentsRepliesLimit)
+		if err != nil {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"id":   ctx.UserID,
+				"post": id,
+			}).WithError(err).Error("failed to fetch replies")
+			break
+		}
+		for _, reply := range next {
+			replies[reply.ParentID] = append(replies[reply.ParentID], reply)
+		}
+		level = next
+		all = append(all, next...)
+	}
+	thread := commentThread{
+		router:  router,
+		ctx:     ctx,
+		replies: replies,
+	}
+	if err := thread.load(r, all); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   ctx.UserID,
+			"post": id,
+		}).WithError(err).Error("failed to fetch comment details")
+		return nil
 	}
+	return thread.build(r, comments)
+}
+
+// commentThread holds the details of the comments shown on a post page.
+type commentThread struct {
+	router   *Router
+	ctx      *Context
+	replies  map[uint][]models.Post
+	authors  map[uint]models.User
+	avatars  map[uint]string
+	likes    map[uint]int
+	comments map[uint]int
+	liked    map[uint]bool
+}
+
+// load fetches the authors and their avatars, like and reply counts and the likes of the viewer for the given comments.
+func (thread *commentThread) load(r *http.Request, comments []models.Post) error {
+	var (
+		data    = thread.router.Data
+		ids     = postIDs(comments)
+		authors = postAuthors(comments)
+		err     error
+	)
+	if thread.likes, err = data.LikeCounts(ids); err != nil {
+		return err
+	}
+	if thread.comments, err = data.CommentCounts(ids); err != nil {
+		return err
+	}
+	thread.liked = make(map[uint]bool)
+	if thread.ctx.SignedIn {
+		if thread.liked, err = data.LikedPosts(thread.ctx.UserID, ids); err != nil {
+			return err
+		}
+	}
+	if thread.authors, err = data.UsersByID(authors); err != nil {
+		return err
+	}
+	thread.avatars = thread.router.Profile.Thumbnails(r.Context(), authors)
+	return nil
+}
+
+// build converts the given comments and their loaded replies.
+func (thread *commentThread) build(r *http.Request, comments []models.Post) []postComment {
+	result := make([]postComment, 0, len(comments))
+	for _, comment := range comments {
+		author, ok := thread.authors[comment.UserID]
+		if !ok {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"id":      thread.ctx.UserID,
+				"comment": comment.ID,
+			}).Error("failed to fetch comment author")
+			continue
+		}
+		commentCtx := postComment{
+			ID:          comment.ID,
+			Author:      author.Name,
+			Avatar:      thread.avatars[author.ID],
+			HTMLContent: renderMarkdown(comment.Content),
+			Date:        humanize.Time(comment.CreatedAt),
+			Self:        thread.ctx.SignedIn && thread.ctx.UserID == comment.UserID,
+			Liked:       thread.liked[comment.ID],
+			LikeCount:   thread.likes[comment.ID],
+		}
+		replies := thread.replies[comment.ID]
+		commentCtx.Replies = thread.build(r, replies)
+		commentCtx.MoreReplies = thread.comments[comment.ID] > len(replies)
+		result = append(result, commentCtx)
+	}
+	return result
+}
+
+// postIDs returns the IDs of the given posts.
+func postIDs(posts []models.Post) []uint {
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	return ids
+}
+
+// postAuthors returns the IDs of the authors of the given posts.
+func postAuthors(posts []models.Post) []uint {
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].UserID
+	}
+	return ids
+}
+
+// threadURL returns the URL of the top-level post the given post or comment belongs to.
+// Comments are referenced using an anchor on the post page.
+func (router *Router) threadURL(id uint) (string, error) {
+	root, err := router.Data.ThreadRoot(id)
+	if err != nil {
+		return "", err
+	}
+	author, err := router.Data.User(root.UserID)
+	if err != nil {
+		return "", err
+	}
+	if root.ID == id {
+		return fmt.Sprintf("/%s/%d/", author.Name, root.ID), nil
+	}
+	return fmt.Sprintf("/%s/%d/#comment-%d", author.Name, root.ID, id), nil
 }
 
 func (router *Router) postContext(r *http.Request) postContext {
@@ -248,6 +525,10 @@ func (router *Router) like(w http.ResponseWriter, r *http.Request) {
 	author := vars["user"]
 	post := vars["post"]
 	postID, _ := strconv.ParseUint(post, 10, 64)
+	if target, err := router.Data.Post(uint(postID)); err != nil || !target.Published() {
+		router.renderNotFound(w, r, "post")
+		return
+	}
 	if err := router.Data.ToggleLike(ctx.UserID, uint(postID)); err != nil {
 		log.WithRequest(r).WithFields(logrus.Fields{
 			"id":   ctx.UserID,
@@ -259,5 +540,47 @@ func (router *Router) like(w http.ResponseWriter, r *http.Request) {
 		"id":   ctx.UserID,
 		"post": postID,
 	}).Debug("toggled like")
-	http.Redirect(w, r, fmt.Sprintf("/%s/%s/", author, post), http.StatusSeeOther)
+	redirect, err := router.threadURL(uint(postID))
+	if err != nil {
+		redirect = fmt.Sprintf("/%s/%s/", author, post)
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+func (router *Router) commentSubmit(w http.ResponseWriter, r *http.Request) {
+	ctx := router.postContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	if ctx.ID == 0 || ctx.State != models.PostPublished {
+		router.renderNotFound(w, r, "post")
+		return
+	}
+	content := r.FormValue("content")
+	if !router.Data.ValidateComment(content) {
+		ctx.ErrorMessage = "Your comment must not be empty and have at max 4000 characters."
+		router.render(postTemplate, w, ctx)
+		return
+	}
+	id, err := router.Data.AddComment(ctx.UserID, ctx.ID, content)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   ctx.UserID,
+			"post": ctx.ID,
+		}).WithError(err).Error("failed to add comment")
+		ctx.ErrorMessage = "Unexpected internal error, please try again."
+		router.render(postTemplate, w, ctx)
+		return
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":      ctx.UserID,
+		"post":    ctx.ID,
+		"comment": id,
+	}).Debug("added comment")
+	redirect, err := router.threadURL(id)
+	if err != nil {
+		redirect = fmt.Sprintf("/%s/%d/", ctx.Author, ctx.ID)
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
 }
@@ -1,27 +1,43 @@
 package router
 
 import (
+	"context"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/lnsp/microlog/gateway/internal/profile"
 	"github.com/sirupsen/logrus"
 )
 
+// imageUploadMaxSize limits the request body of image uploads.
+// The profile service enforces its own, possibly lower, limit on the image itself.
+const imageUploadMaxSize = 8 << 20
+
 type profilePost struct {
-	Title  string
-	Date   string
-	Author string
-	ID     uint
+	Title     string
+	Date      string
+	Author    string
+	ID        uint
+	Scheduled string
 }
 
 type profileContext struct {
 	Context
 	Name        string
+	DisplayName string
 	Biography   string
+	ImageURL    string
 	MemberSince string
 	PostCount   int
 	Self        bool
 	Posts       []profilePost
+	Drafts      []profilePost
+	// Followers and Following count the users following the profile and followed by it.
+	Followers   int
+	Following   int
+	IsFollowing bool
 }
 
 func (router *Router) profileRedirect(w http.ResponseWriter, r *http.Request) {
@@ -60,16 +76,29 @@ func (router *Router) profile(w http.ResponseWriter, r *http.Request) {
 		router.renderNotFound(w, r, "profile")
 		return
 	}
+	details, err := router.profileDetails(r.Context(), user)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   user.ID,
+			"name": user.Name,
+		}).WithError(err).Error("failed to get profile")
+		details = &profile.Profile{ID: user.ID}
+	}
 	// TODO: Sort by specific topic order (date etc.)
 	ctx := router.defaultContext(r)
 	profileCtx := profileContext{
 		Context:     *ctx,
 		Name:        user.Name,
-		Biography:   user.Biography,
+		DisplayName: details.DisplayName,
+		Biography:   details.Biography,
+		ImageURL:    details.ImageURL,
 		MemberSince: user.CreatedAt.Format(timeFormat),
 		PostCount:   len(posts),
 		Self:        ctx.SignedIn && ctx.UserID == user.ID,
 		Posts:       make([]profilePost, len(posts)),
+		Followers:   router.Data.FollowerCount(user.ID),
+		Following:   router.Data.FollowingCount(user.ID),
+		IsFollowing: ctx.SignedIn && router.Data.IsFollowing(ctx.UserID, user.ID),
 	}
 	for i := range posts {
 		profileCtx.Posts[i] = profilePost{
@@ -79,9 +108,36 @@ func (router *Router) profile(w http.ResponseWriter, r *http.Request) {
 			ID:     posts[i].ID,
 		}
 	}
+	if profileCtx.Self {
+		profileCtx.Drafts = router.profileDrafts(r, user)
+	}
 	router.render(profileTemplate, w, profileCtx)
 }
 
+// profileDrafts lists the drafts and scheduled posts of the given user.
+func (router *Router) profileDrafts(r *http.Request, user *models.User) []profilePost {
+	drafts, err := router.Data.Drafts(user.ID)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": user.ID,
+		}).WithError(err).Error("failed to get drafts")
+		return nil
+	}
+	result := make([]profilePost, len(drafts))
+	for i := range drafts {
+		result[i] = profilePost{
+			Title:  drafts[i].Title,
+			Date:   drafts[i].UpdatedAt.Format(timeFormat),
+			Author: user.Name,
+			ID:     drafts[i].ID,
+		}
+		if drafts[i].PublishAt != nil {
+			result[i].Scheduled = drafts[i].PublishAt.UTC().Format(timeFormat)
+		}
+	}
+	return result
+}
+
 func (router *Router) profileEdit(w http.ResponseWriter, r *http.Request) {
 	ctx := router.defaultContext(r)
 	if !ctx.SignedIn {
@@ -96,10 +152,20 @@ func (router *Router) profileEdit(w http.ResponseWriter, r *http.Request) {
 		router.renderNotFound(w, r, "profile")
 		return
 	}
+	details, err := router.profileDetails(r.Context(), user)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to get profile")
+		router.renderNotFound(w, r, "profile")
+		return
+	}
 	profileCtx := profileContext{
-		Context:   *ctx,
-		Name:      user.Name,
-		Biography: user.Biography,
+		Context:     *ctx,
+		Name:        user.Name,
+		DisplayName: details.DisplayName,
+		Biography:   details.Biography,
+		ImageURL:    details.ImageURL,
 	}
 	router.render(profileEditTemplate, w, profileCtx)
 }
@@ -118,18 +184,35 @@ func (router *Router) profileEditSubmit(w http.ResponseWriter, r *http.Request)
 		router.renderNotFound(w, r, "profile")
 		return
 	}
-	biography := r.FormValue("biography")
+	var (
+		displayName = r.FormValue("display_name")
+		biography   = r.FormValue("biography")
+	)
 	profileCtx := profileContext{
-		Context:   *ctx,
-		Name:      user.Name,
-		Biography: biography,
+		Context:     *ctx,
+		Name:        user.Name,
+		DisplayName: displayName,
+		Biography:   biography,
 	}
 	if !router.Data.ValidateBiography(biography) {
 		profileCtx.ErrorMessage = "Your biography must have at max 240 characters."
 		router.render(profileEditTemplate, w, profileCtx)
 		return
 	}
-	if err := router.Data.UpdateBiography(ctx.UserID, biography); err != nil {
+	if _, err := router.Profile.UpdateDisplayName(r.Context(), ctx.UserID, displayName); err == profile.ErrInvalid {
+		profileCtx.ErrorMessage = "Your display name must have at max 48 characters."
+		router.render(profileEditTemplate, w, profileCtx)
+		return
+	} else if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   user.ID,
+			"name": user.Name,
+		}).WithError(err).Error("failed to update display name")
+		profileCtx.ErrorMessage = "Unexpected internal error, please try again."
+		router.render(profileEditTemplate, w, profileCtx)
+		return
+	}
+	if _, err := router.Profile.UpdateBiography(r.Context(), ctx.UserID, biography); err != nil {
 		log.WithRequest(r).WithFields(logrus.Fields{
 			"id":   user.ID,
 			"name": user.Name,
@@ -144,3 +227,90 @@ func (router *Router) profileEditSubmit(w http.ResponseWriter, r *http.Request)
 	}).Debug("updated user profile")
 	http.Redirect(w, r, "/"+user.Name, http.StatusSeeOther)
 }
+
+func (router *Router) profileImageSubmit(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	user, err := router.Data.User(ctx.UserID)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to find user")
+		router.renderNotFound(w, r, "profile")
+		return
+	}
+	details, err := router.profileDetails(r.Context(), user)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to get profile")
+		router.renderNotFound(w, r, "profile")
+		return
+	}
+	profileCtx := profileContext{
+		Context:     *ctx,
+		Name:        user.Name,
+		DisplayName: details.DisplayName,
+		Biography:   details.Biography,
+		ImageURL:    details.ImageURL,
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, imageUploadMaxSize)
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   user.ID,
+			"name": user.Name,
+		}).WithError(err).Debug("failed to read image upload")
+		profileCtx.ErrorMessage = "Please choose an image file of at max 8 MB."
+		router.render(profileEditTemplate, w, profileCtx)
+		return
+	}
+	defer file.Close()
+	image, err := ioutil.ReadAll(file)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   user.ID,
+			"name": user.Name,
+		}).WithError(err).Debug("failed to read image upload")
+		profileCtx.ErrorMessage = "Please choose an image file of at max 8 MB."
+		router.render(profileEditTemplate, w, profileCtx)
+		return
+	}
+	if _, err := router.Profile.UpdateImage(r.Context(), user.ID, image); err == profile.ErrInvalid {
+		profileCtx.ErrorMessage = "Your image must be a PNG, JPEG or GIF file of at max 2 MB."
+		router.render(profileEditTemplate, w, profileCtx)
+		return
+	} else if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":   user.ID,
+			"name": user.Name,
+		}).WithError(err).Error("failed to update image")
+		profileCtx.ErrorMessage = "Unexpected internal error, please try again."
+		router.render(profileEditTemplate, w, profileCtx)
+		return
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id":   user.ID,
+		"name": user.Name,
+	}).Debug("updated profile image")
+	http.Redirect(w, r, "/profile/edit", http.StatusSeeOther)
+}
+
+// profileDetails returns the profile of the given user.
+// Users without a profile yet get an empty one showing the biography stored in the gateway database.
+func (router *Router) profileDetails(ctx context.Context, user *models.User) (*profile.Profile, error) {
+	details, err := router.Profile.Get(ctx, user.ID)
+	if err == profile.ErrNotFound {
+		return &profile.Profile{ID: user.ID, Biography: user.Biography}, nil
+	}
+	return details, err
+}
+
+// avatar returns the thumbnail URL of the given user's profile image.
+// It returns an empty string if the user has no image or the profile is unavailable.
+func (router *Router) avatar(ctx context.Context, userID uint) string {
+	return router.Profile.Thumbnails(ctx, []uint{userID})[userID]
+}
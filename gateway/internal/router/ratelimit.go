@@ -0,0 +1,108 @@
+package router
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/lnsp/microlog/common/logger"
+	"github.com/lnsp/microlog/gateway/internal/ratelimit"
+	"github.com/sirupsen/logrus"
+)
+
+// RateLimits configures how often the authentication forms may be submitted.
+// Attempts are counted per remote host and per account, identified by its email address.
+type RateLimits struct {
+	// Limiter stores the attempts, nil disables rate limiting.
+	Limiter *ratelimit.Limiter
+	// Login limits login attempts, successful logins reset the attempts of the account.
+	Login, LoginHost ratelimit.Rule
+	// Mail limits forms sending emails, such as signups and password resets.
+	Mail, MailHost ratelimit.Rule
+}
+
+type rateLimitedContext struct {
+	Context
+	RetryAt string
+}
+
+// throttle records an attempt of the action by the remote host and, if given, the account.
+// It returns the remaining lockout, which is zero if the attempt is allowed.
+// Failures of the limiter are logged and allow the attempt, so that an unavailable store does not lock out everyone.
+func (router *Router) throttle(r *http.Request, action, account string, accountRule, hostRule ratelimit.Rule) time.Duration {
+	if router.RateLimits.Limiter == nil {
+		return 0
+	}
+	keys := []string{action + ":host:" + logger.RemoteHost(r)}
+	rules := []ratelimit.Rule{hostRule}
+	if account = strings.ToLower(strings.TrimSpace(account)); account != "" {
+		keys = append(keys, action+":account:"+account)
+		rules = append(rules, accountRule)
+	}
+	var lockout time.Duration
+	for i, key := range keys {
+		locked, err := router.RateLimits.Limiter.Attempt(key, rules[i])
+		if err != nil {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"key": key,
+			}).WithError(err).Error("failed to rate limit")
+			continue
+		}
+		if locked > lockout {
+			lockout = locked
+		}
+	}
+	if lockout > 0 {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"action":  action,
+			"account": account,
+			"lockout": lockout,
+		}).Info("rate limited attempt")
+	}
+	return lockout
+}
+
+// resetThrottle forgets the attempts of the account, for example once it signed in successfully.
+func (router *Router) resetThrottle(r *http.Request, action, account string) {
+	if router.RateLimits.Limiter == nil {
+		return
+	}
+	key := action + ":account:" + strings.ToLower(strings.TrimSpace(account))
+	if err := router.RateLimits.Limiter.Reset(key); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"key": key,
+		}).WithError(err).Error("failed to reset rate limit")
+	}
+}
+
+// setRetryAfter tells the client how long to wait before trying again.
+func setRetryAfter(w http.ResponseWriter, lockout time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockout.Seconds()))))
+}
+
+// rateLimit throttles form submissions of the action, the account is read from the email form field.
+// Locked out requests are answered with an error page instead of reaching the handler.
+func (router *Router) rateLimit(action string, accountRule, hostRule ratelimit.Rule, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lockout := router.throttle(r, action, r.FormValue("email"), accountRule, hostRule)
+		if lockout == 0 {
+			next(w, r)
+			return
+		}
+		router.renderRateLimited(w, r, lockout)
+	}
+}
+
+// renderRateLimited answers a locked out request with an error page.
+func (router *Router) renderRateLimited(w http.ResponseWriter, r *http.Request, lockout time.Duration) {
+	ctx := rateLimitedContext{
+		Context: *router.defaultContext(r),
+		RetryAt: humanize.Time(time.Now().Add(lockout)),
+	}
+	setRetryAfter(w, lockout)
+	w.WriteHeader(http.StatusTooManyRequests)
+	router.render(rateLimitedTemplate, w, ctx)
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lnsp/microlog/gateway/internal/diff"
+	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+var diffOps = map[diff.Op]string{
+	diff.Equal:  "equal",
+	diff.Delete: "delete",
+	diff.Insert: "insert",
+}
+
+type revisionEntry struct {
+	// Number counts the revisions starting at one, Previous is zero for the original version.
+	Number, Previous int
+	Title            string
+	Date             string
+}
+
+type revisionLine struct {
+	Op, Text string
+}
+
+type revisionsContext struct {
+	Context
+	ID        uint
+	Author    string
+	Title     string
+	Deleted   bool
+	Revisions []revisionEntry
+	// From and To are the numbers of the compared revisions.
+	From, To           int
+	FromTitle, ToTitle string
+	Diff               []revisionLine
+}
+
+// revisionNumber reads the revision number from the query, falling back to the given default if it is out of range.
+func revisionNumber(r *http.Request, key string, fallback, count int) int {
+	number, err := strconv.Atoi(r.URL.Query().Get(key))
+	if err != nil || number < 1 || number > count {
+		return fallback
+	}
+	return number
+}
+
+// revisions lists the revisions of a post and compares two of them.
+// Moderators can also review the revisions of deleted posts, once they may moderate.
+func (router *Router) revisions(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	id, _ := strconv.ParseUint(mux.Vars(r)["post"], 10, 64)
+	var (
+		post *models.Post
+		err  error
+	)
+	if router.mayModerate(ctx) {
+		post, err = router.Data.PostWithDeleted(uint(id))
+	} else {
+		post, err = router.Data.Post(uint(id))
+	}
+	if err != nil || post.ParentID != 0 || (!post.Published() && post.UserID != ctx.UserID) {
+		router.renderNotFound(w, r, "post")
+		return
+	}
+	author, err := router.Data.User(post.UserID)
+	if err != nil {
+		router.renderNotFound(w, r, "post")
+		return
+	}
+	revisions, err := router.Data.Revisions(post.ID)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"post": post.ID,
+		}).WithError(err).Error("failed to fetch revisions")
+		ctx.ErrorMessage = "An internal error occured, please try again."
+	}
+	revCtx := revisionsContext{
+		Context:   *ctx,
+		ID:        post.ID,
+		Author:    author.Name,
+		Title:     post.Title,
+		Deleted:   post.DeletedAt != nil,
+		Revisions: make([]revisionEntry, len(revisions)),
+	}
+	for i, revision := range revisions {
+		revCtx.Revisions[i] = revisionEntry{
+			Number:   i + 1,
+			Previous: i,
+			Title:    revision.Title,
+			Date:     revision.CreatedAt.Format(timeFormat),
+		}
+	}
+	if len(revisions) < 2 {
+		router.render(revisionsTemplate, w, revCtx)
+		return
+	}
+	revCtx.To = revisionNumber(r, "to", len(revisions), len(revisions))
+	revCtx.From = revisionNumber(r, "from", revCtx.To-1, len(revisions))
+	if revCtx.From < 1 {
+		revCtx.From = 1
+	}
+	from, to := revisions[revCtx.From-1], revisions[revCtx.To-1]
+	revCtx.FromTitle, revCtx.ToTitle = from.Title, to.Title
+	for _, line := range diff.Lines(from.Content, to.Content) {
+		revCtx.Diff = append(revCtx.Diff, revisionLine{diffOps[line.Op], line.Text})
+	}
+	router.render(revisionsTemplate, w, revCtx)
+}
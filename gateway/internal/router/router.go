@@ -12,6 +12,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/lnsp/microlog/gateway/internal/email"
 	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/lnsp/microlog/gateway/internal/oidc"
+	"github.com/lnsp/microlog/gateway/internal/profile"
 	"github.com/sirupsen/logrus"
 	"github.com/tdewolff/minify"
 	"github.com/tdewolff/minify/css"
@@ -23,10 +25,12 @@ import (
 )
 
 const (
-	timeFormat          = "Monday, 2. January at 15:04"
-	sessionCookieName   = "session_token"
-	dashboardPostsLimit = 5
-	dashboardUsersLimit = 5
+	timeFormat             = "Monday, 2. January at 15:04"
+	sessionCookieName      = "session_token"
+	dashboardPostsLimit    = 5
+	dashboardUsersLimit    = 5
+	dashboardTimelineLimit = 10
+	dashboardTagsLimit     = 10
 )
 
 var log = logger.New()
@@ -39,47 +43,89 @@ var (
 	profileTemplate        = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/profile.html"))
 	profileEditTemplate    = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/profileEdit.html"))
 	profileDeleteTemplate  = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/profileDelete.html"))
+	sessionsTemplate       = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/sessions.html"))
 	postTemplate           = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/post.html"))
 	postEditTemplate       = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/postEdit.html"))
 	reportTemplate         = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/report.html"))
 	notFoundTemplate       = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/notfound.html"))
 	confirmTemplate        = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/confirm.html"))
+	confirmResendTemplate  = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/confirmResend.html"))
 	resetTemplate          = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/reset.html"))
 	forgotTemplate         = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/forgot.html"))
+	mailStatusTemplate     = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/mailStatus.html"))
 	changelogTemplate      = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/changelog.html"))
 	termsOfServiceTemplate = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/legal/terms-of-service.html"))
 	privacyPolicyTemplate  = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/legal/privacy-policy.html"))
 	moderationTemplate     = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/moderation.html"))
+	twoFactorLoginTemplate = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/twoFactorLogin.html"))
+	twoFactorTemplate      = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/twoFactorSettings.html"))
+	rateLimitedTemplate    = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/rateLimited.html"))
+	identitiesTemplate     = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/identities.html"))
+	passwordTemplate       = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/password.html"))
+	emailChangeTemplate    = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/emailChange.html"))
+	externalSignupTemplate = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/externalSignup.html"))
+	searchTemplate         = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/search.html"))
+	tagTemplate            = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/tag.html"))
+	revisionsTemplate      = template.Must(template.ParseFiles("./web/templates/base.html", "./web/templates/revisions.html"))
 )
 
 type Config struct {
 	SessionAddr   string
 	EmailClient   *email.Client
 	SessionClient *session.Client
+	ProfileClient *profile.Client
 	DataSource    *models.DataSource
 	PublicAddress string
 	Minify        bool
 	CsrfAuthKey   []byte
 	CsrfSecure    bool
+	// ModeratorTwoFactor requires moderators to enable two-factor authentication before moderating.
+	ModeratorTwoFactor bool
+	RateLimits         RateLimits
+	// OIDC enables signing in with an OpenID Connect provider, nil disables it.
+	OIDC *oidc.Provider
+	// OIDCName is the name of the provider shown to users.
+	OIDCName string
 }
 
 func New(cfg Config) http.Handler {
 	router := &Router{
-		Data:          cfg.DataSource,
-		Email:         cfg.EmailClient,
-		Session:       cfg.SessionClient,
-		PublicAddress: cfg.PublicAddress,
+		Data:               cfg.DataSource,
+		Email:              cfg.EmailClient,
+		Session:            cfg.SessionClient,
+		Profile:            cfg.ProfileClient,
+		PublicAddress:      cfg.PublicAddress,
+		ModeratorTwoFactor: cfg.ModeratorTwoFactor,
+		RateLimits:         cfg.RateLimits,
+		OIDC:               cfg.OIDC,
+		OIDCName:           cfg.OIDCName,
 	}
+	limits := cfg.RateLimits
 	serveMux := mux.NewRouter()
 	serveMux.HandleFunc("/favicon.ico", router.favicon).Methods("GET")
 	serveMux.HandleFunc("/auth/login", router.login).Methods("GET")
-	serveMux.HandleFunc("/auth/login", router.loginSubmit).Methods("POST")
+	serveMux.HandleFunc("/auth/login", router.rateLimit("login", limits.Login, limits.LoginHost, router.loginSubmit)).Methods("POST")
+	serveMux.HandleFunc("/auth/2fa", router.twoFactorLogin).Methods("GET")
+	serveMux.HandleFunc("/auth/2fa", router.rateLimit("login", limits.Login, limits.LoginHost, router.twoFactorLoginSubmit)).Methods("POST")
+	if router.OIDC != nil {
+		serveMux.HandleFunc("/auth/external/login", router.externalLogin).Methods("GET")
+		serveMux.HandleFunc("/auth/external/callback", router.externalCallback).Methods("GET")
+		serveMux.HandleFunc("/auth/external/signup", router.externalSignup).Methods("GET")
+		serveMux.HandleFunc("/auth/external/signup", router.externalSignupSubmit).Methods("POST")
+		serveMux.HandleFunc("/profile/identities/link", router.externalLink).Methods("POST")
+		serveMux.HandleFunc("/profile/identities/unlink", router.externalUnlink).Methods("POST")
+	}
 	serveMux.HandleFunc("/auth/forgot", router.forgot).Methods("GET")
-	serveMux.HandleFunc("/auth/forgot", router.forgotSubmit).Methods("POST")
+	serveMux.HandleFunc("/auth/forgot", router.rateLimit("mail", limits.Mail, limits.MailHost, router.forgotSubmit)).Methods("POST")
 	serveMux.HandleFunc("/auth/signup", router.signup).Methods("GET")
-	serveMux.HandleFunc("/auth/signup", router.signupSubmit).Methods("POST")
+	serveMux.HandleFunc("/auth/signup", router.rateLimit("mail", limits.Mail, limits.MailHost, router.signupSubmit)).Methods("POST")
 	serveMux.HandleFunc("/auth/logout", router.logout).Methods("GET")
 	serveMux.HandleFunc("/auth/confirm", router.confirm).Methods("GET")
+	serveMux.HandleFunc("/auth/confirm/resend", router.confirmResend).Methods("GET")
+	serveMux.HandleFunc("/auth/confirm/resend", router.rateLimit("mail", limits.Mail, limits.MailHost, router.confirmResendSubmit)).Methods("POST")
+	serveMux.HandleFunc("/auth/mail/{id}", router.mailStatus).Methods("GET")
+	serveMux.HandleFunc("/auth/email/change", router.emailChange).Methods("GET")
+	serveMux.HandleFunc("/auth/email/undo", router.emailChangeUndo).Methods("GET")
 	serveMux.HandleFunc("/auth/reset", router.reset).Methods("GET")
 	serveMux.HandleFunc("/auth/reset", router.resetSubmit).Methods("POST")
 	serveMux.HandleFunc("/auth/delete", router.delete).Methods("GET")
@@ -89,22 +135,50 @@ func New(cfg Config) http.Handler {
 	serveMux.HandleFunc("/profile", router.profileRedirect).Methods("GET")
 	serveMux.HandleFunc("/profile/edit", router.profileEdit).Methods("GET")
 	serveMux.HandleFunc("/profile/edit", router.profileEditSubmit).Methods("POST")
+	serveMux.HandleFunc("/profile/image", router.profileImageSubmit).Methods("POST")
+	serveMux.HandleFunc("/profile/sessions", router.sessions).Methods("GET")
+	serveMux.HandleFunc("/profile/sessions/revoke", router.sessionsRevoke).Methods("POST")
+	serveMux.HandleFunc("/profile/sessions/revoke-others", router.sessionsRevokeOthers).Methods("POST")
+	serveMux.HandleFunc("/profile/password", router.password).Methods("GET")
+	serveMux.HandleFunc("/profile/password", router.passwordSubmit).Methods("POST")
+	serveMux.HandleFunc("/profile/identities", router.identities).Methods("GET")
+	serveMux.HandleFunc("/profile/identities", router.rateLimit("mail", limits.Mail, limits.MailHost, router.identitiesAdd)).Methods("POST")
+	serveMux.HandleFunc("/profile/identities/resend", router.rateLimit("mail", limits.Mail, limits.MailHost, router.identitiesResend)).Methods("POST")
+	serveMux.HandleFunc("/profile/identities/change", router.rateLimit("mail", limits.Mail, limits.MailHost, router.identitiesChange)).Methods("POST")
+	serveMux.HandleFunc("/profile/identities/primary", router.identitiesPrimary).Methods("POST")
+	serveMux.HandleFunc("/profile/identities/remove", router.identitiesRemove).Methods("POST")
+	serveMux.HandleFunc("/profile/2fa", router.twoFactorSettings).Methods("GET")
+	serveMux.HandleFunc("/profile/2fa/enable", router.twoFactorEnable).Methods("POST")
+	serveMux.HandleFunc("/profile/2fa/disable", router.twoFactorDisable).Methods("POST")
+	serveMux.HandleFunc("/profile/2fa/recovery", router.twoFactorRecovery).Methods("POST")
 	serveMux.HandleFunc("/post", router.postNew).Methods("GET")
 	serveMux.HandleFunc("/post", router.postSubmit).Methods("POST")
+	serveMux.HandleFunc("/post/autosave", router.postAutosave).Methods("POST")
+	serveMux.HandleFunc("/search", router.search).Methods("GET")
+	serveMux.HandleFunc("/tag/{tag}", router.tag).Methods("GET")
 	serveMux.HandleFunc("/legal/privacy-policy", router.privacyPolicy).Methods("GET")
 	serveMux.HandleFunc("/legal/terms-of-service", router.termsOfService).Methods("GET")
 	serveMux.HandleFunc("/moderate", router.Moderate).Methods("GET")
 	serveMux.HandleFunc("/moderate/delete/{report}", router.ModerateDelete).Methods("GET")
 	serveMux.HandleFunc("/moderate/close/{report}", router.ModerateClose).Methods("GET")
 	serveMux.HandleFunc("/{user}", router.profile).Methods("GET")
+	serveMux.HandleFunc("/{user}/follow", router.follow).Methods("POST")
+	serveMux.HandleFunc("/{user}/unfollow", router.unfollow).Methods("POST")
 	serveMux.HandleFunc("/{user}/{post}", router.postRedirect).Methods("GET")
 	serveMux.HandleFunc("/{user}/{post}/", router.post).Methods("GET")
 	serveMux.HandleFunc("/{user}/{post}/edit", router.postEdit).Methods("GET")
+	serveMux.HandleFunc("/{user}/{post}/revisions", router.revisions).Methods("GET")
 	serveMux.HandleFunc("/{user}/{post}/delete", router.postDelete).Methods("GET")
 	serveMux.HandleFunc("/{user}/{post}/report", router.report).Methods("GET")
 	serveMux.HandleFunc("/{user}/{post}/report", router.reportSubmit).Methods("POST")
 	serveMux.HandleFunc("/{user}/{post}/like", router.like).Methods("GET")
-	return csrf.Protect(cfg.CsrfAuthKey, csrf.Secure(cfg.CsrfSecure))(serveMux)
+	serveMux.HandleFunc("/{user}/{post}/comment", router.commentSubmit).Methods("POST")
+	// The API authenticates using bearer tokens instead of cookies and therefore needs no CSRF protection.
+	rootMux := mux.NewRouter()
+	router.registerAPI(rootMux.PathPrefix("/api/v1").Subrouter())
+	rootMux.HandleFunc("/.well-known/jwks.json", router.jwks).Methods("GET")
+	rootMux.PathPrefix("/").Handler(csrf.Protect(cfg.CsrfAuthKey, csrf.Secure(cfg.CsrfSecure))(router.renewSessions(serveMux)))
+	return rootMux
 }
 
 var minifier *minify.M
@@ -124,17 +198,26 @@ type Context struct {
 	HeadControls bool
 	SignedIn     bool
 	UserID       uint
+	SessionID    string
 	Moderator    bool
 	CurrentYear  int
 	CSRFToken    template.HTML
+	// ExternalLogin is the name of the identity provider users can sign in with, if any.
+	ExternalLogin string
 }
 
 type Router struct {
 	Email         *email.Client
 	Session       *session.Client
+	Profile       *profile.Client
 	Data          *models.DataSource
 	PublicAddress string
 	Minification  bool
+	// ModeratorTwoFactor requires moderators to enable two-factor authentication before moderating.
+	ModeratorTwoFactor bool
+	RateLimits         RateLimits
+	OIDC               *oidc.Provider
+	OIDCName           string
 }
 
 func (router *Router) render(tmp *template.Template, w http.ResponseWriter, ctx interface{}) {
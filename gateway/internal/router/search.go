@@ -0,0 +1,149 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	searchPostsLimit = 10
+	searchUsersLimit = 5
+	searchDateFormat = "2006-01-02"
+)
+
+type searchPost struct {
+	Title, Author, ID, Date string
+	Snippet                 []models.Highlight
+}
+
+type searchUser struct {
+	Name, Avatar string
+	Snippet      []models.Highlight
+}
+
+type searchContext struct {
+	Context
+	Query, Author, From, To string
+	Posts                   []searchPost
+	Users                   []searchUser
+	// Page is the current page of posts, NextPage and PreviousPage are zero if there is none.
+	Page, NextPage, PreviousPage int
+}
+
+// parseSearchFilter reads the author and date range from the given values.
+// The end of the date range includes the whole day.
+// It returns a message describing the first invalid filter, if any.
+func (router *Router) parseSearchFilter(author, from, to string) (models.PostFilter, string) {
+	var filter models.PostFilter
+	if author != "" {
+		user, err := router.Data.UserByName(author)
+		if err != nil {
+			return filter, "The author does not exist."
+		}
+		filter.Author = user.ID
+	}
+	if from != "" {
+		date, err := time.Parse(searchDateFormat, from)
+		if err != nil {
+			return filter, "The dates must be given as YYYY-MM-DD."
+		}
+		filter.From = date
+	}
+	if to != "" {
+		date, err := time.Parse(searchDateFormat, to)
+		if err != nil {
+			return filter, "The dates must be given as YYYY-MM-DD."
+		}
+		filter.To = date.AddDate(0, 0, 1)
+	}
+	return filter, ""
+}
+
+func (router *Router) search(w http.ResponseWriter, r *http.Request) {
+	ctx := searchContext{
+		Context: *router.defaultContext(r),
+		Query:   strings.TrimSpace(r.FormValue("q")),
+		Author:  r.FormValue("author"),
+		From:    r.FormValue("from"),
+		To:      r.FormValue("to"),
+		Page:    1,
+	}
+	if ctx.Query == "" {
+		router.render(searchTemplate, w, ctx)
+		return
+	}
+	if !router.Data.ValidateSearch(ctx.Query) {
+		ctx.ErrorMessage = "Your search must have at max 200 characters."
+		router.render(searchTemplate, w, ctx)
+		return
+	}
+	filter, message := router.parseSearchFilter(ctx.Author, ctx.From, ctx.To)
+	if message != "" {
+		ctx.ErrorMessage = message
+		router.render(searchTemplate, w, ctx)
+		return
+	}
+	if page, err := strconv.Atoi(r.FormValue("page")); err == nil && page > 1 {
+		ctx.Page = page
+		ctx.PreviousPage = page - 1
+	}
+	posts, err := router.Data.SearchPosts(ctx.Query, filter, (ctx.Page-1)*searchPostsLimit, searchPostsLimit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"query": ctx.Query,
+		}).WithError(err).Error("failed to search posts")
+		ctx.ErrorMessage = "An internal error occured, please try again."
+	}
+	if len(posts) == searchPostsLimit {
+		ctx.NextPage = ctx.Page + 1
+	}
+	ctx.Posts = make([]searchPost, 0, len(posts))
+	for _, post := range posts {
+		author, err := router.Data.User(post.UserID)
+		if err != nil {
+			log.WithRequest(r).WithFields(logrus.Fields{
+				"post": post.ID,
+			}).WithError(err).Error("failed to fetch user")
+			continue
+		}
+		ctx.Posts = append(ctx.Posts, searchPost{
+			Title:   post.Title,
+			Author:  author.Name,
+			ID:      strconv.FormatUint(uint64(post.ID), 10),
+			Date:    humanize.Time(post.CreatedAt),
+			Snippet: post.Snippet,
+		})
+	}
+	// Users only match the query itself, so they are listed above the first page of unfiltered posts
+	if ctx.Page > 1 || ctx.Author != "" || ctx.From != "" || ctx.To != "" {
+		router.render(searchTemplate, w, ctx)
+		return
+	}
+	users, err := router.Data.SearchUsers(ctx.Query, 0, searchUsersLimit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"query": ctx.Query,
+		}).WithError(err).Error("failed to search users")
+		ctx.ErrorMessage = "An internal error occured, please try again."
+	}
+	ids := make([]uint, len(users))
+	for i := range users {
+		ids[i] = users[i].ID
+	}
+	avatars := router.Profile.Thumbnails(r.Context(), ids)
+	ctx.Users = make([]searchUser, len(users))
+	for i, user := range users {
+		ctx.Users[i] = searchUser{
+			Name:    user.Name,
+			Avatar:  avatars[user.ID],
+			Snippet: user.Snippet,
+		}
+	}
+	router.render(searchTemplate, w, ctx)
+}
@@ -0,0 +1,190 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/lnsp/microlog/common/keyring"
+	"github.com/lnsp/microlog/gateway/internal/session"
+	"github.com/sirupsen/logrus"
+)
+
+// sessionInfoKey stores the verified session of a request in its context.
+type sessionInfoKey struct{}
+
+// sessionInfo returns the session belonging to the session cookie of the request.
+// It returns nil if the request carries no valid session.
+func (router *Router) sessionInfo(r *http.Request) *session.Info {
+	if info, ok := r.Context().Value(sessionInfoKey{}).(*session.Info); ok {
+		return info
+	}
+	sessionCookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil
+	}
+	info, err := router.Session.Verify(r.Context(), sessionCookie.Value)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"token": sessionCookie.Value,
+			"type":  "failed to verify token",
+		}).WithError(err).Error("failed to create context")
+		return nil
+	}
+	return info
+}
+
+// setSessionCookie stores the session token in the client.
+// Remembered sessions outlive the browser session, others are dropped once the browser closes.
+func setSessionCookie(w http.ResponseWriter, token string, expires time.Time, remember bool) {
+	cookie := http.Cookie{
+		Path:     "/",
+		Name:     sessionCookieName,
+		Value:    token,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if remember {
+		cookie.Expires = expires
+	}
+	http.SetCookie(w, &cookie)
+}
+
+// renewSessions verifies the session cookie once per request and attaches the session to the request context.
+// Sessions past half of their lifetime are refreshed and the cookie is replaced transparently.
+func (router *Router) renewSessions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionCookie, err := r.Cookie(sessionCookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		info := router.sessionInfo(r)
+		if info != nil && time.Until(info.Expires) < info.Expires.Sub(info.Issued)/2 {
+			token, expires, err := router.Session.Refresh(r.Context(), sessionCookie.Value)
+			if err != nil {
+				log.WithRequest(r).WithFields(logrus.Fields{
+					"id":      info.UserID,
+					"session": info.ID,
+				}).WithError(err).Warn("failed to refresh session")
+			} else {
+				setSessionCookie(w, token, expires, info.Remember)
+				info.Issued, info.Expires = time.Now(), expires
+				log.WithRequest(r).WithFields(logrus.Fields{
+					"id":      info.UserID,
+					"session": info.ID,
+				}).Debug("refreshed session")
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionInfoKey{}, info)))
+	})
+}
+
+type sessionEntry struct {
+	ID        string
+	Created   string
+	LastSeen  string
+	UserAgent string
+	IP        string
+	Current   bool
+	Remember  bool
+}
+
+type sessionsContext struct {
+	Context
+	Sessions []sessionEntry
+}
+
+func (router *Router) sessions(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	sessionsCtx := sessionsContext{
+		Context: *ctx,
+	}
+	sessions, err := router.Session.List(r.Context(), ctx.UserID)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to list sessions")
+		sessionsCtx.ErrorMessage = "Unexpected internal error, please try again."
+		router.render(sessionsTemplate, w, sessionsCtx)
+		return
+	}
+	// Show the most recently used sessions first
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	sessionsCtx.Sessions = make([]sessionEntry, len(sessions))
+	for i, s := range sessions {
+		sessionsCtx.Sessions[i] = sessionEntry{
+			ID:        s.ID,
+			Created:   s.Created.Format(timeFormat),
+			LastSeen:  humanize.Time(s.LastSeen),
+			UserAgent: s.UserAgent,
+			IP:        s.IP,
+			Current:   s.ID == ctx.SessionID,
+			Remember:  s.Remember,
+		}
+	}
+	router.render(sessionsTemplate, w, sessionsCtx)
+}
+
+func (router *Router) sessionsRevoke(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	sessionID := r.FormValue("session")
+	if err := router.Session.Revoke(r.Context(), ctx.UserID, sessionID); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":      ctx.UserID,
+			"session": sessionID,
+		}).WithError(err).Warn("failed to revoke session")
+	} else {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id":      ctx.UserID,
+			"session": sessionID,
+		}).Debug("revoked session")
+	}
+	if sessionID == ctx.SessionID {
+		http.Redirect(w, r, "/auth/logout", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/profile/sessions", http.StatusSeeOther)
+}
+
+func (router *Router) sessionsRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	if err := router.Session.DeleteAll(r.Context(), ctx.UserID, ctx.SessionID); err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to revoke sessions")
+	} else {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).Debug("revoked other sessions")
+	}
+	http.Redirect(w, r, "/profile/sessions", http.StatusSeeOther)
+}
+
+// jwks publishes the public keys of the session service, so third parties can verify session tokens.
+func (router *Router) jwks(w http.ResponseWriter, r *http.Request) {
+	keys, err := router.Session.Keys(r.Context())
+	if err != nil {
+		log.WithRequest(r).WithError(err).Error("failed to get session keys")
+		router.apiInternalError(w)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	router.apiJSON(w, http.StatusOK, keyring.JSONWebKeySet{Keys: keys})
+}
@@ -0,0 +1,42 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+type tagContext struct {
+	Context
+	Name  string
+	Posts []dashboardPost
+	// Next is the cursor of the next page, if there is one.
+	Next string
+}
+
+func (router *Router) tag(w http.ResponseWriter, r *http.Request) {
+	ctx := tagContext{
+		Context: *router.defaultContext(r),
+		Name:    models.NormalizeTag(mux.Vars(r)["tag"]),
+	}
+	if !router.Data.ValidateTag(ctx.Name) {
+		router.renderNotFound(w, r, "tag")
+		return
+	}
+	// Malformed cursors start at the newest post
+	before, _ := models.ParsePostCursor(r.URL.Query().Get("before"))
+	posts, err := router.Data.PostsByTagBefore(ctx.Name, before, dashboardTimelineLimit)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"tag": ctx.Name,
+		}).WithError(err).Error("failed to fetch tagged posts")
+		ctx.ErrorMessage = "An internal error occured, please try again."
+	}
+	ctx.Posts = router.dashboardPosts(r, ctx.UserID, posts, router.Profile.Thumbnails(r.Context(), postAuthors(posts)))
+	if len(posts) == dashboardTimelineLimit {
+		ctx.Next = posts[len(posts)-1].Cursor().String()
+	}
+	router.render(tagTemplate, w, ctx)
+}
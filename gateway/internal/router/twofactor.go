@@ -0,0 +1,252 @@
+package router
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/lnsp/microlog/gateway/internal/qr"
+	"github.com/lnsp/microlog/gateway/internal/totp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	twoFactorIssuer        = "microlog"
+	loginChallengeCookie   = "login_challenge"
+	loginChallengeLifetime = 5 * time.Minute
+)
+
+type twoFactorContext struct {
+	Context
+	Enabled       bool
+	Secret        string
+	URI           string
+	QRCode        template.HTML
+	RecoveryCodes []string
+	CodesLeft     int
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, a recovery code of the user.
+// Accepted TOTP codes and recovery codes can not be used again.
+func (router *Router) verifySecondFactor(userID uint, code string) bool {
+	twoFactor, err := router.Data.TwoFactor(userID)
+	if err != nil || !twoFactor.Enabled {
+		return false
+	}
+	if step, ok := totp.Validate(twoFactor.Secret, code, time.Now()); ok {
+		return router.Data.UseTwoFactorStep(userID, step)
+	}
+	return router.Data.UseRecoveryCode(userID, code)
+}
+
+// beginTwoFactorLogin stores the pending login and asks for the second factor.
+func (router *Router) beginTwoFactorLogin(w http.ResponseWriter, r *http.Request, userID uint, email string, remember bool) {
+	challenge, err := router.Data.AddLoginChallenge(userID, email, remember, loginChallengeLifetime)
+	if err != nil {
+		ctx := router.defaultContext(r)
+		ctx.ErrorMessage = "Unexpected internal error, please try again."
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": userID,
+		}).WithError(err).Error("failed to create login challenge")
+		router.render(loginTemplate, w, ctx)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Path:     "/auth",
+		Name:     loginChallengeCookie,
+		Value:    challenge,
+		MaxAge:   int(loginChallengeLifetime / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/auth/2fa", http.StatusSeeOther)
+}
+
+// clearLoginChallenge removes the pending login cookie.
+func clearLoginChallenge(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Path: "/auth", Name: loginChallengeCookie, Value: "", MaxAge: -1})
+}
+
+func (router *Router) twoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(loginChallengeCookie)
+	if err != nil {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	if _, err := router.Data.LoginChallenge(cookie.Value); err != nil {
+		clearLoginChallenge(w)
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	ctx := router.defaultContext(r)
+	ctx.HeadControls = false
+	router.render(twoFactorLoginTemplate, w, ctx)
+}
+
+func (router *Router) twoFactorLoginSubmit(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	ctx.HeadControls = false
+	cookie, err := r.Cookie(loginChallengeCookie)
+	if err != nil {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	challenge, err := router.Data.LoginChallenge(cookie.Value)
+	if err != nil {
+		clearLoginChallenge(w)
+		ctx.ErrorMessage = "Your login has expired, please log in again."
+		router.render(loginTemplate, w, ctx)
+		return
+	}
+	if !router.verifySecondFactor(challenge.UserID, r.FormValue("code")) {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": challenge.UserID,
+		}).Debug("failed second factor attempt")
+		if !router.Data.FailLoginChallenge(challenge.ID) {
+			clearLoginChallenge(w)
+			ctx.ErrorMessage = "Too many invalid codes, please log in again."
+			router.render(loginTemplate, w, ctx)
+			return
+		}
+		ctx.ErrorMessage = "Invalid authentication code."
+		router.render(twoFactorLoginTemplate, w, ctx)
+		return
+	}
+	clearLoginChallenge(w)
+	// Deleting the challenge fails if a concurrent request has already used it.
+	if !router.Data.DeleteLoginChallenge(challenge.ID) {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	router.resetThrottle(r, "login", challenge.Email)
+	router.startSession(w, r, challenge.UserID, challenge.Remember)
+}
+
+// twoFactorSettings shows the enrollment or, once enabled, the state of two-factor authentication.
+// The secret of an unfinished enrollment is kept, so reloading the page does not invalidate a scanned code.
+func (router *Router) twoFactorSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	if r.URL.Query().Get("required") == "moderate" {
+		ctx.ErrorMessage = "Moderators have to enable two-factor authentication."
+	}
+	router.renderTwoFactor(w, r, ctx, nil)
+}
+
+// renderTwoFactor renders the settings page, showing the given recovery codes once.
+func (router *Router) renderTwoFactor(w http.ResponseWriter, r *http.Request, ctx *Context, codes []string) {
+	tfCtx := twoFactorContext{
+		Context:       *ctx,
+		RecoveryCodes: codes,
+	}
+	twoFactor, err := router.Data.TwoFactor(ctx.UserID)
+	if err == nil && twoFactor.Enabled {
+		tfCtx.Enabled = true
+		tfCtx.CodesLeft = router.Data.RecoveryCodesLeft(ctx.UserID)
+		router.render(twoFactorTemplate, w, tfCtx)
+		return
+	}
+	user, err := router.Data.User(ctx.UserID)
+	if err != nil {
+		router.renderNotFound(w, r, "user")
+		return
+	}
+	var secret string
+	if twoFactor != nil {
+		secret = twoFactor.Secret
+	} else if secret, err = totp.GenerateSecret(); err == nil {
+		err = router.Data.BeginTwoFactor(ctx.UserID, secret)
+	}
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to begin two-factor enrollment")
+		tfCtx.ErrorMessage = "Unexpected internal error, please try again."
+		router.render(twoFactorTemplate, w, tfCtx)
+		return
+	}
+	tfCtx.Secret = secret
+	tfCtx.URI = totp.URI(twoFactorIssuer, user.Name, secret)
+	if code, err := qr.Encode(tfCtx.URI); err == nil {
+		tfCtx.QRCode = template.HTML(code.SVG())
+	} else {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Warn("failed to encode enrollment qr code")
+	}
+	router.render(twoFactorTemplate, w, tfCtx)
+}
+
+func (router *Router) twoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	twoFactor, err := router.Data.TwoFactor(ctx.UserID)
+	if err != nil || twoFactor.Enabled {
+		http.Redirect(w, r, "/profile/2fa", http.StatusSeeOther)
+		return
+	}
+	step, ok := totp.Validate(twoFactor.Secret, r.FormValue("code"), time.Now())
+	if !ok {
+		ctx.ErrorMessage = "Invalid authentication code, please check the time of your device."
+		router.renderTwoFactor(w, r, ctx, nil)
+		return
+	}
+	codes, err := router.Data.EnableTwoFactor(ctx.UserID, step)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to enable two-factor authentication")
+		ctx.ErrorMessage = "Unexpected internal error, please try again."
+		router.renderTwoFactor(w, r, ctx, nil)
+		return
+	}
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id": ctx.UserID,
+	}).Info("enabled two-factor authentication")
+	router.renderTwoFactor(w, r, ctx, codes)
+}
+
+func (router *Router) twoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	if !router.verifySecondFactor(ctx.UserID, r.FormValue("code")) {
+		ctx.ErrorMessage = "Invalid authentication code."
+		router.renderTwoFactor(w, r, ctx, nil)
+		return
+	}
+	router.Data.DisableTwoFactor(ctx.UserID)
+	log.WithRequest(r).WithFields(logrus.Fields{
+		"id": ctx.UserID,
+	}).Info("disabled two-factor authentication")
+	http.Redirect(w, r, "/profile/2fa", http.StatusSeeOther)
+}
+
+func (router *Router) twoFactorRecovery(w http.ResponseWriter, r *http.Request) {
+	ctx := router.defaultContext(r)
+	if !ctx.SignedIn {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	if !router.verifySecondFactor(ctx.UserID, r.FormValue("code")) {
+		ctx.ErrorMessage = "Invalid authentication code."
+		router.renderTwoFactor(w, r, ctx, nil)
+		return
+	}
+	codes, err := router.Data.RegenerateRecoveryCodes(ctx.UserID)
+	if err != nil {
+		log.WithRequest(r).WithFields(logrus.Fields{
+			"id": ctx.UserID,
+		}).WithError(err).Error("failed to regenerate recovery codes")
+		ctx.ErrorMessage = "Unexpected internal error, please try again."
+	}
+	router.renderTwoFactor(w, r, ctx, codes)
+}
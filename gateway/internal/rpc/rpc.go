@@ -0,0 +1,133 @@
+// Package rpc sets up long-lived gRPC connections to the backend services.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// retryDelay is the delay before the first retry, it is doubled with each further attempt.
+	retryDelay = 50 * time.Millisecond
+	// maxReconnectDelay limits the backoff between two connection attempts.
+	maxReconnectDelay = 10 * time.Second
+)
+
+// Config describes how backend services are called.
+type Config struct {
+	// Timeout is the deadline of a single call attempt.
+	Timeout time.Duration
+	// Retries is the number of additional attempts for idempotent calls.
+	Retries int
+	// TLS enables transport security, optionally verifying the server using CAFile.
+	TLS    bool
+	CAFile string
+	// CertFile and KeyFile are presented to the server as client certificate.
+	CertFile, KeyFile string
+}
+
+// idempotentOption marks calls which are safe to retry.
+type idempotentOption struct {
+	grpc.EmptyCallOption
+}
+
+// Idempotent marks a call as safe to retry.
+// Calls without it are attempted exactly once.
+var Idempotent grpc.CallOption = idempotentOption{}
+
+// Dial sets up a connection to the given target.
+// The connection is established in the background and re-established with backoff whenever it breaks,
+// so a backend being unavailable at startup is not fatal.
+func Dial(target string, cfg Config) (*grpc.ClientConn, error) {
+	transport, err := cfg.transportOption()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.Dial(target,
+		transport,
+		grpc.WithBackoffMaxDelay(maxReconnectDelay),
+		grpc.WithUnaryInterceptor(cfg.intercept),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial %s", target)
+	}
+	return conn, nil
+}
+
+// transportOption returns the dial option securing the connection.
+func (cfg Config) transportOption() (grpc.DialOption, error) {
+	if !cfg.TLS {
+		return grpc.WithInsecure(), nil
+	}
+	tlsConfig := &tls.Config{}
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in CA file")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), nil
+}
+
+// intercept applies the per-attempt deadline and retries idempotent calls on transient errors.
+// The context of the call bounds all attempts together.
+func (cfg Config) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	retries := 0
+	for _, opt := range opts {
+		if _, ok := opt.(idempotentOption); ok {
+			retries = cfg.Retries
+		}
+	}
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if cfg.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		}
+		err := invoker(attemptCtx, method, req, reply, cc, opts...)
+		cancel()
+		if err == nil || attempt >= retries || !retryable(ctx, err) {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// retryable checks if a failed attempt may succeed when repeated.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
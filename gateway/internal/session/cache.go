@@ -0,0 +1,212 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/lnsp/microlog/common/keyring"
+	"github.com/lnsp/microlog/session/api"
+	"github.com/pkg/errors"
+)
+
+const (
+	// cachePruneSize is the number of cached sessions above which expired entries are pruned.
+	cachePruneSize = 10000
+	// maxWatchDelay limits the backoff between two attempts to watch revocations.
+	maxWatchDelay = 30 * time.Second
+	// keysRefreshInterval limits how often unknown key IDs trigger fetching the public keys.
+	keysRefreshInterval = time.Minute
+)
+
+// claims mirrors the claims of tokens issued by the session service.
+type claims struct {
+	jwt.StandardClaims
+	Identity uint32
+	Role     string
+	Remember bool
+}
+
+// cacheEntry stores whether a session was active when it was last checked.
+type cacheEntry struct {
+	active bool
+	until  time.Time
+}
+
+// cache remembers the revocation state of sessions for a short time.
+// Entries are only used while the revocation watch is connected, so revocations take effect immediately.
+type cache struct {
+	keys *keyring.Keyring
+	ttl  time.Duration
+
+	mu          sync.Mutex
+	synced      bool
+	entries     map[string]cacheEntry
+	keysFetched time.Time
+}
+
+// get returns the cached state of the session.
+// The second return value is false if the state is unknown or may be outdated.
+func (c *cache) get(id string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[id]
+	if !c.synced || !ok || time.Now().After(entry.until) {
+		return false, false
+	}
+	return entry.active, true
+}
+
+// put stores the state of the session.
+// Revocations are final, a session marked as deleted is never marked active again.
+func (c *cache) put(id string, active bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.synced {
+		return
+	}
+	now := time.Now()
+	if entry, ok := c.entries[id]; ok && active && !entry.active && now.Before(entry.until) {
+		return
+	}
+	if len(c.entries) >= cachePruneSize {
+		for key, entry := range c.entries {
+			if now.After(entry.until) {
+				delete(c.entries, key)
+			}
+		}
+	}
+	c.entries[id] = cacheEntry{active: active, until: now.Add(c.ttl)}
+}
+
+// revoke marks the session as deleted.
+func (c *cache) revoke(id string) {
+	c.put(id, false)
+}
+
+// setSynced enables or disables the cache.
+// Disabling drops all entries, since revocations may be missed until the watch reconnects.
+func (c *cache) setSynced(synced bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.synced != synced {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.synced = synced
+}
+
+// EnableCache verifies tokens locally and caches the session state for the given time.
+// Besides the given keys, the public keys published by the session service are used.
+// Revocations are watched until the context is done.
+func (session *Client) EnableCache(ctx context.Context, keys *keyring.Keyring, ttl time.Duration) {
+	session.cache = &cache{
+		keys:    keys,
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+	go session.watchRevocations(ctx)
+}
+
+// watchRevocations keeps the cache in sync with deleted sessions.
+// The cache is disabled whenever the watch disconnects and reconnected with backoff.
+func (session *Client) watchRevocations(ctx context.Context) {
+	delay := time.Second
+	for ctx.Err() == nil {
+		err := session.receiveRevocations(ctx, &delay)
+		session.cache.setSynced(false)
+		if ctx.Err() != nil {
+			return
+		}
+		log.WithError(err).WithField("retry", delay).Warn("revocation watch disconnected")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		if delay *= 2; delay > maxWatchDelay {
+			delay = maxWatchDelay
+		}
+	}
+}
+
+// receiveRevocations applies revocation events to the cache until the stream breaks.
+// The backoff delay is reset once the watch is established.
+func (session *Client) receiveRevocations(ctx context.Context, delay *time.Duration) error {
+	stream, err := session.client.WatchRevocations(ctx, &api.WatchRequest{})
+	if err != nil {
+		return errors.Wrap(err, "failed to watch revocations")
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return errors.Wrap(err, "failed to receive revocation")
+		}
+		if event.Session == "" {
+			// The session service may have been restarted using new keys
+			session.refreshKeys(ctx)
+			session.cache.setSynced(true)
+			*delay = time.Second
+			log.Debug("watching revocations")
+			continue
+		}
+		session.cache.revoke(event.Session)
+	}
+}
+
+// keysOutdated reports if the public keys may be fetched again.
+// Calling it resets the refresh interval, so concurrent callers do not all trigger a refresh.
+func (c *cache) keysOutdated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.keysFetched) < keysRefreshInterval {
+		return false
+	}
+	c.keysFetched = time.Now()
+	return true
+}
+
+// refreshKeys fetches the public keys of the session service.
+func (session *Client) refreshKeys(ctx context.Context) {
+	jwks, err := session.Keys(ctx)
+	if err != nil {
+		log.WithError(err).Warn("failed to fetch session keys")
+		return
+	}
+	if err := session.cache.keys.SetPublicKeys(jwks); err != nil {
+		log.WithError(err).Warn("failed to load session keys")
+		return
+	}
+	log.WithField("count", len(jwks)).Debug("fetched session keys")
+}
+
+// verifyLocally checks the token signature and looks up the session state in the cache.
+// The second return value is false if the session service has to be asked instead.
+func (session *Client) verifyLocally(token string) (*Info, bool, error) {
+	var c claims
+	if _, err := jwt.ParseWithClaims(token, &c, session.cache.keys.Keyfunc); err != nil {
+		if verr, ok := err.(*jwt.ValidationError); ok && errors.Cause(verr.Inner) == keyring.ErrUnknownKey {
+			// The key may have been added after the keys were fetched
+			if session.cache.keysOutdated() {
+				go session.refreshKeys(context.Background())
+			}
+			return nil, false, nil
+		}
+		return nil, true, errors.Wrap(err, "token not accepted")
+	}
+	active, ok := session.cache.get(c.Id)
+	if !ok {
+		return nil, false, nil
+	}
+	if !active {
+		return nil, true, errors.New("session has been revoked")
+	}
+	return &Info{
+		ID:        c.Id,
+		UserID:    uint(c.Identity),
+		Moderator: c.Role == "moderator",
+		Issued:    time.Unix(c.IssuedAt, 0),
+		Expires:   time.Unix(c.ExpiresAt, 0),
+		Remember:  c.Remember,
+	}, true, nil
+}
@@ -1,7 +1,10 @@
 package session
 
 import (
+	"github.com/lnsp/microlog/common/keyring"
+	"github.com/lnsp/microlog/common/logger"
 	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/lnsp/microlog/gateway/internal/rpc"
 	"github.com/lnsp/microlog/session/api"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
@@ -10,82 +13,180 @@ import (
 	"time"
 )
 
-type Client struct {
-	data    *models.DataSource
-	service string
+// Info describes an active session.
+type Info struct {
+	ID        string
+	UserID    uint
+	Moderator bool
+	Created   time.Time
+	LastSeen  time.Time
+	UserAgent string
+	IP        string
+	Issued    time.Time
+	Expires   time.Time
+	Remember  bool
 }
 
-func NewClient(dataSource *models.DataSource, sessionService string) *Client {
-	return &Client{
-		data:    dataSource,
-		service: sessionService,
-	}
+var log = logger.New()
+
+type Client struct {
+	data   *models.DataSource
+	client api.SessionClient
+	cache  *cache
 }
 
-func (session *Client) serviceClient() (api.SessionClient, *grpc.ClientConn, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	conn, err := grpc.DialContext(ctx, session.service, grpc.WithInsecure(), grpc.WithBlock())
-	if err != nil {
-		return nil, nil, errors.Wrapf(err, "failed to dial session service %s", session.service)
+// NewClient creates a session service client using the given connection.
+func NewClient(dataSource *models.DataSource, conn *grpc.ClientConn) *Client {
+	return &Client{
+		data:   dataSource,
+		client: api.NewSessionClient(conn),
 	}
-	service := api.NewSessionClient(conn)
-	return service, conn, nil
 }
 
-func (session *Client) Create(userID uint) (string, error) {
+// Create starts a new session for the given user.
+// The user agent and IP are stored to help the user recognize the session later on.
+// Remembered sessions are issued with a longer lifetime.
+// It returns the session token and its expiration time.
+func (session *Client) Create(ctx context.Context, userID uint, userAgent, ip string, remember bool) (string, time.Time, error) {
 	user, err := session.data.User(userID)
 	if err != nil {
-		return "", errors.Wrap(err, "could not create context")
+		return "", time.Time{}, errors.Wrap(err, "could not create context")
 	}
-	client, conn, err := session.serviceClient()
-	if err != nil {
-		return "", errors.Wrap(err, "failed to create client")
-	}
-	defer conn.Close()
 	role := "user"
 	if user.Moderator {
 		role = "moderator"
 	}
-	resp, err := client.Create(context.Background(), &api.CreateRequest{
-		Id:   uint32(user.ID),
-		Role: role,
+	resp, err := session.client.Create(ctx, &api.CreateRequest{
+		Id:        uint32(user.ID),
+		Role:      role,
+		UserAgent: userAgent,
+		Ip:        ip,
+		Remember:  remember,
 	})
 	if err != nil {
-		return "", errors.Wrap(err, "failed to create token")
+		return "", time.Time{}, errors.Wrap(err, "failed to create token")
 	}
-	return resp.Token, nil
+	return resp.Token, time.Unix(resp.Expires, 0), nil
 }
 
-func (session *Client) Verify(token string) (uint, bool, error) {
-	client, conn, err := session.serviceClient()
-	if err != nil {
-		return 0, false, errors.Wrap(err, "failed to create client")
+// Verify checks if the token belongs to an active session.
+// If the cache is enabled, the session service is only asked if the session state is not cached.
+// It returns the session owner and ID.
+func (session *Client) Verify(ctx context.Context, token string) (*Info, error) {
+	if session.cache != nil {
+		info, ok, err := session.verifyLocally(token)
+		if ok {
+			return info, err
+		}
 	}
-	defer conn.Close()
-	resp, err := client.Verify(context.Background(), &api.VerifyRequest{
+	resp, err := session.client.Verify(ctx, &api.VerifyRequest{
 		Token: token,
-	})
+	}, rpc.Idempotent)
 	if err != nil {
-		return 0, false, errors.Wrap(err, "failed to verify token")
+		return nil, errors.Wrap(err, "failed to verify token")
+	}
+	if session.cache != nil && resp.Session != "" {
+		session.cache.put(resp.Session, resp.Ok)
 	}
 	if !resp.Ok {
-		return 0, false, errors.Wrap(err, "token not accepted")
+		return nil, errors.New("token not accepted")
 	}
-	return uint(resp.Id), resp.Role == "moderator", nil
+	return &Info{
+		ID:        resp.Session,
+		UserID:    uint(resp.Id),
+		Moderator: resp.Role == "moderator",
+		Issued:    time.Unix(resp.Issued, 0),
+		Expires:   time.Unix(resp.Expires, 0),
+		Remember:  resp.Remember,
+	}, nil
 }
 
-func (session *Client) Delete(token string) error {
-	client, conn, err := session.serviceClient()
+// Refresh extends the lifetime of the session and reissues its token.
+// It returns the new token and its expiration time.
+func (session *Client) Refresh(ctx context.Context, token string) (string, time.Time, error) {
+	resp, err := session.client.Refresh(ctx, &api.RefreshRequest{
+		Token: token,
+	})
 	if err != nil {
-		return errors.Wrap(err, "failed to create client")
+		return "", time.Time{}, errors.Wrap(err, "failed to refresh token")
+	}
+	return resp.Token, time.Unix(resp.Expires, 0), nil
+}
+
+// Keys returns the public keys the session service signs tokens with.
+func (session *Client) Keys(ctx context.Context) ([]keyring.JSONWebKey, error) {
+	resp, err := session.client.Keys(ctx, &api.KeysRequest{}, rpc.Idempotent)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get keys")
+	}
+	jwks := make([]keyring.JSONWebKey, len(resp.Keys))
+	for i, key := range resp.Keys {
+		jwks[i] = keyring.JSONWebKey{
+			KeyID:     key.Kid,
+			KeyType:   key.Kty,
+			Algorithm: key.Alg,
+			Use:       key.Use,
+			N:         key.N,
+			E:         key.E,
+		}
 	}
-	defer conn.Close()
-	_, err = client.Delete(context.Background(), &api.DeleteRequest{
+	return jwks, nil
+}
+
+func (session *Client) Delete(ctx context.Context, token string) error {
+	_, err := session.client.Delete(ctx, &api.DeleteRequest{
 		Token: token,
-	})
+	}, rpc.Idempotent)
 	if err != nil {
 		return errors.Wrap(err, "failed to delete token")
 	}
 	return nil
 }
+
+// List returns the active sessions of the given user.
+func (session *Client) List(ctx context.Context, userID uint) ([]Info, error) {
+	resp, err := session.client.List(ctx, &api.ListRequest{
+		Id: uint32(userID),
+	}, rpc.Idempotent)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list sessions")
+	}
+	sessions := make([]Info, len(resp.Sessions))
+	for i, s := range resp.Sessions {
+		sessions[i] = Info{
+			ID:        s.Id,
+			UserID:    userID,
+			Created:   time.Unix(s.Created, 0),
+			LastSeen:  time.Unix(s.LastSeen, 0),
+			UserAgent: s.UserAgent,
+			IP:        s.Ip,
+			Remember:  s.Remember,
+		}
+	}
+	return sessions, nil
+}
+
+// Revoke ends the session with the given ID if it belongs to the given user.
+func (session *Client) Revoke(ctx context.Context, userID uint, sessionID string) error {
+	_, err := session.client.Revoke(ctx, &api.RevokeRequest{
+		Id:      uint32(userID),
+		Session: sessionID,
+	}, rpc.Idempotent)
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke session")
+	}
+	return nil
+}
+
+// DeleteAll ends all sessions of the given user except the one with the given ID.
+// Pass an empty ID to end all sessions.
+func (session *Client) DeleteAll(ctx context.Context, userID uint, keep string) error {
+	_, err := session.client.DeleteAllForUser(ctx, &api.DeleteAllRequest{
+		Id:   uint32(userID),
+		Keep: keep,
+	}, rpc.Idempotent)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete sessions")
+	}
+	return nil
+}
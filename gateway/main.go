@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"time"
 
+	"github.com/lnsp/microlog/common/keyring"
 	"github.com/lnsp/microlog/common/logger"
 	"github.com/lnsp/microlog/gateway/internal/session"
 
@@ -12,20 +14,159 @@ import (
 
 	"github.com/lnsp/microlog/gateway/internal/email"
 	"github.com/lnsp/microlog/gateway/internal/models"
+	"github.com/lnsp/microlog/gateway/internal/oidc"
+	"github.com/lnsp/microlog/gateway/internal/profile"
+	"github.com/lnsp/microlog/gateway/internal/ratelimit"
 	"github.com/lnsp/microlog/gateway/internal/router"
+	"github.com/lnsp/microlog/gateway/internal/rpc"
+	"google.golang.org/grpc"
 )
 
 var log = logger.New()
 
 type specification struct {
-	PublicAddr     string `default:"localhost:8080" desc:"Public address the server is reachable on"`
-	Addr           string `default:":8080" desc:"Address the server is listening on"`
-	Datasource     string `required:"true" desc:"Database file name"`
-	Minify         bool   `default:"false" desc:"Minify all responses"`
-	EmailService   string `default:"mail:8080" desc:"Email service host"`
-	SessionService string `default:"session:8080" desc:"Session service host"`
-	CsrfAuthKey    string `default:"csrf-auth-key" desc:"CSRF validation key"`
-	CsrfSecure     bool   `default:"true" desc:"CSRF HTTPS only"`
+	PublicAddr     string        `default:"localhost:8080" desc:"Public address the server is reachable on"`
+	Addr           string        `default:":8080" desc:"Address the server is listening on"`
+	Datasource     string        `required:"true" desc:"Database file name"`
+	Minify         bool          `default:"false" desc:"Minify all responses"`
+	EmailService   string        `default:"mail:8080" desc:"Email service host"`
+	SessionService string        `default:"session:8080" desc:"Session service host"`
+	ProfileService string        `default:"profile:8080" desc:"Profile service host"`
+	RPCTimeout     time.Duration `default:"2s" desc:"Deadline of a single call to a backend service"`
+	RPCRetries     int           `default:"2" desc:"Retries of idempotent calls failing with a transient error"`
+	RPCTLS         bool          `default:"false" desc:"Secure connections to backend services using TLS"`
+	RPCCAFile      string        `desc:"CA certificate bundle used to verify backend services"`
+	RPCCertFile    string        `desc:"Client certificate presented to backend services for mutual TLS"`
+	RPCKeyFile     string        `desc:"Key of the client certificate"`
+	SessionSecret  string        `desc:"Session signing secret, enables local verification of HS256 session tokens"`
+	SessionKeys    string        `desc:"Additional session signing keys as comma separated id:secret pairs"`
+	SessionKeyFile string        `desc:"JSON file containing the session signing keys"`
+	SessionCache   time.Duration `default:"30s" desc:"Time the state of locally verified sessions is cached, 0 disables local verification"`
+	MigrateProfile bool          `default:"false" desc:"Migrate biographies into the profile service and copy them back for search on startup"`
+	CsrfAuthKey    string        `default:"csrf-auth-key" desc:"CSRF validation key"`
+	CsrfSecure     bool          `default:"true" desc:"CSRF HTTPS only"`
+	Moderator2FA   bool          `default:"false" desc:"Require moderators to enable two-factor authentication before moderating"`
+	RateLimit      string        `default:"memory" desc:"Store of rate limit counters, either memory, redis or none"`
+	Redis          string        `default:"session_db:6379" desc:"Address of the redis server storing rate limit counters"`
+	RedisPassword  string        `desc:"Password of the redis server"`
+	LoginLimit     int           `default:"5" desc:"Login attempts per account within LoginWindow before it is locked out"`
+	LoginHostLimit int           `default:"20" desc:"Login attempts per remote host within LoginWindow before it is locked out"`
+	LoginWindow    time.Duration `default:"15m" desc:"Window login attempts are counted in"`
+	MailLimit      int           `default:"3" desc:"Signups and password resets per email address within MailWindow"`
+	MailHostLimit  int           `default:"10" desc:"Signups and password resets per remote host within MailWindow"`
+	MailWindow     time.Duration `default:"1h" desc:"Window signups and password resets are counted in"`
+	Lockout        time.Duration `default:"1m" desc:"Duration of the first lockout, doubled with each further lockout within a day"`
+	MaxLockout     time.Duration `default:"1h" desc:"Maximum duration of a lockout"`
+	UnconfirmedTTL time.Duration `default:"0" desc:"Time after which accounts without a confirmed email address are deleted, 0 keeps them"`
+	CleanupPeriod  time.Duration `default:"1h" desc:"Interval between two deletions of unconfirmed accounts"`
+	PublishPeriod  time.Duration `default:"1m" desc:"Interval between two checks for scheduled posts to publish, 0 disables publishing"`
+	OIDCIssuer     string        `desc:"Issuer URL of an OpenID Connect provider, enables signing in with it"`
+	OIDCClientID   string        `desc:"Client ID registered with the OpenID Connect provider"`
+	OIDCSecret     string        `desc:"Client secret registered with the OpenID Connect provider"`
+	OIDCRedirect   string        `default:"http://localhost:8080/auth/external/callback" desc:"Callback URL registered with the OpenID Connect provider"`
+	OIDCScopes     []string      `default:"email,profile" desc:"Scopes requested from the OpenID Connect provider in addition to openid"`
+	OIDCName       string        `default:"OpenID Connect" desc:"Name of the OpenID Connect provider shown to users"`
+}
+
+// dial connects to the backend service at the given address.
+func dial(addr string, cfg rpc.Config) *grpc.ClientConn {
+	conn, err := rpc.Dial(addr, cfg)
+	if err != nil {
+		log.WithError(err).WithFields(logrus.Fields{
+			"addr": addr,
+		}).Fatal("failed to setup backend connection")
+	}
+	return conn
+}
+
+// rateLimiter creates the limiter selected in the specification.
+// It returns nil if rate limiting is disabled.
+func rateLimiter(spec *specification) *ratelimit.Limiter {
+	var store ratelimit.Store
+	switch spec.RateLimit {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "redis":
+		store = ratelimit.NewRedisStore(spec.Redis, spec.RedisPassword)
+	case "none":
+		return nil
+	default:
+		log.WithFields(logrus.Fields{
+			"store": spec.RateLimit,
+		}).Fatal("unknown rate limit store")
+	}
+	return ratelimit.New(store, spec.Lockout, spec.MaxLockout)
+}
+
+// deleteUnconfirmed periodically deletes accounts that have not been confirmed within the given time.
+// Like deleting an account by hand, it also removes the profile, email tokens and sessions of the account.
+// Replicas may run it concurrently, each account is deleted by exactly one of them.
+func deleteUnconfirmed(dataSource *models.DataSource, profileClient *profile.Client, emailClient *email.Client, sessionClient *session.Client, ttl, period time.Duration) {
+	for range time.Tick(period) {
+		deleted, err := dataSource.DeleteUnconfirmedUsers(time.Now().Add(-ttl))
+		if err != nil {
+			log.WithError(err).Error("failed to delete unconfirmed accounts")
+		}
+		ctx := context.Background()
+		for _, id := range deleted {
+			if err := profileClient.Delete(ctx, id); err != nil && err != profile.ErrNotFound {
+				log.WithFields(logrus.Fields{
+					"id": id,
+				}).WithError(err).Warn("failed to delete profile")
+			}
+			if err := emailClient.RevokeTokens(ctx, id); err != nil {
+				log.WithFields(logrus.Fields{
+					"id": id,
+				}).WithError(err).Warn("failed to revoke email tokens")
+			}
+			if err := sessionClient.DeleteAll(ctx, id, ""); err != nil {
+				log.WithFields(logrus.Fields{
+					"id": id,
+				}).WithError(err).Warn("failed to revoke sessions")
+			}
+		}
+		if len(deleted) > 0 {
+			log.WithFields(logrus.Fields{
+				"deleted": len(deleted),
+			}).Info("deleted unconfirmed accounts")
+		}
+	}
+}
+
+// publishScheduled periodically publishes scheduled posts whose time has passed.
+// Replicas may run it concurrently, each post is published by exactly one of them.
+func publishScheduled(dataSource *models.DataSource, period time.Duration) {
+	for range time.Tick(period) {
+		published, err := dataSource.PublishScheduledPosts(time.Now())
+		if err != nil {
+			log.WithError(err).Error("failed to publish scheduled posts")
+			continue
+		}
+		for _, id := range published {
+			log.WithFields(logrus.Fields{
+				"post": id,
+			}).Info("published scheduled post")
+		}
+	}
+}
+
+// oidcProvider creates the OpenID Connect provider configured in the specification.
+// It returns nil if no provider is configured.
+func oidcProvider(spec *specification) *oidc.Provider {
+	if spec.OIDCIssuer == "" {
+		return nil
+	}
+	provider, err := oidc.New(oidc.Config{
+		Issuer:       spec.OIDCIssuer,
+		ClientID:     spec.OIDCClientID,
+		ClientSecret: spec.OIDCSecret,
+		RedirectURL:  spec.OIDCRedirect,
+		Scopes:       spec.OIDCScopes,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("failed to setup openid connect provider")
+	}
+	return provider
 }
 
 func main() {
@@ -40,14 +181,67 @@ func main() {
 			"datasource": spec.Datasource,
 		}).Fatal("failed to open data source")
 	}
+	if spec.PublishPeriod > 0 {
+		go publishScheduled(dataSource, spec.PublishPeriod)
+	}
+	rpcConfig := rpc.Config{
+		Timeout:  spec.RPCTimeout,
+		Retries:  spec.RPCRetries,
+		TLS:      spec.RPCTLS,
+		CAFile:   spec.RPCCAFile,
+		CertFile: spec.RPCCertFile,
+		KeyFile:  spec.RPCKeyFile,
+	}
+	profileClient := profile.NewClient(dataSource, dial(spec.ProfileService, rpcConfig))
+	if spec.MigrateProfile {
+		go func() {
+			migrated, err := profileClient.MigrateAll(context.Background())
+			if err != nil {
+				log.WithError(err).Error("failed to migrate profiles")
+				return
+			}
+			log.WithFields(logrus.Fields{
+				"migrated": migrated,
+			}).Info("migrated profiles")
+		}()
+	}
+	sessionClient := session.NewClient(dataSource, dial(spec.SessionService, rpcConfig))
+	if spec.SessionCache > 0 {
+		// Shared secrets are only required for HS256 tokens, RS256 keys are fetched from the session service
+		keys, err := keyring.New(keyring.Config{
+			Secret:     spec.SessionSecret,
+			Keys:       spec.SessionKeys,
+			File:       spec.SessionKeyFile,
+			VerifyOnly: true,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("failed to load session keys")
+		}
+		sessionClient.EnableCache(context.Background(), keys, spec.SessionCache)
+	}
+	emailClient := email.NewClient(dataSource, dial(spec.EmailService, rpcConfig))
+	if spec.UnconfirmedTTL > 0 {
+		go deleteUnconfirmed(dataSource, profileClient, emailClient, sessionClient, spec.UnconfirmedTTL, spec.CleanupPeriod)
+	}
 	handler := router.New(router.Config{
-		EmailClient:   email.NewClient(dataSource, spec.EmailService),
-		SessionClient: session.NewClient(dataSource, spec.SessionService),
-		DataSource:    dataSource,
-		PublicAddress: spec.PublicAddr,
-		Minify:        spec.Minify,
-		CsrfAuthKey:   []byte(spec.CsrfAuthKey),
-		CsrfSecure:    spec.CsrfSecure,
+		EmailClient:        emailClient,
+		SessionClient:      sessionClient,
+		ProfileClient:      profileClient,
+		DataSource:         dataSource,
+		PublicAddress:      spec.PublicAddr,
+		Minify:             spec.Minify,
+		CsrfAuthKey:        []byte(spec.CsrfAuthKey),
+		CsrfSecure:         spec.CsrfSecure,
+		ModeratorTwoFactor: spec.Moderator2FA,
+		OIDC:               oidcProvider(spec),
+		OIDCName:           spec.OIDCName,
+		RateLimits: router.RateLimits{
+			Limiter:   rateLimiter(spec),
+			Login:     ratelimit.Rule{Limit: spec.LoginLimit, Window: spec.LoginWindow},
+			LoginHost: ratelimit.Rule{Limit: spec.LoginHostLimit, Window: spec.LoginWindow},
+			Mail:      ratelimit.Rule{Limit: spec.MailLimit, Window: spec.MailWindow},
+			MailHost:  ratelimit.Rule{Limit: spec.MailHostLimit, Window: spec.MailWindow},
+		},
 	})
 	server := &http.Server{
 		Handler:           log.Middleware(handler),
@@ -1,27 +1,16 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // source: mail.proto
 
-/*
-Package api is a generated protocol buffer package.
-
-It is generated from these files:
-	mail.proto
-
-It has these top-level messages:
-	VerificationRequest
-	VerificationResponse
-	MailRequest
-	MailResponse
-*/
 package api
 
-import proto "github.com/golang/protobuf/proto"
-import fmt "fmt"
-import math "math"
-
 import (
-	context "golang.org/x/net/context"
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
 	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -33,40 +22,99 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type VerificationRequest_Purpose int32
 
 const (
-	VerificationRequest_CONFIRMATION   VerificationRequest_Purpose = 0
-	VerificationRequest_PASSWORD_RESET VerificationRequest_Purpose = 1
+	VerificationRequest_CONFIRMATION      VerificationRequest_Purpose = 0
+	VerificationRequest_PASSWORD_RESET    VerificationRequest_Purpose = 1
+	VerificationRequest_EMAIL_CHANGE      VerificationRequest_Purpose = 2
+	VerificationRequest_EMAIL_CHANGE_UNDO VerificationRequest_Purpose = 3
 )
 
 var VerificationRequest_Purpose_name = map[int32]string{
 	0: "CONFIRMATION",
 	1: "PASSWORD_RESET",
+	2: "EMAIL_CHANGE",
+	3: "EMAIL_CHANGE_UNDO",
 }
+
 var VerificationRequest_Purpose_value = map[string]int32{
-	"CONFIRMATION":   0,
-	"PASSWORD_RESET": 1,
+	"CONFIRMATION":      0,
+	"PASSWORD_RESET":    1,
+	"EMAIL_CHANGE":      2,
+	"EMAIL_CHANGE_UNDO": 3,
 }
 
 func (x VerificationRequest_Purpose) String() string {
 	return proto.EnumName(VerificationRequest_Purpose_name, int32(x))
 }
+
 func (VerificationRequest_Purpose) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor0, []int{0, 0}
+	return fileDescriptor_7cda5f053e74676b, []int{0, 0}
+}
+
+type StatusResponse_State int32
+
+const (
+	StatusResponse_PENDING StatusResponse_State = 0
+	StatusResponse_SENT    StatusResponse_State = 1
+	StatusResponse_FAILED  StatusResponse_State = 2
+)
+
+var StatusResponse_State_name = map[int32]string{
+	0: "PENDING",
+	1: "SENT",
+	2: "FAILED",
+}
+
+var StatusResponse_State_value = map[string]int32{
+	"PENDING": 0,
+	"SENT":    1,
+	"FAILED":  2,
+}
+
+func (x StatusResponse_State) String() string {
+	return proto.EnumName(StatusResponse_State_name, int32(x))
+}
+
+func (StatusResponse_State) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{8, 0}
 }
 
 type VerificationRequest struct {
-	Token   string                      `protobuf:"bytes,1,opt,name=token" json:"token,omitempty"`
-	Purpose VerificationRequest_Purpose `protobuf:"varint,2,opt,name=purpose,enum=api.VerificationRequest_Purpose" json:"purpose,omitempty"`
+	Token                string                      `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Purpose              VerificationRequest_Purpose `protobuf:"varint,2,opt,name=purpose,proto3,enum=api.VerificationRequest_Purpose" json:"purpose,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
+	XXX_unrecognized     []byte                      `json:"-"`
+	XXX_sizecache        int32                       `json:"-"`
+}
+
+func (m *VerificationRequest) Reset()         { *m = VerificationRequest{} }
+func (m *VerificationRequest) String() string { return proto.CompactTextString(m) }
+func (*VerificationRequest) ProtoMessage()    {}
+func (*VerificationRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{0}
+}
+
+func (m *VerificationRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VerificationRequest.Unmarshal(m, b)
+}
+func (m *VerificationRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VerificationRequest.Marshal(b, m, deterministic)
+}
+func (m *VerificationRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VerificationRequest.Merge(m, src)
+}
+func (m *VerificationRequest) XXX_Size() int {
+	return xxx_messageInfo_VerificationRequest.Size(m)
+}
+func (m *VerificationRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_VerificationRequest.DiscardUnknown(m)
 }
 
-func (m *VerificationRequest) Reset()                    { *m = VerificationRequest{} }
-func (m *VerificationRequest) String() string            { return proto.CompactTextString(m) }
-func (*VerificationRequest) ProtoMessage()               {}
-func (*VerificationRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+var xxx_messageInfo_VerificationRequest proto.InternalMessageInfo
 
 func (m *VerificationRequest) GetToken() string {
 	if m != nil {
@@ -83,14 +131,38 @@ func (m *VerificationRequest) GetPurpose() VerificationRequest_Purpose {
 }
 
 type VerificationResponse struct {
-	Email string `protobuf:"bytes,1,opt,name=email" json:"email,omitempty"`
-	Id    uint32 `protobuf:"varint,2,opt,name=id" json:"id,omitempty"`
+	Email                string   `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Id                   uint32   `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	PreviousEmail        string   `protobuf:"bytes,3,opt,name=previous_email,json=previousEmail,proto3" json:"previous_email,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *VerificationResponse) Reset()                    { *m = VerificationResponse{} }
-func (m *VerificationResponse) String() string            { return proto.CompactTextString(m) }
-func (*VerificationResponse) ProtoMessage()               {}
-func (*VerificationResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (m *VerificationResponse) Reset()         { *m = VerificationResponse{} }
+func (m *VerificationResponse) String() string { return proto.CompactTextString(m) }
+func (*VerificationResponse) ProtoMessage()    {}
+func (*VerificationResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{1}
+}
+
+func (m *VerificationResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VerificationResponse.Unmarshal(m, b)
+}
+func (m *VerificationResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VerificationResponse.Marshal(b, m, deterministic)
+}
+func (m *VerificationResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VerificationResponse.Merge(m, src)
+}
+func (m *VerificationResponse) XXX_Size() int {
+	return xxx_messageInfo_VerificationResponse.Size(m)
+}
+func (m *VerificationResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_VerificationResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_VerificationResponse proto.InternalMessageInfo
 
 func (m *VerificationResponse) GetEmail() string {
 	if m != nil {
@@ -106,16 +178,124 @@ func (m *VerificationResponse) GetId() uint32 {
 	return 0
 }
 
+func (m *VerificationResponse) GetPreviousEmail() string {
+	if m != nil {
+		return m.PreviousEmail
+	}
+	return ""
+}
+
+type RevocationRequest struct {
+	Id                   uint32   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevocationRequest) Reset()         { *m = RevocationRequest{} }
+func (m *RevocationRequest) String() string { return proto.CompactTextString(m) }
+func (*RevocationRequest) ProtoMessage()    {}
+func (*RevocationRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{2}
+}
+
+func (m *RevocationRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevocationRequest.Unmarshal(m, b)
+}
+func (m *RevocationRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevocationRequest.Marshal(b, m, deterministic)
+}
+func (m *RevocationRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevocationRequest.Merge(m, src)
+}
+func (m *RevocationRequest) XXX_Size() int {
+	return xxx_messageInfo_RevocationRequest.Size(m)
+}
+func (m *RevocationRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevocationRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevocationRequest proto.InternalMessageInfo
+
+func (m *RevocationRequest) GetId() uint32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+type RevocationResponse struct {
+	Revoked              int32    `protobuf:"varint,1,opt,name=revoked,proto3" json:"revoked,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevocationResponse) Reset()         { *m = RevocationResponse{} }
+func (m *RevocationResponse) String() string { return proto.CompactTextString(m) }
+func (*RevocationResponse) ProtoMessage()    {}
+func (*RevocationResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{3}
+}
+
+func (m *RevocationResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevocationResponse.Unmarshal(m, b)
+}
+func (m *RevocationResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevocationResponse.Marshal(b, m, deterministic)
+}
+func (m *RevocationResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevocationResponse.Merge(m, src)
+}
+func (m *RevocationResponse) XXX_Size() int {
+	return xxx_messageInfo_RevocationResponse.Size(m)
+}
+func (m *RevocationResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevocationResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevocationResponse proto.InternalMessageInfo
+
+func (m *RevocationResponse) GetRevoked() int32 {
+	if m != nil {
+		return m.Revoked
+	}
+	return 0
+}
+
 type MailRequest struct {
-	Email string `protobuf:"bytes,1,opt,name=email" json:"email,omitempty"`
-	Id    uint32 `protobuf:"varint,2,opt,name=id" json:"id,omitempty"`
-	Name  string `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	Email                string   `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Id                   uint32   `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string   `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *MailRequest) Reset()                    { *m = MailRequest{} }
-func (m *MailRequest) String() string            { return proto.CompactTextString(m) }
-func (*MailRequest) ProtoMessage()               {}
-func (*MailRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (m *MailRequest) Reset()         { *m = MailRequest{} }
+func (m *MailRequest) String() string { return proto.CompactTextString(m) }
+func (*MailRequest) ProtoMessage()    {}
+func (*MailRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{4}
+}
+
+func (m *MailRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MailRequest.Unmarshal(m, b)
+}
+func (m *MailRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MailRequest.Marshal(b, m, deterministic)
+}
+func (m *MailRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MailRequest.Merge(m, src)
+}
+func (m *MailRequest) XXX_Size() int {
+	return xxx_messageInfo_MailRequest.Size(m)
+}
+func (m *MailRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_MailRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MailRequest proto.InternalMessageInfo
 
 func (m *MailRequest) GetEmail() string {
 	if m != nil {
@@ -138,15 +318,102 @@ func (m *MailRequest) GetName() string {
 	return ""
 }
 
+type EmailChangeRequest struct {
+	Email                string   `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Id                   uint32   `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string   `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	PreviousEmail        string   `protobuf:"bytes,4,opt,name=previous_email,json=previousEmail,proto3" json:"previous_email,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EmailChangeRequest) Reset()         { *m = EmailChangeRequest{} }
+func (m *EmailChangeRequest) String() string { return proto.CompactTextString(m) }
+func (*EmailChangeRequest) ProtoMessage()    {}
+func (*EmailChangeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{5}
+}
+
+func (m *EmailChangeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EmailChangeRequest.Unmarshal(m, b)
+}
+func (m *EmailChangeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EmailChangeRequest.Marshal(b, m, deterministic)
+}
+func (m *EmailChangeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EmailChangeRequest.Merge(m, src)
+}
+func (m *EmailChangeRequest) XXX_Size() int {
+	return xxx_messageInfo_EmailChangeRequest.Size(m)
+}
+func (m *EmailChangeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_EmailChangeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EmailChangeRequest proto.InternalMessageInfo
+
+func (m *EmailChangeRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *EmailChangeRequest) GetId() uint32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *EmailChangeRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *EmailChangeRequest) GetPreviousEmail() string {
+	if m != nil {
+		return m.PreviousEmail
+	}
+	return ""
+}
+
 type MailResponse struct {
-	Status string `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
-	Code   int32  `protobuf:"varint,2,opt,name=code" json:"code,omitempty"`
+	Status               string   `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Code                 int32    `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	Id                   string   `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *MailResponse) Reset()                    { *m = MailResponse{} }
-func (m *MailResponse) String() string            { return proto.CompactTextString(m) }
-func (*MailResponse) ProtoMessage()               {}
-func (*MailResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (m *MailResponse) Reset()         { *m = MailResponse{} }
+func (m *MailResponse) String() string { return proto.CompactTextString(m) }
+func (*MailResponse) ProtoMessage()    {}
+func (*MailResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{6}
+}
+
+func (m *MailResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MailResponse.Unmarshal(m, b)
+}
+func (m *MailResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MailResponse.Marshal(b, m, deterministic)
+}
+func (m *MailResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MailResponse.Merge(m, src)
+}
+func (m *MailResponse) XXX_Size() int {
+	return xxx_messageInfo_MailResponse.Size(m)
+}
+func (m *MailResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MailResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MailResponse proto.InternalMessageInfo
 
 func (m *MailResponse) GetStatus() string {
 	if m != nil {
@@ -162,12 +429,262 @@ func (m *MailResponse) GetCode() int32 {
 	return 0
 }
 
+func (m *MailResponse) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type StatusRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StatusRequest) Reset()         { *m = StatusRequest{} }
+func (m *StatusRequest) String() string { return proto.CompactTextString(m) }
+func (*StatusRequest) ProtoMessage()    {}
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{7}
+}
+
+func (m *StatusRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatusRequest.Unmarshal(m, b)
+}
+func (m *StatusRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatusRequest.Marshal(b, m, deterministic)
+}
+func (m *StatusRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatusRequest.Merge(m, src)
+}
+func (m *StatusRequest) XXX_Size() int {
+	return xxx_messageInfo_StatusRequest.Size(m)
+}
+func (m *StatusRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatusRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatusRequest proto.InternalMessageInfo
+
+func (m *StatusRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type StatusResponse struct {
+	Id                   string               `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	State                StatusResponse_State `protobuf:"varint,2,opt,name=state,proto3,enum=api.StatusResponse_State" json:"state,omitempty"`
+	Attempts             int32                `protobuf:"varint,3,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *StatusResponse) Reset()         { *m = StatusResponse{} }
+func (m *StatusResponse) String() string { return proto.CompactTextString(m) }
+func (*StatusResponse) ProtoMessage()    {}
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{8}
+}
+
+func (m *StatusResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatusResponse.Unmarshal(m, b)
+}
+func (m *StatusResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatusResponse.Marshal(b, m, deterministic)
+}
+func (m *StatusResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatusResponse.Merge(m, src)
+}
+func (m *StatusResponse) XXX_Size() int {
+	return xxx_messageInfo_StatusResponse.Size(m)
+}
+func (m *StatusResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatusResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatusResponse proto.InternalMessageInfo
+
+func (m *StatusResponse) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *StatusResponse) GetState() StatusResponse_State {
+	if m != nil {
+		return m.State
+	}
+	return StatusResponse_PENDING
+}
+
+func (m *StatusResponse) GetAttempts() int32 {
+	if m != nil {
+		return m.Attempts
+	}
+	return 0
+}
+
+type PromoteKeyRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PromoteKeyRequest) Reset()         { *m = PromoteKeyRequest{} }
+func (m *PromoteKeyRequest) String() string { return proto.CompactTextString(m) }
+func (*PromoteKeyRequest) ProtoMessage()    {}
+func (*PromoteKeyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{9}
+}
+
+func (m *PromoteKeyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PromoteKeyRequest.Unmarshal(m, b)
+}
+func (m *PromoteKeyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PromoteKeyRequest.Marshal(b, m, deterministic)
+}
+func (m *PromoteKeyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PromoteKeyRequest.Merge(m, src)
+}
+func (m *PromoteKeyRequest) XXX_Size() int {
+	return xxx_messageInfo_PromoteKeyRequest.Size(m)
+}
+func (m *PromoteKeyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PromoteKeyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PromoteKeyRequest proto.InternalMessageInfo
+
+func (m *PromoteKeyRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type PromoteKeyResponse struct {
+	Active               string   `protobuf:"bytes,1,opt,name=active,proto3" json:"active,omitempty"`
+	Previous             string   `protobuf:"bytes,2,opt,name=previous,proto3" json:"previous,omitempty"`
+	Keys                 []string `protobuf:"bytes,3,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PromoteKeyResponse) Reset()         { *m = PromoteKeyResponse{} }
+func (m *PromoteKeyResponse) String() string { return proto.CompactTextString(m) }
+func (*PromoteKeyResponse) ProtoMessage()    {}
+func (*PromoteKeyResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7cda5f053e74676b, []int{10}
+}
+
+func (m *PromoteKeyResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PromoteKeyResponse.Unmarshal(m, b)
+}
+func (m *PromoteKeyResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PromoteKeyResponse.Marshal(b, m, deterministic)
+}
+func (m *PromoteKeyResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PromoteKeyResponse.Merge(m, src)
+}
+func (m *PromoteKeyResponse) XXX_Size() int {
+	return xxx_messageInfo_PromoteKeyResponse.Size(m)
+}
+func (m *PromoteKeyResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_PromoteKeyResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PromoteKeyResponse proto.InternalMessageInfo
+
+func (m *PromoteKeyResponse) GetActive() string {
+	if m != nil {
+		return m.Active
+	}
+	return ""
+}
+
+func (m *PromoteKeyResponse) GetPrevious() string {
+	if m != nil {
+		return m.Previous
+	}
+	return ""
+}
+
+func (m *PromoteKeyResponse) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
 func init() {
+	proto.RegisterEnum("api.VerificationRequest_Purpose", VerificationRequest_Purpose_name, VerificationRequest_Purpose_value)
+	proto.RegisterEnum("api.StatusResponse_State", StatusResponse_State_name, StatusResponse_State_value)
 	proto.RegisterType((*VerificationRequest)(nil), "api.VerificationRequest")
 	proto.RegisterType((*VerificationResponse)(nil), "api.VerificationResponse")
+	proto.RegisterType((*RevocationRequest)(nil), "api.RevocationRequest")
+	proto.RegisterType((*RevocationResponse)(nil), "api.RevocationResponse")
 	proto.RegisterType((*MailRequest)(nil), "api.MailRequest")
+	proto.RegisterType((*EmailChangeRequest)(nil), "api.EmailChangeRequest")
 	proto.RegisterType((*MailResponse)(nil), "api.MailResponse")
-	proto.RegisterEnum("api.VerificationRequest_Purpose", VerificationRequest_Purpose_name, VerificationRequest_Purpose_value)
+	proto.RegisterType((*StatusRequest)(nil), "api.StatusRequest")
+	proto.RegisterType((*StatusResponse)(nil), "api.StatusResponse")
+	proto.RegisterType((*PromoteKeyRequest)(nil), "api.PromoteKeyRequest")
+	proto.RegisterType((*PromoteKeyResponse)(nil), "api.PromoteKeyResponse")
+}
+
+func init() { proto.RegisterFile("mail.proto", fileDescriptor_7cda5f053e74676b) }
+
+var fileDescriptor_7cda5f053e74676b = []byte{
+	// 648 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0x4d, 0x6f, 0xd3, 0x40,
+	0x10, 0xad, 0xf3, 0xd1, 0xb4, 0xd3, 0x34, 0x38, 0xdb, 0xd2, 0xa6, 0xb9, 0x50, 0x19, 0x21, 0x55,
+	0x1c, 0x82, 0x54, 0x24, 0x24, 0x2a, 0x24, 0x14, 0x25, 0x6e, 0x08, 0xb4, 0x4e, 0xb4, 0x2e, 0x1f,
+	0x07, 0xa4, 0xc8, 0x24, 0x53, 0x58, 0xb5, 0xf1, 0x1a, 0xef, 0x26, 0xa8, 0x3f, 0x85, 0x9f, 0xc3,
+	0x81, 0xff, 0x85, 0x76, 0xd7, 0x4e, 0x1d, 0xe2, 0x4a, 0x95, 0x7a, 0xdb, 0x99, 0xbc, 0x99, 0x79,
+	0xf3, 0xf6, 0x79, 0x03, 0x30, 0x0d, 0xd8, 0x75, 0x2b, 0x8a, 0xb9, 0xe4, 0xa4, 0x18, 0x44, 0xcc,
+	0xf9, 0x63, 0xc1, 0xce, 0x27, 0x8c, 0xd9, 0x25, 0x1b, 0x07, 0x92, 0xf1, 0x90, 0xe2, 0xcf, 0x19,
+	0x0a, 0x49, 0x76, 0xa1, 0x2c, 0xf9, 0x15, 0x86, 0x0d, 0xeb, 0xd0, 0x3a, 0xda, 0xa4, 0x26, 0x20,
+	0x27, 0x50, 0x89, 0x66, 0x71, 0xc4, 0x05, 0x36, 0x0a, 0x87, 0xd6, 0x51, 0xed, 0xf8, 0xb0, 0x15,
+	0x44, 0xac, 0x95, 0xd3, 0xa0, 0x35, 0x34, 0x38, 0x9a, 0x16, 0x38, 0x5f, 0xa0, 0x92, 0xe4, 0x88,
+	0x0d, 0xd5, 0xce, 0xc0, 0x3b, 0xed, 0xd3, 0xf3, 0xf6, 0x45, 0x7f, 0xe0, 0xd9, 0x6b, 0x84, 0x40,
+	0x6d, 0xd8, 0xf6, 0xfd, 0xcf, 0x03, 0xda, 0x1d, 0x51, 0xd7, 0x77, 0x2f, 0x6c, 0x4b, 0xa1, 0xdc,
+	0xf3, 0x76, 0xff, 0x6c, 0xd4, 0x79, 0xd7, 0xf6, 0x7a, 0xae, 0x5d, 0x20, 0x8f, 0xa1, 0x9e, 0xcd,
+	0x8c, 0x3e, 0x7a, 0xdd, 0x81, 0x5d, 0x74, 0xc6, 0xb0, 0xbb, 0xcc, 0x40, 0x44, 0x3c, 0x14, 0xa8,
+	0x76, 0x40, 0xb5, 0x6f, 0xba, 0x83, 0x0e, 0x48, 0x0d, 0x0a, 0x6c, 0xa2, 0xe9, 0x6f, 0xd3, 0x02,
+	0x9b, 0x90, 0x67, 0x50, 0x8b, 0x62, 0x9c, 0x33, 0x3e, 0x13, 0x23, 0x03, 0x2f, 0x6a, 0xf8, 0x76,
+	0x9a, 0x75, 0x55, 0xd2, 0x79, 0x0a, 0x75, 0x8a, 0x73, 0xbe, 0xac, 0x92, 0xe9, 0x65, 0xa5, 0xbd,
+	0x9c, 0x16, 0x90, 0x2c, 0x28, 0xe1, 0xd1, 0x80, 0x4a, 0x8c, 0x73, 0x7e, 0x85, 0x06, 0x5a, 0xa6,
+	0x69, 0xe8, 0xf4, 0x60, 0xeb, 0x3c, 0x60, 0xd7, 0x19, 0xd1, 0xef, 0x41, 0x98, 0x40, 0x29, 0x0c,
+	0xa6, 0x98, 0xd0, 0xd4, 0x67, 0x67, 0x06, 0x44, 0xd3, 0xec, 0xfc, 0x08, 0xc2, 0xef, 0xf8, 0xe0,
+	0x7e, 0x39, 0xa2, 0x94, 0xf2, 0x44, 0x79, 0x0f, 0x55, 0xc3, 0x3f, 0xd9, 0x74, 0x0f, 0xd6, 0x85,
+	0x0c, 0xe4, 0x4c, 0x24, 0x13, 0x93, 0x48, 0x8d, 0x18, 0xf3, 0x89, 0x31, 0x4d, 0x99, 0xea, 0x73,
+	0x42, 0xc3, 0x0c, 0x55, 0xda, 0x3d, 0x81, 0x6d, 0x5f, 0xa3, 0x57, 0xc5, 0x35, 0x80, 0xdf, 0x16,
+	0xd4, 0x52, 0x44, 0x32, 0xef, 0x3f, 0x08, 0x79, 0x01, 0x65, 0x35, 0x31, 0x75, 0xe7, 0x81, 0x76,
+	0xe7, 0x72, 0x8d, 0x0e, 0x91, 0x1a, 0x1c, 0x69, 0xc2, 0x46, 0x20, 0x25, 0x4e, 0x23, 0x29, 0x34,
+	0x95, 0x32, 0x5d, 0xc4, 0xce, 0x73, 0x28, 0x6b, 0x2c, 0xd9, 0x82, 0xca, 0xd0, 0xf5, 0xba, 0x7d,
+	0xaf, 0x67, 0xaf, 0x91, 0x0d, 0x28, 0xf9, 0xae, 0xa7, 0xfc, 0x09, 0xb0, 0x7e, 0xda, 0xee, 0x9f,
+	0xb9, 0x5d, 0xbb, 0xa0, 0xdc, 0x31, 0x8c, 0xf9, 0x94, 0x4b, 0xfc, 0x80, 0x37, 0x77, 0x2d, 0xf0,
+	0x15, 0x48, 0x16, 0x74, 0xab, 0x59, 0x30, 0x96, 0x6c, 0x8e, 0xa9, 0x66, 0x26, 0x52, 0xd4, 0x52,
+	0xb1, 0xf5, 0x3a, 0x9b, 0x74, 0x11, 0x2b, 0x3d, 0xaf, 0xf0, 0x46, 0x51, 0x2e, 0xaa, 0x2b, 0x53,
+	0xe7, 0xe3, 0xbf, 0x25, 0x28, 0xa9, 0xcb, 0x20, 0xaf, 0xc1, 0xf6, 0x31, 0x9c, 0x74, 0x78, 0x78,
+	0xc9, 0xe2, 0xa9, 0xb6, 0x22, 0xb1, 0xb5, 0x12, 0x19, 0xaf, 0x35, 0xeb, 0x99, 0x8c, 0x61, 0xe2,
+	0xac, 0x91, 0x13, 0xa8, 0xab, 0xd2, 0x61, 0x20, 0xc4, 0x2f, 0x1e, 0x4f, 0x28, 0x0a, 0x94, 0xf7,
+	0xad, 0x7d, 0x0b, 0x8f, 0x54, 0x6d, 0xc6, 0x86, 0x64, 0x5f, 0xe3, 0x56, 0x8d, 0x99, 0xdf, 0xe0,
+	0x0d, 0xec, 0x64, 0x87, 0x9b, 0x8a, 0xc9, 0x7d, 0xc7, 0x77, 0x61, 0x4b, 0x3f, 0x02, 0x37, 0x17,
+	0xfa, 0xa5, 0x6a, 0xdc, 0xf5, 0x30, 0x35, 0x0f, 0x72, 0x7e, 0x59, 0x74, 0x71, 0xa1, 0xda, 0xe1,
+	0xa1, 0x98, 0x4d, 0xf1, 0x41, 0x6d, 0xda, 0x50, 0xa5, 0xfa, 0x13, 0xd7, 0x5d, 0x04, 0xd9, 0xd3,
+	0xe0, 0x95, 0xf7, 0xa3, 0xb9, 0xbf, 0x92, 0x5f, 0xb4, 0x78, 0x05, 0x9b, 0x3d, 0x94, 0x7e, 0xf2,
+	0xfd, 0x2c, 0x19, 0xd9, 0xd4, 0xee, 0xe4, 0x98, 0x5b, 0x5f, 0x03, 0xdc, 0x9a, 0x2c, 0x19, 0xbc,
+	0x62, 0xcd, 0xe6, 0xfe, 0x4a, 0x3e, 0x6d, 0xf0, 0x6d, 0x5d, 0xff, 0x3b, 0xbc, 0xfc, 0x37, 0x00,
+	0x1b, 0xca, 0x07, 0xf6, 0x2b, 0x06, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -178,12 +695,19 @@ var _ grpc.ClientConn
 // is compatible with the grpc package it is being compiled against.
 const _ = grpc.SupportPackageIsVersion4
 
-// Client API for Mail service
-
+// MailClient is the client API for Mail service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type MailClient interface {
 	SendConfirmation(ctx context.Context, in *MailRequest, opts ...grpc.CallOption) (*MailResponse, error)
 	SendPasswordReset(ctx context.Context, in *MailRequest, opts ...grpc.CallOption) (*MailResponse, error)
+	SendEmailChange(ctx context.Context, in *EmailChangeRequest, opts ...grpc.CallOption) (*MailResponse, error)
+	SendPasswordChanged(ctx context.Context, in *MailRequest, opts ...grpc.CallOption) (*MailResponse, error)
 	VerifyToken(ctx context.Context, in *VerificationRequest, opts ...grpc.CallOption) (*VerificationResponse, error)
+	ConsumeToken(ctx context.Context, in *VerificationRequest, opts ...grpc.CallOption) (*VerificationResponse, error)
+	RevokeTokens(ctx context.Context, in *RevocationRequest, opts ...grpc.CallOption) (*RevocationResponse, error)
+	GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	PromoteKey(ctx context.Context, in *PromoteKeyRequest, opts ...grpc.CallOption) (*PromoteKeyResponse, error)
 }
 
 type mailClient struct {
@@ -196,7 +720,7 @@ func NewMailClient(cc *grpc.ClientConn) MailClient {
 
 func (c *mailClient) SendConfirmation(ctx context.Context, in *MailRequest, opts ...grpc.CallOption) (*MailResponse, error) {
 	out := new(MailResponse)
-	err := grpc.Invoke(ctx, "/api.Mail/SendConfirmation", in, out, c.cc, opts...)
+	err := c.cc.Invoke(ctx, "/api.Mail/SendConfirmation", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -205,7 +729,25 @@ func (c *mailClient) SendConfirmation(ctx context.Context, in *MailRequest, opts
 
 func (c *mailClient) SendPasswordReset(ctx context.Context, in *MailRequest, opts ...grpc.CallOption) (*MailResponse, error) {
 	out := new(MailResponse)
-	err := grpc.Invoke(ctx, "/api.Mail/SendPasswordReset", in, out, c.cc, opts...)
+	err := c.cc.Invoke(ctx, "/api.Mail/SendPasswordReset", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mailClient) SendEmailChange(ctx context.Context, in *EmailChangeRequest, opts ...grpc.CallOption) (*MailResponse, error) {
+	out := new(MailResponse)
+	err := c.cc.Invoke(ctx, "/api.Mail/SendEmailChange", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mailClient) SendPasswordChanged(ctx context.Context, in *MailRequest, opts ...grpc.CallOption) (*MailResponse, error) {
+	out := new(MailResponse)
+	err := c.cc.Invoke(ctx, "/api.Mail/SendPasswordChanged", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -214,19 +756,92 @@ func (c *mailClient) SendPasswordReset(ctx context.Context, in *MailRequest, opt
 
 func (c *mailClient) VerifyToken(ctx context.Context, in *VerificationRequest, opts ...grpc.CallOption) (*VerificationResponse, error) {
 	out := new(VerificationResponse)
-	err := grpc.Invoke(ctx, "/api.Mail/VerifyToken", in, out, c.cc, opts...)
+	err := c.cc.Invoke(ctx, "/api.Mail/VerifyToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mailClient) ConsumeToken(ctx context.Context, in *VerificationRequest, opts ...grpc.CallOption) (*VerificationResponse, error) {
+	out := new(VerificationResponse)
+	err := c.cc.Invoke(ctx, "/api.Mail/ConsumeToken", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// Server API for Mail service
+func (c *mailClient) RevokeTokens(ctx context.Context, in *RevocationRequest, opts ...grpc.CallOption) (*RevocationResponse, error) {
+	out := new(RevocationResponse)
+	err := c.cc.Invoke(ctx, "/api.Mail/RevokeTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mailClient) GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, "/api.Mail/GetStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
+func (c *mailClient) PromoteKey(ctx context.Context, in *PromoteKeyRequest, opts ...grpc.CallOption) (*PromoteKeyResponse, error) {
+	out := new(PromoteKeyResponse)
+	err := c.cc.Invoke(ctx, "/api.Mail/PromoteKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MailServer is the server API for Mail service.
 type MailServer interface {
 	SendConfirmation(context.Context, *MailRequest) (*MailResponse, error)
 	SendPasswordReset(context.Context, *MailRequest) (*MailResponse, error)
+	SendEmailChange(context.Context, *EmailChangeRequest) (*MailResponse, error)
+	SendPasswordChanged(context.Context, *MailRequest) (*MailResponse, error)
 	VerifyToken(context.Context, *VerificationRequest) (*VerificationResponse, error)
+	ConsumeToken(context.Context, *VerificationRequest) (*VerificationResponse, error)
+	RevokeTokens(context.Context, *RevocationRequest) (*RevocationResponse, error)
+	GetStatus(context.Context, *StatusRequest) (*StatusResponse, error)
+	PromoteKey(context.Context, *PromoteKeyRequest) (*PromoteKeyResponse, error)
+}
+
+// UnimplementedMailServer can be embedded to have forward compatible implementations.
+type UnimplementedMailServer struct {
+}
+
+func (*UnimplementedMailServer) SendConfirmation(ctx context.Context, req *MailRequest) (*MailResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendConfirmation not implemented")
+}
+func (*UnimplementedMailServer) SendPasswordReset(ctx context.Context, req *MailRequest) (*MailResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendPasswordReset not implemented")
+}
+func (*UnimplementedMailServer) SendEmailChange(ctx context.Context, req *EmailChangeRequest) (*MailResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendEmailChange not implemented")
+}
+func (*UnimplementedMailServer) SendPasswordChanged(ctx context.Context, req *MailRequest) (*MailResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendPasswordChanged not implemented")
+}
+func (*UnimplementedMailServer) VerifyToken(ctx context.Context, req *VerificationRequest) (*VerificationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyToken not implemented")
+}
+func (*UnimplementedMailServer) ConsumeToken(ctx context.Context, req *VerificationRequest) (*VerificationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConsumeToken not implemented")
+}
+func (*UnimplementedMailServer) RevokeTokens(ctx context.Context, req *RevocationRequest) (*RevocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeTokens not implemented")
+}
+func (*UnimplementedMailServer) GetStatus(ctx context.Context, req *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (*UnimplementedMailServer) PromoteKey(ctx context.Context, req *PromoteKeyRequest) (*PromoteKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PromoteKey not implemented")
 }
 
 func RegisterMailServer(s *grpc.Server, srv MailServer) {
@@ -269,6 +884,42 @@ func _Mail_SendPasswordReset_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Mail_SendEmailChange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmailChangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailServer).SendEmailChange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Mail/SendEmailChange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailServer).SendEmailChange(ctx, req.(*EmailChangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mail_SendPasswordChanged_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailServer).SendPasswordChanged(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Mail/SendPasswordChanged",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailServer).SendPasswordChanged(ctx, req.(*MailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Mail_VerifyToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(VerificationRequest)
 	if err := dec(in); err != nil {
@@ -287,6 +938,78 @@ func _Mail_VerifyToken_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Mail_ConsumeToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerificationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailServer).ConsumeToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Mail/ConsumeToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailServer).ConsumeToken(ctx, req.(*VerificationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mail_RevokeTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailServer).RevokeTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Mail/RevokeTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailServer).RevokeTokens(ctx, req.(*RevocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mail_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Mail/GetStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailServer).GetStatus(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mail_PromoteKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PromoteKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailServer).PromoteKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Mail/PromoteKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailServer).PromoteKey(ctx, req.(*PromoteKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Mail_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "api.Mail",
 	HandlerType: (*MailServer)(nil),
@@ -299,38 +1022,35 @@ var _Mail_serviceDesc = grpc.ServiceDesc{
 			MethodName: "SendPasswordReset",
 			Handler:    _Mail_SendPasswordReset_Handler,
 		},
+		{
+			MethodName: "SendEmailChange",
+			Handler:    _Mail_SendEmailChange_Handler,
+		},
+		{
+			MethodName: "SendPasswordChanged",
+			Handler:    _Mail_SendPasswordChanged_Handler,
+		},
 		{
 			MethodName: "VerifyToken",
 			Handler:    _Mail_VerifyToken_Handler,
 		},
+		{
+			MethodName: "ConsumeToken",
+			Handler:    _Mail_ConsumeToken_Handler,
+		},
+		{
+			MethodName: "RevokeTokens",
+			Handler:    _Mail_RevokeTokens_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Mail_GetStatus_Handler,
+		},
+		{
+			MethodName: "PromoteKey",
+			Handler:    _Mail_PromoteKey_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "mail.proto",
 }
-
-func init() { proto.RegisterFile("mail.proto", fileDescriptor0) }
-
-var fileDescriptor0 = []byte{
-	// 326 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x52, 0x4d, 0x4f, 0x02, 0x31,
-	0x10, 0x65, 0xf9, 0x8c, 0x03, 0x92, 0x65, 0x24, 0x66, 0xe5, 0x44, 0x7a, 0xe2, 0xb4, 0x26, 0x78,
-	0x92, 0x78, 0x21, 0x80, 0x86, 0x03, 0x1f, 0xe9, 0x12, 0x3d, 0x9a, 0xca, 0x96, 0xa4, 0x11, 0xda,
-	0xba, 0x2d, 0x31, 0xfe, 0x13, 0xff, 0x8f, 0x7f, 0xcc, 0x6c, 0x77, 0x49, 0x30, 0xc1, 0xe8, 0x6d,
-	0x66, 0x3a, 0xef, 0xcd, 0x9b, 0x37, 0x05, 0xd8, 0x31, 0xb1, 0x0d, 0x75, 0xa2, 0xac, 0xc2, 0x12,
-	0xd3, 0x82, 0x7c, 0x7a, 0x70, 0xf1, 0xc8, 0x13, 0xb1, 0x11, 0x6b, 0x66, 0x85, 0x92, 0x94, 0xbf,
-	0xed, 0xb9, 0xb1, 0xd8, 0x86, 0x8a, 0x55, 0xaf, 0x5c, 0x06, 0x5e, 0xd7, 0xeb, 0x9d, 0xd1, 0x2c,
-	0xc1, 0x01, 0xd4, 0xf4, 0x3e, 0xd1, 0xca, 0xf0, 0xa0, 0xd8, 0xf5, 0x7a, 0xcd, 0x7e, 0x37, 0x64,
-	0x5a, 0x84, 0x27, 0x08, 0xc2, 0x65, 0xd6, 0x47, 0x0f, 0x00, 0x72, 0x0d, 0xb5, 0xbc, 0x86, 0x3e,
-	0x34, 0x46, 0x8b, 0xf9, 0xfd, 0x94, 0xce, 0x86, 0xab, 0xe9, 0x62, 0xee, 0x17, 0x10, 0xa1, 0xb9,
-	0x1c, 0x46, 0xd1, 0xd3, 0x82, 0x8e, 0x9f, 0xe9, 0x24, 0x9a, 0xac, 0x7c, 0x8f, 0xdc, 0x41, 0xfb,
-	0x27, 0xb1, 0xd1, 0x4a, 0x1a, 0x9e, 0x4a, 0xe3, 0xe9, 0x1a, 0x07, 0x69, 0x2e, 0xc1, 0x26, 0x14,
-	0x45, 0xec, 0x54, 0x9d, 0xd3, 0xa2, 0x88, 0xc9, 0x03, 0xd4, 0x67, 0x4c, 0x6c, 0x8f, 0xf6, 0xf9,
-	0x1b, 0x84, 0x08, 0x65, 0xc9, 0x76, 0x3c, 0x28, 0xb9, 0x26, 0x17, 0x93, 0x01, 0x34, 0x32, 0xa2,
-	0x7c, 0xfc, 0x25, 0x54, 0x8d, 0x65, 0x76, 0x6f, 0x72, 0xaa, 0x3c, 0x4b, 0xb1, 0x6b, 0x15, 0x67,
-	0xc6, 0x54, 0xa8, 0x8b, 0xfb, 0x5f, 0x1e, 0x94, 0x53, 0x30, 0xde, 0x82, 0x1f, 0x71, 0x19, 0x8f,
-	0x94, 0xdc, 0x88, 0x64, 0xe7, 0xf6, 0x41, 0xdf, 0x79, 0x77, 0x24, 0xb2, 0xd3, 0x3a, 0xaa, 0x64,
-	0xd3, 0x48, 0x01, 0x07, 0xd0, 0x4a, 0xa1, 0x4b, 0x66, 0xcc, 0xbb, 0x4a, 0x62, 0xca, 0x0d, 0xb7,
-	0xff, 0xc5, 0x8e, 0xa1, 0xee, 0x2c, 0xfc, 0x58, 0xb9, 0xf3, 0x05, 0xbf, 0x5d, 0xab, 0x73, 0x75,
-	0xe2, 0xe5, 0xc0, 0xf2, 0x52, 0x75, 0xff, 0xe5, 0xe6, 0x3b, 0x00, 0x00, 0xff, 0xff, 0x1e, 0x90,
-	0x5b, 0xa7, 0x3d, 0x02, 0x00, 0x00,
-}
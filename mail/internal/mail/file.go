@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// FileTransport writes messages as .eml files into a directory instead of delivering them.
+// It is meant for development, testing and CI setups.
+type FileTransport struct {
+	dir string
+}
+
+// NewFileTransport creates a new transport writing into the given directory, creating it if necessary.
+func NewFileTransport(dir string) (*FileTransport, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "could not create mail directory")
+	}
+	return &FileTransport{dir}, nil
+}
+
+// Send writes the message into the mail directory.
+// The file is written under a temporary name first, so readers never observe partial messages.
+func (t *FileTransport) Send(ctx context.Context, msg *Message) error {
+	tmp, err := ioutil.TempFile(t.dir, ".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "could not create message file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := msg.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "could not write message file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "could not write message file")
+	}
+	name := fmt.Sprintf("%s-%s.eml", msg.Date.Format("20060102T150405"), strings.SplitN(msg.ID, "@", 2)[0])
+	if err := os.Rename(tmp.Name(), filepath.Join(t.dir, name)); err != nil {
+		return errors.Wrap(err, "could not move message file")
+	}
+	return nil
+}
+
+// Ping checks if the mail directory is accessible.
+func (t *FileTransport) Ping(ctx context.Context) error {
+	info, err := os.Stat(t.dir)
+	if err != nil {
+		return errors.Wrap(err, "could not access mail directory")
+	}
+	if !info.IsDir() {
+		return errors.Errorf("%s is not a directory", t.dir)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package mail
 
 import (
 	"bytes"
+	"crypto/rand"
 	"fmt"
 	"html/template"
 	"time"
@@ -11,11 +12,11 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/lnsp/microlog/common/keyring"
 	"github.com/lnsp/microlog/common/logger"
 	"github.com/lnsp/microlog/mail/api"
+	"github.com/lnsp/microlog/mail/internal/mail/models"
 	"github.com/pkg/errors"
-	"github.com/sendgrid/sendgrid-go"
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 
@@ -25,27 +26,39 @@ import (
 var log = logger.New()
 
 const (
-	resetSubject   = "Reset your email"
-	confirmSubject = "Please confirm your email"
+	resetSubject        = "Reset your email"
+	confirmSubject      = "Please confirm your email"
+	changeSubject       = "Please confirm your new email"
+	changeNoticeSubject = "Your email is being changed"
+	passwordSubject     = "Your password has been changed"
 )
 
 // Config stores the service configuration.
 type Config struct {
-	Secret                  []byte
+	Keys                    *keyring.Keyring
 	ConfirmURL, ResetURL    string
+	ChangeURL, UndoURL      string
+	ForgotURL               string
 	SenderName, SenderEmail string
-	APIKey                  string
+	Transport               Transport
 	TemplateFolder          string
+	Datasource              string
+	Queue                   QueueConfig
 }
 
 // Server is the implementation of the mail gRPC service.
 type Server struct {
-	secret                          []byte
-	sender                          *mail.Email
-	mail                            *sendgrid.Client
+	keys                            *keyring.Keyring
+	senderName, senderEmail         string
+	transport                       Transport
+	db                              *models.DB
+	queue                           QueueConfig
 	forgotTemplate, confirmTemplate *template.Template
-	apiKey                          string
+	changeTemplate, noticeTemplate  *template.Template
+	passwordTemplate                *template.Template
 	confirmURL, resetURL            string
+	changeURL, undoURL              string
+	forgotURL                       string
 }
 
 // EmailPurpose defines a transaction email purpose.
@@ -56,6 +69,10 @@ const (
 	EmailConfirmation EmailPurpose = "purpose_confirmation"
 	// EmailPasswordReset is a password reset email.
 	EmailPasswordReset = "purpose_resetpassword"
+	// EmailChange confirms a new address replacing an existing one.
+	EmailChange = "purpose_emailchange"
+	// EmailChangeUndo reverts an email change, it is sent to the previous address.
+	EmailChangeUndo = "purpose_emailchangeundo"
 )
 
 var (
@@ -63,11 +80,15 @@ var (
 	ExpirationTimes = map[EmailPurpose]time.Duration{
 		EmailConfirmation:  time.Hour * 72,
 		EmailPasswordReset: time.Hour,
+		EmailChange:        time.Hour * 24,
+		EmailChangeUndo:    time.Hour * 24 * 7,
 	}
 	// MapPurpose defines a map of verification request purposes to EmailPurposes.
 	MapPurpose = map[api.VerificationRequest_Purpose]EmailPurpose{
-		api.VerificationRequest_CONFIRMATION:   EmailConfirmation,
-		api.VerificationRequest_PASSWORD_RESET: EmailPasswordReset,
+		api.VerificationRequest_CONFIRMATION:      EmailConfirmation,
+		api.VerificationRequest_PASSWORD_RESET:    EmailPasswordReset,
+		api.VerificationRequest_EMAIL_CHANGE:      EmailChange,
+		api.VerificationRequest_EMAIL_CHANGE_UNDO: EmailChangeUndo,
 	}
 )
 
@@ -76,6 +97,8 @@ type EmailInfo struct {
 	Identity     uint32
 	EmailAddress string
 	Purpose      EmailPurpose
+	// PreviousAddress is the address replaced by an email change.
+	PreviousAddress string `json:",omitempty"`
 }
 
 // Claims stores email info in a JWT-compatible way.
@@ -86,62 +109,132 @@ type Claims struct {
 
 type emailContext struct {
 	Name, Link string
+	// Email is the address mentioned in the email, such as the new address of an email change.
+	Email string
+}
+
+// newTokenID generates a random unique token ID.
+func newTokenID() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", errors.Wrap(err, "failed to generate token id")
+	}
+	return fmt.Sprintf("%x", random), nil
 }
 
 // GenerateToken generates a new token based on the given email information.
+// The token ID is recorded, so that the token can be consumed or revoked later on.
 func (s *Server) GenerateToken(info *EmailInfo) (string, error) {
-	expiration := ExpirationTimes[info.Purpose]
+	id, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(ExpirationTimes[info.Purpose])
 	claims := &Claims{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(expiration).Unix(),
+			Id:        id,
+			ExpiresAt: expiresAt.Unix(),
 		},
 		EmailInfo: *info,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(s.secret)
+	signed, err := s.keys.Sign(claims)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to sign claims")
 	}
+	if err := s.db.AddToken(id, info.Identity, string(info.Purpose), expiresAt); err != nil {
+		return "", errors.Wrap(err, "failed to record token")
+	}
 	return signed, nil
 }
 
-// ProofToken checks if the given token string is valid.
-func (s *Server) ProofToken(signed string) (*EmailInfo, error) {
+// ProofToken checks if the given token string has a valid signature and has not expired.
+// It does not check if the token has been consumed or revoked.
+func (s *Server) ProofToken(signed string) (*Claims, error) {
 	var claims Claims
-	_, err := jwt.ParseWithClaims(signed, &claims, func(token *jwt.Token) (interface{}, error) {
-		if token.Method != jwt.SigningMethodHS256 {
-			return nil, errors.New("unexpected signing method")
-		}
-		return s.secret, nil
-	})
+	_, err := jwt.ParseWithClaims(signed, &claims, s.keys.Keyfunc)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse token")
 	}
-	return &claims.EmailInfo, nil
+	return &claims, nil
+}
+
+// proofTokenPurpose checks if the token is valid and has been issued for the requested purpose.
+func (s *Server) proofTokenPurpose(req *api.VerificationRequest) (*Claims, error) {
+	claims, err := s.ProofToken(req.Token)
+	if err != nil {
+		return nil, errors.Wrap(err, "verification failed")
+	}
+	if claims.Purpose != MapPurpose[req.Purpose] {
+		return nil, errors.New("purpose does not match")
+	}
+	return claims, nil
 }
 
-// VerifyToken verifies the given special-purpose token.
+// VerifyToken verifies the given special-purpose token without using it up.
 func (s *Server) VerifyToken(ctx context.Context, req *api.VerificationRequest) (*api.VerificationResponse, error) {
 	log := log.WithFields(logrus.Fields{
 		"purpose": req.Purpose,
 		"token":   req.Token,
 	})
-	info, err := s.ProofToken(req.Token)
+	claims, err := s.proofTokenPurpose(req)
 	if err != nil {
 		log.WithError(err).Warn("verification failed")
-		return nil, errors.Wrap(err, "verification failed")
+		return nil, err
 	}
-	if info.Purpose != MapPurpose[req.Purpose] {
-		log.Warn("purpose does not match")
-		return nil, errors.New("purpose does not match")
+	if err := s.db.CheckToken(claims.Id); err != nil {
+		log.WithError(err).Warn("verification failed")
+		return nil, errors.Wrap(err, "verification failed")
 	}
 	log.WithFields(logrus.Fields{
-		"identity": info.Identity,
-		"email":    info.EmailAddress,
+		"identity": claims.Identity,
+		"email":    claims.EmailAddress,
 	}).Debug("verification successful")
 	return &api.VerificationResponse{
-		Email: info.EmailAddress,
-		Id:    info.Identity,
+		Email:         claims.EmailAddress,
+		Id:            claims.Identity,
+		PreviousEmail: claims.PreviousAddress,
+	}, nil
+}
+
+// ConsumeToken verifies the given special-purpose token and marks it as used.
+// A token can only be consumed once, concurrent attempts fail except for one.
+func (s *Server) ConsumeToken(ctx context.Context, req *api.VerificationRequest) (*api.VerificationResponse, error) {
+	log := log.WithFields(logrus.Fields{
+		"purpose": req.Purpose,
+		"token":   req.Token,
+	})
+	claims, err := s.proofTokenPurpose(req)
+	if err != nil {
+		log.WithError(err).Warn("verification failed")
+		return nil, err
+	}
+	if err := s.db.ConsumeToken(claims.Id); err != nil {
+		log.WithError(err).Warn("consumption failed")
+		return nil, errors.Wrap(err, "consumption failed")
+	}
+	log.WithFields(logrus.Fields{
+		"identity": claims.Identity,
+		"email":    claims.EmailAddress,
+	}).Debug("consumed token")
+	return &api.VerificationResponse{
+		Email:         claims.EmailAddress,
+		Id:            claims.Identity,
+		PreviousEmail: claims.PreviousAddress,
+	}, nil
+}
+
+// RevokeTokens revokes all usable tokens of the given identity.
+// Tokens undoing an email change are kept, so that someone who took over the account can not revoke the link sent to the owner.
+func (s *Server) RevokeTokens(ctx context.Context, req *api.RevocationRequest) (*api.RevocationResponse, error) {
+	log := log.WithField("identity", req.Id)
+	revoked, err := s.db.RevokeTokens(req.Id, EmailChangeUndo)
+	if err != nil {
+		log.WithError(err).Error("failed to revoke tokens")
+		return nil, errors.Wrap(err, "failed to revoke tokens")
+	}
+	log.WithField("count", revoked).Debug("revoked tokens")
+	return &api.RevocationResponse{
+		Revoked: int32(revoked),
 	}, nil
 }
 
@@ -167,20 +260,18 @@ func (s *Server) SendConfirmation(ctx context.Context, req *api.MailRequest) (*a
 		log.WithError(err).Warn("failed to render email")
 		return nil, errors.Wrap(err, "failed to render email")
 	}
-	receiver := mail.NewEmail(req.Name, req.Email)
-	message := mail.NewSingleEmail(s.sender, confirmSubject, receiver, buf.String(), buf.String())
-	resp, err := s.mail.Send(message)
+	id, err := s.enqueue(req, confirmSubject, buf.String())
 	if err != nil {
-		log.WithError(err).Warn("failed to send email")
-		return nil, errors.Wrap(err, "failed to send email")
+		log.WithError(err).Warn("failed to enqueue email")
+		return nil, errors.Wrap(err, "failed to enqueue email")
 	}
 	log.WithFields(logrus.Fields{
-		"link":   link,
-		"status": resp.StatusCode,
-	}).Debug("sent confirmation email")
+		"link":    link,
+		"message": id,
+	}).Debug("enqueued confirmation email")
 	return &api.MailResponse{
 		Status: "OK",
-		Code:   int32(resp.StatusCode),
+		Id:     id,
 	}, nil
 }
 
@@ -206,20 +297,178 @@ func (s *Server) SendPasswordReset(ctx context.Context, req *api.MailRequest) (*
 		log.WithError(err).Warn("failed to render email")
 		return nil, errors.Wrap(err, "failed to render email")
 	}
-	receiver := mail.NewEmail(req.Name, req.Email)
-	message := mail.NewSingleEmail(s.sender, resetSubject, receiver, buf.String(), buf.String())
-	resp, err := s.mail.Send(message)
+	id, err := s.enqueue(req, resetSubject, buf.String())
+	if err != nil {
+		log.WithError(err).Warn("failed to enqueue email")
+		return nil, errors.Wrap(err, "failed to enqueue email")
+	}
+	log.WithFields(logrus.Fields{
+		"link":    link,
+		"message": id,
+	}).Debug("enqueued password reset email")
+	return &api.MailResponse{
+		Status: "OK",
+		Id:     id,
+	}, nil
+}
+
+// SendEmailChange sends a confirmation to the new address and a notice with an undo link to the previous one.
+// It returns the ID of the queued confirmation.
+func (s *Server) SendEmailChange(ctx context.Context, req *api.EmailChangeRequest) (*api.MailResponse, error) {
+	log := log.WithFields(logrus.Fields{
+		"email":    req.Email,
+		"previous": req.PreviousEmail,
+		"identity": req.Id,
+		"purpose":  EmailChange,
+	})
+	changeToken, err := s.GenerateToken(&EmailInfo{
+		EmailAddress:    req.Email,
+		PreviousAddress: req.PreviousEmail,
+		Identity:        req.Id,
+		Purpose:         EmailChange,
+	})
+	if err != nil {
+		log.WithError(err).Warn("failed to create token")
+		return nil, errors.Wrap(err, "failed to create token")
+	}
+	undoToken, err := s.GenerateToken(&EmailInfo{
+		EmailAddress:    req.PreviousEmail,
+		PreviousAddress: req.Email,
+		Identity:        req.Id,
+		Purpose:         EmailChangeUndo,
+	})
+	if err != nil {
+		log.WithError(err).Warn("failed to create token")
+		return nil, errors.Wrap(err, "failed to create token")
+	}
+	change, notice := new(bytes.Buffer), new(bytes.Buffer)
+	if err := s.changeTemplate.Execute(change, &emailContext{
+		Name:  req.Name,
+		Link:  fmt.Sprintf(s.changeURL, changeToken),
+		Email: req.PreviousEmail,
+	}); err != nil {
+		log.WithError(err).Warn("failed to render email")
+		return nil, errors.Wrap(err, "failed to render email")
+	}
+	if err := s.noticeTemplate.Execute(notice, &emailContext{
+		Name:  req.Name,
+		Link:  fmt.Sprintf(s.undoURL, undoToken),
+		Email: req.Email,
+	}); err != nil {
+		log.WithError(err).Warn("failed to render email")
+		return nil, errors.Wrap(err, "failed to render email")
+	}
+	id, err := s.enqueue(&api.MailRequest{Email: req.Email, Id: req.Id, Name: req.Name}, changeSubject, change.String())
 	if err != nil {
-		log.WithError(err).Warn("failed to send email")
-		return nil, errors.Wrap(err, "failed to send email")
+		log.WithError(err).Warn("failed to enqueue email")
+		return nil, errors.Wrap(err, "failed to enqueue email")
+	}
+	noticeID, err := s.enqueue(&api.MailRequest{Email: req.PreviousEmail, Id: req.Id, Name: req.Name}, changeNoticeSubject, notice.String())
+	if err != nil {
+		log.WithError(err).Warn("failed to enqueue email")
+		return nil, errors.Wrap(err, "failed to enqueue email")
 	}
 	log.WithFields(logrus.Fields{
-		"link":   link,
-		"status": resp.StatusCode,
-	}).Debug("sent password reset email")
+		"message": id,
+		"notice":  noticeID,
+	}).Debug("enqueued email change")
 	return &api.MailResponse{
 		Status: "OK",
-		Code:   int32(resp.StatusCode),
+		Id:     id,
+	}, nil
+}
+
+// SendPasswordChanged notifies the user that the password of the account has been changed.
+// The notice links to the password reset form, in case the user did not change the password.
+func (s *Server) SendPasswordChanged(ctx context.Context, req *api.MailRequest) (*api.MailResponse, error) {
+	log := log.WithFields(logrus.Fields{
+		"email":    req.Email,
+		"identity": req.Id,
+	})
+	buf := new(bytes.Buffer)
+	if err := s.passwordTemplate.Execute(buf, &emailContext{Name: req.Name, Link: s.forgotURL}); err != nil {
+		log.WithError(err).Warn("failed to render email")
+		return nil, errors.Wrap(err, "failed to render email")
+	}
+	id, err := s.enqueue(req, passwordSubject, buf.String())
+	if err != nil {
+		log.WithError(err).Warn("failed to enqueue email")
+		return nil, errors.Wrap(err, "failed to enqueue email")
+	}
+	log.WithField("message", id).Debug("enqueued password change notice")
+	return &api.MailResponse{
+		Status: "OK",
+		Id:     id,
+	}, nil
+}
+
+// enqueue stores the rendered email for delivery to the receiver given in the request.
+// It returns the ID of the queued message.
+func (s *Server) enqueue(req *api.MailRequest, subject, content string) (string, error) {
+	id, err := newMessageID(s.senderEmail)
+	if err != nil {
+		return "", err
+	}
+	if err := s.db.Enqueue(&models.Message{
+		ID:          id,
+		FromName:    s.senderName,
+		FromAddress: s.senderEmail,
+		ToName:      req.Name,
+		ToAddress:   req.Email,
+		Subject:     subject,
+		Content:     content,
+	}); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// MapState defines a map of queue states to status response states.
+var MapState = map[string]api.StatusResponse_State{
+	models.StatePending: api.StatusResponse_PENDING,
+	models.StateSent:    api.StatusResponse_SENT,
+	models.StateFailed:  api.StatusResponse_FAILED,
+}
+
+// GetStatus returns the delivery status of a queued email.
+func (s *Server) GetStatus(ctx context.Context, req *api.StatusRequest) (*api.StatusResponse, error) {
+	msg, err := s.db.Get(req.Id)
+	if err == models.ErrMessageNotFound {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if err != nil {
+		log.WithField("message", req.Id).WithError(err).Error("failed to get message")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &api.StatusResponse{
+		Id:       msg.ID,
+		State:    MapState[msg.State],
+		Attempts: int32(msg.Attempts),
+	}, nil
+}
+
+// AdminMethods are the methods only admins may call, since they change the configuration of all replicas.
+var AdminMethods = []string{"/api.Mail/PromoteKey"}
+
+// PromoteKey makes the given key the signing key for new tokens.
+// Tokens signed by the previous key stay valid as long as it is part of the keyring.
+func (s *Server) PromoteKey(ctx context.Context, req *api.PromoteKeyRequest) (*api.PromoteKeyResponse, error) {
+	log := log.WithField("key", req.Id)
+	previous, err := s.keys.Promote(req.Id)
+	if errors.Cause(err) == keyring.ErrUnknownKey {
+		log.WithError(err).Warn("attempt to promote unknown key")
+		return nil, status.Error(codes.NotFound, "key not found")
+	} else if err == keyring.ErrNoKeyFile {
+		log.WithError(err).Warn("attempt to promote key without key file")
+		return nil, status.Error(codes.FailedPrecondition, "promoting keys requires a key file shared by all replicas")
+	} else if err != nil {
+		log.WithError(err).Error("failed to promote key")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.WithField("previous", previous).Info("promoted signing key")
+	return &api.PromoteKeyResponse{
+		Active:   req.Id,
+		Previous: previous,
+		Keys:     s.keys.IDs(),
 	}, nil
 }
 
@@ -234,9 +483,14 @@ type healthServer struct {
 
 func (h *healthServer) Check(ctx context.Context, req *health.HealthCheckRequest) (*health.HealthCheckResponse, error) {
 	// We will ignore the service parameter for now since we only implement one service per package.
-	// Check for sendgrid if everything works since mail is important
-	_, err := sendgrid.MakeRequest(sendgrid.GetRequest(h.s.apiKey, "/api/v3/alerts", ""))
-	if err != nil {
+	// Check if the transport works since mail is important
+	if err := h.s.transport.Ping(ctx); err != nil {
+		log.WithError(err).Error("mail transport unavailable")
+		return &health.HealthCheckResponse{Status: health.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	// The queue is needed to accept any emails at all
+	if err := h.s.db.Ping(); err != nil {
+		log.WithError(err).Error("postgresql storage unreachable")
 		return &health.HealthCheckResponse{Status: health.HealthCheckResponse_NOT_SERVING}, nil
 	}
 	return &health.HealthCheckResponse{Status: health.HealthCheckResponse_SERVING}, nil
@@ -250,13 +504,29 @@ func (h *healthServer) Watch(req *health.HealthCheckRequest, stream health.Healt
 func NewServer(cfg *Config) *Server {
 	forgotTemplate := template.Must(template.ParseFiles(cfg.TemplateFolder + "/forgot.html"))
 	confirmTemplate := template.Must(template.ParseFiles(cfg.TemplateFolder + "/confirm.html"))
+	changeTemplate := template.Must(template.ParseFiles(cfg.TemplateFolder + "/change.html"))
+	noticeTemplate := template.Must(template.ParseFiles(cfg.TemplateFolder + "/changeNotice.html"))
+	passwordTemplate := template.Must(template.ParseFiles(cfg.TemplateFolder + "/passwordChanged.html"))
+	data, err := models.Open(cfg.Datasource)
+	if err != nil {
+		log.WithError(err).Fatal("could not connect to data source")
+	}
 	return &Server{
-		sender:          mail.NewEmail(cfg.SenderName, cfg.SenderEmail),
-		mail:            sendgrid.NewSendClient(cfg.APIKey),
-		apiKey:          cfg.APIKey,
-		forgotTemplate:  forgotTemplate,
-		confirmTemplate: confirmTemplate,
-		resetURL:        cfg.ResetURL,
-		confirmURL:      cfg.ConfirmURL,
+		keys:             cfg.Keys,
+		senderName:       cfg.SenderName,
+		senderEmail:      cfg.SenderEmail,
+		transport:        cfg.Transport,
+		db:               data,
+		queue:            cfg.Queue,
+		forgotTemplate:   forgotTemplate,
+		confirmTemplate:  confirmTemplate,
+		changeTemplate:   changeTemplate,
+		noticeTemplate:   noticeTemplate,
+		passwordTemplate: passwordTemplate,
+		resetURL:         cfg.ResetURL,
+		confirmURL:       cfg.ConfirmURL,
+		changeURL:        cfg.ChangeURL,
+		undoURL:          cfg.UndoURL,
+		forgotURL:        cfg.ForgotURL,
 	}
 }
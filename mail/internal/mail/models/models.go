@@ -0,0 +1,246 @@
+// Package models provides the persistent outbound mail queue and token store.
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lnsp/microlog/common/logger"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+var log = logger.New()
+
+const (
+	// StatePending marks messages waiting for their next delivery attempt.
+	StatePending = "pending"
+	// StateSent marks messages accepted by the transport.
+	StateSent = "sent"
+	// StateFailed marks dead-lettered messages that will not be retried.
+	StateFailed = "failed"
+)
+
+var (
+	// ErrMessageNotFound is returned if no message with the given ID exists.
+	ErrMessageNotFound = errors.New("could not find message")
+	// ErrQueueEmpty is returned if no message is due for delivery.
+	ErrQueueEmpty = errors.New("no message due")
+	// ErrTokenInvalid is returned if the token is unknown, expired, revoked or already consumed.
+	ErrTokenInvalid = errors.New("token invalid")
+)
+
+// Message is a queued outbound email.
+type Message struct {
+	ID          string `gorm:"primary_key"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FromName    string
+	FromAddress string
+	ToName      string
+	ToAddress   string
+	Subject     string
+	Content     string `gorm:"type:text"`
+	State       string `gorm:"index"`
+	Attempts    int
+	NextAttempt time.Time `gorm:"index"`
+	LastError   string
+}
+
+// Token records an issued email token, so it can be consumed or revoked before it expires.
+type Token struct {
+	ID         string `gorm:"primary_key"`
+	CreatedAt  time.Time
+	Identity   uint32 `gorm:"index"`
+	Purpose    string
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+type DB struct {
+	db *gorm.DB
+}
+
+func (d *DB) Ping() error {
+	if err := d.db.Exec("select 1").Error; err != nil {
+		return errors.Wrap(err, "ping failed")
+	}
+	return nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Enqueue stores the message for immediate delivery.
+func (d *DB) Enqueue(msg *Message) error {
+	msg.State = StatePending
+	msg.NextAttempt = time.Now()
+	if err := d.db.Create(msg).Error; err != nil {
+		return errors.Wrap(err, "could not enqueue message")
+	}
+	return nil
+}
+
+// Get retrieves the message with the given ID.
+// It returns the message and an error if the message does not exist.
+func (d *DB) Get(id string) (*Message, error) {
+	var msg Message
+	if err := d.db.Where("id = ?", id).First(&msg).Error; gorm.IsRecordNotFoundError(err) {
+		return nil, ErrMessageNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not get message")
+	}
+	return &msg, nil
+}
+
+// Claim picks the pending message that is due the longest and reserves it for the given lease duration.
+// Locked rows are skipped, so multiple service replicas can claim messages concurrently.
+// If the claimer does not report back within the lease, the message becomes due again.
+// It returns the message with its attempt counter already increased or ErrQueueEmpty if no message is due.
+func (d *DB) Claim(lease time.Duration) (*Message, error) {
+	tx := d.db.Begin()
+	if tx.Error != nil {
+		return nil, errors.Wrap(tx.Error, "could not begin transaction")
+	}
+	var msg Message
+	err := tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Where("state = ? AND next_attempt <= ?", StatePending, time.Now()).
+		Order("next_attempt asc").
+		First(&msg).Error
+	if gorm.IsRecordNotFoundError(err) {
+		tx.Rollback()
+		return nil, ErrQueueEmpty
+	} else if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrap(err, "could not find due message")
+	}
+	msg.Attempts++
+	msg.NextAttempt = time.Now().Add(lease)
+	if err := tx.Model(&msg).Updates(map[string]interface{}{
+		"attempts":     msg.Attempts,
+		"next_attempt": msg.NextAttempt,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.Wrap(err, "could not reserve message")
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.Wrap(err, "could not commit transaction")
+	}
+	return &msg, nil
+}
+
+// MarkSent marks the message as delivered.
+// The content is discarded since it may contain confidential links.
+func (d *DB) MarkSent(id string) error {
+	return d.update(id, map[string]interface{}{
+		"state":      StateSent,
+		"content":    "",
+		"last_error": "",
+	})
+}
+
+// Retry schedules another delivery attempt of the message at the given time.
+func (d *DB) Retry(id string, at time.Time, reason string) error {
+	return d.update(id, map[string]interface{}{
+		"next_attempt": at,
+		"last_error":   reason,
+	})
+}
+
+// MarkFailed moves the message into the dead-letter state.
+// The content is kept for inspection.
+func (d *DB) MarkFailed(id string, reason string) error {
+	return d.update(id, map[string]interface{}{
+		"state":      StateFailed,
+		"last_error": reason,
+	})
+}
+
+// AddToken records a newly issued token.
+func (d *DB) AddToken(id string, identity uint32, purpose string, expiresAt time.Time) error {
+	token := Token{
+		ID:        id,
+		Identity:  identity,
+		Purpose:   purpose,
+		ExpiresAt: expiresAt,
+	}
+	if err := d.db.Create(&token).Error; err != nil {
+		return errors.Wrap(err, "could not add token")
+	}
+	return nil
+}
+
+// activeTokens selects the tokens which have neither expired nor been consumed or revoked.
+func (d *DB) activeTokens() *gorm.DB {
+	return d.db.Model(&Token{}).Where("consumed_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now())
+}
+
+// CheckToken checks if the token is still usable.
+// It returns ErrTokenInvalid if the token is unknown, expired, revoked or already consumed.
+func (d *DB) CheckToken(id string) error {
+	var count int
+	if err := d.activeTokens().Where("id = ?", id).Count(&count).Error; err != nil {
+		return errors.Wrap(err, "could not check token")
+	}
+	if count == 0 {
+		return ErrTokenInvalid
+	}
+	return nil
+}
+
+// ConsumeToken marks the token as used.
+// Concurrent calls are safe, only one of them succeeds.
+// It returns ErrTokenInvalid if the token is unknown, expired, revoked or already consumed.
+func (d *DB) ConsumeToken(id string) error {
+	result := d.activeTokens().Where("id = ?", id).Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "could not consume token")
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenInvalid
+	}
+	return nil
+}
+
+// RevokeTokens revokes all usable tokens of the given identity, except for the tokens with one of the kept purposes.
+// It returns the number of revoked tokens.
+func (d *DB) RevokeTokens(identity uint32, keep ...string) (int64, error) {
+	query := d.activeTokens().Where("identity = ?", identity)
+	if len(keep) > 0 {
+		query = query.Where("purpose NOT IN (?)", keep)
+	}
+	result := query.Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "could not revoke tokens")
+	}
+	return result.RowsAffected, nil
+}
+
+func (d *DB) update(id string, attrs map[string]interface{}) error {
+	result := d.db.Model(&Message{}).Where("id = ?", id).Updates(attrs)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "could not update message")
+	}
+	if result.RowsAffected == 0 {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+func Open(path string) (*DB, error) {
+	log.WithFields(logrus.Fields{
+		"path": path,
+		"type": "postgres",
+	}).Info("accessing database")
+	db, err := gorm.Open("postgres", path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to data source")
+	}
+	db.SetLogger(log)
+	db.AutoMigrate(&Message{}, &Token{})
+	return &DB{db}, nil
+}
@@ -0,0 +1,107 @@
+package mail
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/lnsp/microlog/mail/internal/mail/models"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	// queuePollInterval is the time a worker waits after finding the queue empty.
+	queuePollInterval = time.Second
+	// queueSendTimeout limits a single delivery attempt.
+	queueSendTimeout = time.Minute
+	// queueLease is the time a claimed message is reserved for a worker.
+	// It outlasts the send timeout, so a slow attempt finishes and records its result
+	// before another worker may claim the message and deliver it a second time.
+	queueLease = 2 * queueSendTimeout
+)
+
+// QueueConfig stores the delivery settings of the outbound mail queue.
+type QueueConfig struct {
+	// Workers is the number of concurrent delivery workers per service instance.
+	Workers int
+	// MaxAttempts is the number of delivery attempts before a message is dead-lettered.
+	MaxAttempts int
+	// RetryDelay is the delay after the first failed attempt, it doubles with each further attempt.
+	RetryDelay time.Duration
+	// MaxRetryDelay caps the delay between two attempts.
+	MaxRetryDelay time.Duration
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts.
+// The delay grows exponentially and is randomized to spread retries of concurrent failures.
+func (cfg *QueueConfig) backoff(attempts int) time.Duration {
+	delay := cfg.RetryDelay
+	for i := 1; i < attempts && delay < cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > cfg.MaxRetryDelay {
+		delay = cfg.MaxRetryDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// RunQueue starts the configured number of delivery workers.
+// The workers stop once the given context is done.
+func (s *Server) RunQueue(ctx context.Context) {
+	for i := 0; i < s.queue.Workers; i++ {
+		go s.deliverLoop(ctx)
+	}
+}
+
+func (s *Server) deliverLoop(ctx context.Context) {
+	for {
+		err := s.deliverNext(ctx)
+		if err == nil {
+			continue
+		}
+		if err != models.ErrQueueEmpty {
+			log.WithError(err).Error("failed to process mail queue")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(queuePollInterval):
+		}
+	}
+}
+
+// deliverNext claims the next due message and attempts to deliver it.
+// It returns models.ErrQueueEmpty if no message is due.
+func (s *Server) deliverNext(ctx context.Context) error {
+	msg, err := s.db.Claim(queueLease)
+	if err != nil {
+		return err
+	}
+	log := log.WithFields(logrus.Fields{
+		"message": msg.ID,
+		"attempt": msg.Attempts,
+	})
+	sendCtx, cancel := context.WithTimeout(ctx, queueSendTimeout)
+	defer cancel()
+	err = s.transport.Send(sendCtx, &Message{
+		ID:          msg.ID,
+		FromName:    msg.FromName,
+		FromAddress: msg.FromAddress,
+		ToName:      msg.ToName,
+		ToAddress:   msg.ToAddress,
+		Subject:     msg.Subject,
+		HTMLContent: msg.Content,
+		Date:        msg.CreatedAt,
+	})
+	if err == nil {
+		log.Debug("delivered message")
+		return s.db.MarkSent(msg.ID)
+	}
+	if IsPermanent(err) || msg.Attempts >= s.queue.MaxAttempts {
+		log.WithError(err).Error("giving up on message")
+		return s.db.MarkFailed(msg.ID, err.Error())
+	}
+	next := time.Now().Add(s.queue.backoff(msg.Attempts))
+	log.WithError(err).WithField("retry", next).Warn("failed to deliver message")
+	return s.db.Retry(msg.ID, next, err.Error())
+}
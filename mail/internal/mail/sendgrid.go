@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"golang.org/x/net/context"
+)
+
+// SendGridTransport delivers messages using the SendGrid web API.
+type SendGridTransport struct {
+	client *sendgrid.Client
+	apiKey string
+}
+
+// NewSendGridTransport creates a new transport authenticating with the given API key.
+func NewSendGridTransport(apiKey string) *SendGridTransport {
+	return &SendGridTransport{
+		client: sendgrid.NewSendClient(apiKey),
+		apiKey: apiKey,
+	}
+}
+
+// Send delivers the message via the SendGrid mail API.
+func (t *SendGridTransport) Send(ctx context.Context, msg *Message) error {
+	sender := mail.NewEmail(msg.FromName, msg.FromAddress)
+	receiver := mail.NewEmail(msg.ToName, msg.ToAddress)
+	message := mail.NewSingleEmail(sender, msg.Subject, receiver, msg.HTMLContent, msg.HTMLContent)
+	message.SetHeader("Message-ID", "<"+msg.ID+">")
+	resp, err := t.client.Send(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to send email")
+	}
+	// Only malformed requests are permanent, authorization and rate limit errors may be fixed over time.
+	switch {
+	case resp.StatusCode == http.StatusBadRequest, resp.StatusCode == http.StatusRequestEntityTooLarge:
+		return Permanent(errors.Errorf("sendgrid rejected email with status %d", resp.StatusCode))
+	case resp.StatusCode >= http.StatusBadRequest:
+		return errors.Errorf("sendgrid failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Ping checks if the SendGrid API is reachable and accepts the API key.
+func (t *SendGridTransport) Ping(ctx context.Context) error {
+	resp, err := sendgrid.MakeRequest(sendgrid.GetRequest(t.apiKey, "/api/v3/alerts", ""))
+	if err != nil {
+		return errors.Wrap(err, "failed to reach sendgrid")
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("sendgrid responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// smtpTimeout limits SMTP conversations without a context deadline.
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig stores the settings of a SMTP relay.
+type SMTPConfig struct {
+	Host               string
+	Port               int
+	Username, Password string
+	// StartTLS requires the relay to upgrade the connection before authenticating and sending.
+	StartTLS bool
+}
+
+// SMTPTransport delivers messages to a SMTP relay.
+type SMTPTransport struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPTransport creates a new transport delivering to the given relay.
+func NewSMTPTransport(cfg SMTPConfig) *SMTPTransport {
+	return &SMTPTransport{cfg}
+}
+
+// dial opens a new SMTP session, upgrades it to TLS and authenticates if configured.
+func (t *SMTPTransport) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(t.cfg.Host, strconv.Itoa(t.cfg.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial smtp relay %s", addr)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to set deadline")
+	}
+	client, err := smtp.NewClient(conn, t.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to start smtp session")
+	}
+	if t.cfg.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp relay does not support STARTTLS")
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: t.cfg.Host}); err != nil {
+			client.Close()
+			return nil, errors.Wrap(err, "failed to start tls")
+		}
+	}
+	if t.cfg.Username != "" {
+		auth := smtp.PlainAuth("", t.cfg.Username, t.cfg.Password, t.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, errors.Wrap(err, "failed to authenticate")
+		}
+	}
+	return client, nil
+}
+
+// Send delivers the message to the SMTP relay.
+func (t *SMTPTransport) Send(ctx context.Context, msg *Message) error {
+	client, err := t.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Mail(msg.FromAddress); err != nil {
+		return errors.Wrap(classifySMTPError(err), "sender rejected")
+	}
+	if err := client.Rcpt(msg.ToAddress); err != nil {
+		return errors.Wrap(classifySMTPError(err), "receiver rejected")
+	}
+	w, err := client.Data()
+	if err != nil {
+		return errors.Wrap(err, "failed to start data transfer")
+	}
+	if _, err := msg.WriteTo(w); err != nil {
+		w.Close()
+		return errors.Wrap(err, "failed to write message")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(classifySMTPError(err), "message rejected")
+	}
+	if err := client.Quit(); err != nil {
+		return errors.Wrap(err, "failed to quit session")
+	}
+	return nil
+}
+
+// classifySMTPError marks negative completion replies (5xx) of the relay as permanent failures.
+func classifySMTPError(err error) error {
+	if reply, ok := err.(*textproto.Error); ok && reply.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
+
+// Ping checks if a session with the SMTP relay can be established.
+func (t *SMTPTransport) Ping(ctx context.Context) error {
+	client, err := t.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Noop(); err != nil {
+		return errors.Wrap(err, "smtp relay not responding")
+	}
+	return client.Quit()
+}
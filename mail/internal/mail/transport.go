@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// Message is a rendered transactional email ready for delivery.
+type Message struct {
+	ID                    string
+	FromName, FromAddress string
+	ToName, ToAddress     string
+	Subject, HTMLContent  string
+	Date                  time.Time
+}
+
+// Transport delivers messages to their receivers.
+type Transport interface {
+	// Send delivers the message or returns an error if the delivery failed.
+	Send(ctx context.Context, msg *Message) error
+	// Ping checks if the transport is able to deliver messages.
+	Ping(ctx context.Context) error
+}
+
+// permanentError marks delivery failures that will not go away by retrying.
+type permanentError struct {
+	error
+}
+
+// Permanent marks the error as a permanent delivery failure.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// IsPermanent checks if the error or its cause has been marked as a permanent delivery failure.
+func IsPermanent(err error) bool {
+	_, ok := errors.Cause(err).(permanentError)
+	return ok
+}
+
+// newMessageID generates a unique message ID in the domain of the given address.
+func newMessageID(address string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", errors.Wrap(err, "failed to generate message id")
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		domain = address[at+1:]
+	}
+	return fmt.Sprintf("%x@%s", random, domain), nil
+}
+
+// WriteTo encodes the message in the Internet Message Format (RFC 5322).
+func (msg *Message) WriteTo(w io.Writer) (int64, error) {
+	from := netmail.Address{Name: msg.FromName, Address: msg.FromAddress}
+	to := netmail.Address{Name: msg.ToName, Address: msg.ToAddress}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", msg.Date.Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "Message-ID: <%s>\r\n", msg.ID)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	body := quotedprintable.NewWriter(buf)
+	if _, err := body.Write([]byte(msg.HTMLContent)); err != nil {
+		return 0, errors.Wrap(err, "failed to encode body")
+	}
+	if err := body.Close(); err != nil {
+		return 0, errors.Wrap(err, "failed to encode body")
+	}
+	return buf.WriteTo(w)
+}
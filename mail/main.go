@@ -1,30 +1,87 @@
 package main
 
 import (
+	"context"
 	"net"
+	"time"
 
 	health "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/lnsp/microlog/common/keyring"
 	"github.com/lnsp/microlog/common/logger"
+	"github.com/lnsp/microlog/common/servertls"
 	"github.com/lnsp/microlog/mail/api"
 	"github.com/lnsp/microlog/mail/internal/mail"
 	"google.golang.org/grpc"
 )
 
 type specification struct {
-	APIKey      string `required:"true" desc:"SendGrid API Key"`
-	Secret      string `required:"true" desc:"Encryption secret for tokens"`
-	Addr        string `default:":8080" desc:"Host and port to listen on"`
-	ConfirmURL  string `default:"http://localhost:8080/auth/confirm?token=%s" desc:"Confirmation URL format"`
-	ResetURL    string `default:"http://localhost:8080/auth/reset?token=%s" desc:"Reset URL format"`
-	Templates   string `default:"templates" desc:"Template folder"`
-	SenderName  string `default:"The microlog team" desc:"The default sender name"`
-	SenderEmail string `default:"team@microlog.co" desc:"The default sender email"`
+	Transport     string        `default:"sendgrid" desc:"Mail transport, either sendgrid, smtp or file"`
+	APIKey        string        `desc:"SendGrid API Key"`
+	SMTPHost      string        `desc:"SMTP relay host"`
+	SMTPPort      int           `default:"587" desc:"SMTP relay port"`
+	SMTPUsername  string        `desc:"SMTP username, authentication is skipped if empty"`
+	SMTPPassword  string        `desc:"SMTP password"`
+	SMTPStartTLS  bool          `default:"true" desc:"Require STARTTLS before authenticating and sending"`
+	MailDir       string        `default:"outbox" desc:"Directory the file transport writes .eml files to"`
+	Datasource    string        `required:"true" desc:"gorm compatible datasource for the mail queue"`
+	Workers       int           `default:"2" desc:"Number of concurrent delivery workers"`
+	MaxAttempts   int           `default:"8" desc:"Delivery attempts before a message is dead-lettered"`
+	RetryDelay    time.Duration `default:"30s" desc:"Delay after the first failed delivery, doubled with each attempt"`
+	MaxRetryDelay time.Duration `default:"1h" desc:"Maximum delay between two delivery attempts"`
+	Secret        string        `desc:"Encryption secret for tokens, available as key ID default"`
+	Keys          string        `desc:"Additional token secrets as comma separated id:secret pairs"`
+	ActiveKey     string        `desc:"ID of the key used to sign new tokens"`
+	KeyFile       string        `desc:"JSON file containing the active key ID and keys, persists promotions"`
+	Addr          string        `default:":8080" desc:"Host and port to listen on"`
+	TLSCert       string        `desc:"Server certificate, enables TLS"`
+	TLSKey        string        `desc:"Key of the server certificate"`
+	TLSClientCA   string        `desc:"CA certificate bundle, enables mutual TLS by requiring client certificates"`
+	TLSAdmins     string        `desc:"Comma separated common names of client certificates allowed to promote keys, requires TLSClientCA"`
+	ConfirmURL    string        `default:"http://localhost:8080/auth/confirm?token=%s" desc:"Confirmation URL format"`
+	ResetURL      string        `default:"http://localhost:8080/auth/reset?token=%s" desc:"Reset URL format"`
+	ChangeURL     string        `default:"http://localhost:8080/auth/email/change?token=%s" desc:"Email change confirmation URL format"`
+	UndoURL       string        `default:"http://localhost:8080/auth/email/undo?token=%s" desc:"Email change undo URL format"`
+	ForgotURL     string        `default:"http://localhost:8080/auth/forgot" desc:"Password reset request URL"`
+	Templates     string        `default:"templates" desc:"Template folder"`
+	SenderName    string        `default:"The microlog team" desc:"The default sender name"`
+	SenderEmail   string        `default:"team@microlog.co" desc:"The default sender email"`
 }
 
 var log = logger.New()
 
+// openTransport sets up the configured mail transport.
+func openTransport(spec *specification) mail.Transport {
+	switch spec.Transport {
+	case "sendgrid":
+		if spec.APIKey == "" {
+			log.Fatal("sendgrid transport requires an API key")
+		}
+		return mail.NewSendGridTransport(spec.APIKey)
+	case "smtp":
+		if spec.SMTPHost == "" {
+			log.Fatal("smtp transport requires a relay host")
+		}
+		return mail.NewSMTPTransport(mail.SMTPConfig{
+			Host:     spec.SMTPHost,
+			Port:     spec.SMTPPort,
+			Username: spec.SMTPUsername,
+			Password: spec.SMTPPassword,
+			StartTLS: spec.SMTPStartTLS,
+		})
+	case "file":
+		transport, err := mail.NewFileTransport(spec.MailDir)
+		if err != nil {
+			log.WithError(err).Fatal("could not setup file transport")
+		}
+		return transport
+	default:
+		log.WithField("transport", spec.Transport).Fatal("unknown mail transport")
+	}
+	return nil
+}
+
 func main() {
 	var spec specification
 	if err := envconfig.Process("mail", &spec); err != nil {
@@ -35,16 +92,46 @@ func main() {
 	if err != nil {
 		log.WithError(err).Fatal("could not setup networking")
 	}
-	grpcServer := grpc.NewServer()
+	keys, err := keyring.New(keyring.Config{
+		Secret: spec.Secret,
+		Keys:   spec.Keys,
+		Active: spec.ActiveKey,
+		File:   spec.KeyFile,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("could not load signing keys")
+	}
+	serverOptions, err := servertls.Options(servertls.Config{
+		CertFile:     spec.TLSCert,
+		KeyFile:      spec.TLSKey,
+		ClientCAFile: spec.TLSClientCA,
+		AdminMethods: mail.AdminMethods,
+		Admins:       servertls.ParseNames(spec.TLSAdmins),
+	})
+	if err != nil {
+		log.WithError(err).Fatal("could not setup TLS")
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
 	mailServer := mail.NewServer(&mail.Config{
-		APIKey:         spec.APIKey,
+		Transport:  openTransport(&spec),
+		Datasource: spec.Datasource,
+		Queue: mail.QueueConfig{
+			Workers:       spec.Workers,
+			MaxAttempts:   spec.MaxAttempts,
+			RetryDelay:    spec.RetryDelay,
+			MaxRetryDelay: spec.MaxRetryDelay,
+		},
 		TemplateFolder: spec.Templates,
 		ConfirmURL:     spec.ConfirmURL,
 		ResetURL:       spec.ResetURL,
+		ChangeURL:      spec.ChangeURL,
+		UndoURL:        spec.UndoURL,
+		ForgotURL:      spec.ForgotURL,
 		SenderName:     spec.SenderName,
 		SenderEmail:    spec.SenderEmail,
-		Secret:         []byte(spec.Secret),
+		Keys:           keys,
 	})
+	mailServer.RunQueue(context.Background())
 	api.RegisterMailServer(grpcServer, mailServer)
 	health.RegisterHealthServer(grpcServer, mailServer.Health())
 	if err := grpcServer.Serve(listener); err != nil {
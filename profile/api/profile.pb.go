@@ -279,6 +279,7 @@ type ProfileResponse struct {
 	DisplayName          string   `protobuf:"bytes,2,opt,name=displayName,proto3" json:"displayName,omitempty"`
 	Biography            string   `protobuf:"bytes,3,opt,name=biography,proto3" json:"biography,omitempty"`
 	ImageURL             string   `protobuf:"bytes,4,opt,name=imageURL,proto3" json:"imageURL,omitempty"`
+	ThumbnailURL         string   `protobuf:"bytes,5,opt,name=thumbnailURL,proto3" json:"thumbnailURL,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -337,6 +338,13 @@ func (m *ProfileResponse) GetImageURL() string {
 	return ""
 }
 
+func (m *ProfileResponse) GetThumbnailURL() string {
+	if m != nil {
+		return m.ThumbnailURL
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*ProfileCreateRequest)(nil), "microlog.profile.v1.ProfileCreateRequest")
 	proto.RegisterType((*ProfileCreateResponse)(nil), "microlog.profile.v1.ProfileCreateResponse")
@@ -350,29 +358,29 @@ func init() {
 func init() { proto.RegisterFile("profile.proto", fileDescriptor_744bf7a47b381504) }
 
 var fileDescriptor_744bf7a47b381504 = []byte{
-	// 339 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x94, 0xcf, 0x4a, 0xf3, 0x40,
-	0x10, 0xc0, 0x9b, 0xfe, 0xfb, 0xbe, 0x4e, 0xad, 0xa5, 0x6b, 0xc5, 0x50, 0x3c, 0x84, 0x28, 0xa5,
-	0x7a, 0x08, 0xa8, 0x6f, 0x50, 0x0b, 0x55, 0x10, 0x91, 0x85, 0x5c, 0x3c, 0xb9, 0x35, 0xd3, 0xb8,
-	0x90, 0xb8, 0x6b, 0x12, 0x85, 0x82, 0xbe, 0xa6, 0xcf, 0x23, 0xcd, 0x26, 0x69, 0x5a, 0xda, 0x50,
-	0xd0, 0x1e, 0x67, 0x76, 0x66, 0x7e, 0xd9, 0xfc, 0x86, 0x85, 0x96, 0x0c, 0xc4, 0x94, 0x7b, 0x68,
-	0xc9, 0x40, 0x44, 0x82, 0x1c, 0xf8, 0xfc, 0x39, 0x10, 0x9e, 0x70, 0xad, 0x34, 0xff, 0x71, 0x61,
-	0xde, 0x40, 0xf7, 0x41, 0x45, 0xd7, 0x01, 0xb2, 0x08, 0x29, 0xbe, 0xbd, 0x63, 0x18, 0x91, 0x7d,
-	0x28, 0x73, 0x47, 0xd7, 0x0c, 0x6d, 0xd0, 0xa2, 0x65, 0xee, 0x10, 0x03, 0x9a, 0x0e, 0x0f, 0xa5,
-	0xc7, 0x66, 0xf7, 0xcc, 0x47, 0xbd, 0x6c, 0x68, 0x83, 0x06, 0xcd, 0xa7, 0xcc, 0x23, 0x38, 0x5c,
-	0x99, 0x14, 0x4a, 0xf1, 0x1a, 0xa2, 0xd9, 0xcf, 0x10, 0x23, 0xf4, 0x70, 0x23, 0x22, 0x37, 0x20,
-	0xad, 0x4b, 0x06, 0x9c, 0x40, 0x27, 0x39, 0x18, 0x63, 0xb4, 0xa9, 0xfb, 0x33, 0xa3, 0xd8, 0xd2,
-	0xf9, 0xcd, 0x45, 0xc8, 0x31, 0x34, 0x26, 0x5c, 0xb8, 0x01, 0x93, 0x2f, 0x33, 0xbd, 0x12, 0x9f,
-	0x2f, 0x12, 0xa4, 0x0b, 0x35, 0xee, 0x33, 0x17, 0xf5, 0xaa, 0xa1, 0x0d, 0xf6, 0xa8, 0x0a, 0xcc,
-	0x2f, 0x68, 0x27, 0xf4, 0xf4, 0xab, 0xff, 0x1c, 0xdc, 0x83, 0xff, 0x31, 0xcb, 0xa6, 0x77, 0x31,
-	0xbb, 0x41, 0xb3, 0xf8, 0xf2, 0xbb, 0x0a, 0xff, 0x12, 0x3e, 0x61, 0x50, 0x57, 0x02, 0xc8, 0x99,
-	0xb5, 0xc6, 0xb8, 0xb5, 0x4e, 0x77, 0xef, 0x7c, 0x9b, 0xd2, 0x44, 0x47, 0x69, 0x8e, 0x50, 0x8a,
-	0x8a, 0x11, 0x4b, 0xba, 0x8b, 0x11, 0x2b, 0xc6, 0x4b, 0xc4, 0x86, 0xca, 0x18, 0x23, 0xd2, 0x2f,
-	0x6a, 0x5a, 0x6c, 0x43, 0xef, 0xb4, 0xa8, 0x2e, 0x37, 0x76, 0x0a, 0x1d, 0xb5, 0x1e, 0xa3, 0xdc,
-	0x7f, 0x2f, 0xbc, 0xc4, 0xd2, 0x36, 0x6d, 0xcd, 0x71, 0xa0, 0xad, 0x1a, 0x87, 0x99, 0xbf, 0x1d,
-	0x50, 0x9e, 0xa0, 0xa9, 0x1a, 0x6f, 0xe7, 0x8b, 0xb0, 0x03, 0xc2, 0xb0, 0xf6, 0x58, 0x61, 0x92,
-	0x4f, 0xea, 0xf1, 0x0b, 0x72, 0xf5, 0x13, 0x00, 0x00, 0xff, 0xff, 0x24, 0xf6, 0xa0, 0xb9, 0x52,
-	0x04, 0x00, 0x00,
+	// 352 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x94, 0x5d, 0x4b, 0xc2, 0x50,
+	0x18, 0xc7, 0x9b, 0x6f, 0xe5, 0xa3, 0x26, 0x9e, 0x8c, 0xc6, 0xe8, 0x62, 0xac, 0x10, 0xf3, 0x62,
+	0x50, 0x7d, 0x03, 0x13, 0x2c, 0x88, 0x88, 0x81, 0x37, 0x5d, 0x75, 0x6c, 0x8f, 0x7a, 0x60, 0xf3,
+	0x9c, 0xb6, 0x63, 0x20, 0xf4, 0x65, 0xfa, 0x52, 0x7d, 0x9e, 0x70, 0x67, 0xce, 0x19, 0x3a, 0x84,
+	0xf2, 0xf2, 0x79, 0xfd, 0x9d, 0x87, 0xff, 0x9f, 0x03, 0x35, 0x11, 0xf0, 0x11, 0xf3, 0xd0, 0x16,
+	0x01, 0x97, 0x9c, 0x9c, 0xf8, 0xec, 0x2d, 0xe0, 0x1e, 0x1f, 0xdb, 0xcb, 0xfc, 0xc7, 0xb5, 0x75,
+	0x0f, 0xcd, 0x67, 0x15, 0xdd, 0x05, 0x48, 0x25, 0x3a, 0xf8, 0x3e, 0xc3, 0x50, 0x92, 0x63, 0xc8,
+	0x31, 0x57, 0xd7, 0x4c, 0xad, 0x5d, 0x73, 0x72, 0xcc, 0x25, 0x26, 0x54, 0x5c, 0x16, 0x0a, 0x8f,
+	0xce, 0x9f, 0xa8, 0x8f, 0x7a, 0xce, 0xd4, 0xda, 0x65, 0x27, 0x9d, 0xb2, 0xce, 0xe0, 0xf4, 0xd7,
+	0xa6, 0x50, 0xf0, 0x69, 0x88, 0x56, 0x2b, 0x41, 0xf4, 0xd0, 0xc3, 0xad, 0x88, 0xd4, 0x82, 0x65,
+	0x5f, 0xbc, 0xe0, 0x02, 0x1a, 0x71, 0xa1, 0x8f, 0x72, 0xdb, 0xf4, 0x67, 0x42, 0x19, 0x08, 0xf7,
+	0x2f, 0x87, 0x90, 0x73, 0x28, 0x0f, 0x19, 0x1f, 0x07, 0x54, 0x4c, 0xe6, 0x7a, 0x3e, 0xaa, 0xaf,
+	0x12, 0xa4, 0x09, 0x45, 0xe6, 0xd3, 0x31, 0xea, 0x05, 0x53, 0x6b, 0x57, 0x1d, 0x15, 0x58, 0x5f,
+	0x1a, 0xd4, 0x63, 0xfc, 0xf2, 0xd9, 0xff, 0x4e, 0x36, 0xe0, 0x28, 0x82, 0x0d, 0x9c, 0xc7, 0x08,
+	0x5e, 0x76, 0x92, 0x98, 0x58, 0x50, 0x95, 0x93, 0x99, 0x3f, 0x9c, 0x52, 0xe6, 0x2d, 0xea, 0xc5,
+	0xa8, 0xbe, 0x96, 0xbb, 0xf9, 0x2e, 0xc0, 0x61, 0xfc, 0x46, 0x42, 0xa1, 0xa4, 0x54, 0x22, 0x57,
+	0xf6, 0x06, 0x5b, 0xd8, 0x9b, 0x3c, 0x61, 0x74, 0x76, 0x69, 0x8d, 0x35, 0x3b, 0x58, 0x20, 0x94,
+	0x8e, 0xd9, 0x88, 0x35, 0x4f, 0x18, 0x9d, 0x5d, 0x5a, 0x13, 0xc4, 0x00, 0xf2, 0x7d, 0x94, 0xa4,
+	0x95, 0x35, 0xb4, 0xb2, 0x8c, 0x71, 0x99, 0xd5, 0x97, 0x5a, 0x3b, 0x82, 0x86, 0xf2, 0x50, 0x2f,
+	0xa5, 0x4d, 0xe6, 0x11, 0x6b, 0x96, 0xdb, 0x99, 0xe3, 0x42, 0x5d, 0x0d, 0x76, 0x13, 0x8d, 0xf7,
+	0x40, 0x79, 0x85, 0x8a, 0x1a, 0x7c, 0x58, 0x98, 0x65, 0x0f, 0x84, 0x6e, 0xf1, 0x25, 0x4f, 0x05,
+	0x1b, 0x96, 0xa2, 0x6f, 0xe6, 0xf6, 0x67, 0x00, 0xb1, 0x66, 0x85, 0x32, 0x77, 0x04, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
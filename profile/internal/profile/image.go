@@ -0,0 +1,75 @@
+package profile
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+
+	// Register supported image formats.
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/draw"
+)
+
+const (
+	imageSizeLarge     = 256
+	imageSizeSmall     = 64
+	imageMaxDimensions = 4096
+	imageContentType   = "image/png"
+)
+
+var (
+	errImageTooLarge      = errors.New("image exceeds size limit")
+	errImageUnsupported   = errors.New("unsupported image format")
+	errImageBadDimensions = errors.New("image dimensions out of bounds")
+	supportedContentTypes = map[string]bool{
+		"image/png":  true,
+		"image/jpeg": true,
+		"image/gif":  true,
+	}
+)
+
+// decodeImage sniffs the content type of the given data and decodes it.
+// It rejects data larger than maxSize bytes and images with huge dimensions before decoding them.
+func decodeImage(data []byte, maxSize int) (image.Image, error) {
+	if len(data) > maxSize {
+		return nil, errImageTooLarge
+	}
+	if !supportedContentTypes[http.DetectContentType(data)] {
+		return nil, errImageUnsupported
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errImageUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > imageMaxDimensions || cfg.Height > imageMaxDimensions {
+		return nil, errImageBadDimensions
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errImageUnsupported
+	}
+	return img, nil
+}
+
+// thumbnail crops the image to a centered square and scales it to the given size.
+// It returns the thumbnail encoded as PNG.
+func thumbnail(src image.Image, size int) ([]byte, error) {
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	x, y := bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2
+	crop := image.Rect(x, y, x+side, y+side)
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, dst); err != nil {
+		return nil, errors.Wrap(err, "could not encode thumbnail")
+	}
+	return buf.Bytes(), nil
+}
@@ -5,16 +5,34 @@ import (
 	"github.com/lnsp/microlog/common/logger"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
 )
 
 var log = logger.New()
 
+const (
+	displayNameMaxLength = 48
+	biographyMaxLength   = 240
+)
+
+var (
+	// ErrProfileNotFound is returned if no profile exists for the given user.
+	ErrProfileNotFound = errors.New("could not find profile")
+	// ErrProfileExists is returned if a profile for the given user already exists.
+	ErrProfileExists = errors.New("profile already exists")
+	// ErrValidation is returned if the given profile params are invalid.
+	ErrValidation = errors.New("can not validate params")
+)
+
 type Profile struct {
 	gorm.Model
-	UserID      uint
-	DisplayName string
-	ImageURL    string
-	Biography   string
+	UserID       uint `gorm:"unique_index"`
+	DisplayName  string
+	ImageURL     string
+	ThumbnailURL string
+	ImageKey     string
+	Biography    string
 }
 
 type DB struct {
@@ -32,16 +50,111 @@ func (d *DB) Close() error {
 	return d.db.Close()
 }
 
+// ValidateDisplayName checks if the display name is valid.
+func (d *DB) ValidateDisplayName(name string) bool {
+	return len(name) <= displayNameMaxLength
+}
+
+// ValidateBiography checks if the biography text is valid.
+func (d *DB) ValidateBiography(biography string) bool {
+	return len(biography) <= biographyMaxLength
+}
+
+// Create creates a new profile for the given user.
+// It returns an error if the display name is invalid or the user already has a profile.
+func (d *DB) Create(user uint, displayName string) (*Profile, error) {
+	if !d.ValidateDisplayName(displayName) {
+		return nil, ErrValidation
+	}
+	var count int
+	d.db.Model(&Profile{}).Where("user_id = ?", user).Count(&count)
+	if count > 0 {
+		return nil, ErrProfileExists
+	}
+	profile := Profile{
+		UserID:      user,
+		DisplayName: displayName,
+	}
+	if err := d.db.Create(&profile).Error; err != nil {
+		return nil, errors.Wrap(err, "could not create profile")
+	}
+	return &profile, nil
+}
+
+// Get retrieves the profile of the given user.
+// It returns the profile and an error if the profile does not exist.
+func (d *DB) Get(user uint) (*Profile, error) {
+	var profile Profile
+	d.db.Where("user_id = ?", user).First(&profile)
+	if profile.UserID != user || profile.ID == 0 {
+		return nil, ErrProfileNotFound
+	}
+	return &profile, nil
+}
+
+// Delete deletes the profile of the given user.
+// It returns an error if the profile does not exist.
+func (d *DB) Delete(user uint) error {
+	profile, err := d.Get(user)
+	if err != nil {
+		return err
+	}
+	if err := d.db.Delete(profile).Error; err != nil {
+		return errors.Wrap(err, "could not delete profile")
+	}
+	return nil
+}
+
+// UpdateDisplayName changes the display name of the given user.
+// It returns the updated profile and an error if the name is invalid or the profile does not exist.
+func (d *DB) UpdateDisplayName(user uint, name string) (*Profile, error) {
+	if !d.ValidateDisplayName(name) {
+		return nil, ErrValidation
+	}
+	return d.update(user, "display_name", name)
+}
+
+// UpdateBiography changes the biography of the given user.
+// It returns the updated profile and an error if the biography is invalid or the profile does not exist.
+func (d *DB) UpdateBiography(user uint, biography string) (*Profile, error) {
+	if !d.ValidateBiography(biography) {
+		return nil, ErrValidation
+	}
+	return d.update(user, "biography", biography)
+}
+
+// UpdateImage changes the profile image of the given user.
+// The key identifies the stored image objects, imageURL and thumbnailURL point to the rendered sizes.
+// It returns the updated profile and an error if the profile does not exist.
+func (d *DB) UpdateImage(user uint, key, imageURL, thumbnailURL string) (*Profile, error) {
+	return d.update(user, map[string]interface{}{
+		"image_key":     key,
+		"image_url":     imageURL,
+		"thumbnail_url": thumbnailURL,
+	})
+}
+
+func (d *DB) update(user uint, attrs ...interface{}) (*Profile, error) {
+	profile, err := d.Get(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.db.Model(profile).Update(attrs...).Error; err != nil {
+		return nil, errors.Wrap(err, "could not update profile")
+	}
+	return profile, nil
+}
+
 func Open(path string) (*DB, error) {
 	log.WithFields(logrus.Fields{
 		"path": path,
 		"type": "postgres",
 	}).Info("accessing database")
 	db, err := gorm.Open("postgres", path)
-	db.SetLogger(log)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not connect to data source")
 	}
+	db.SetLogger(log)
 	db.AutoMigrate(&Profile{})
 	return &DB{db}, nil
 }
@@ -1,12 +1,16 @@
+// Package profile provides a GRPC service for managing user profiles.
 package profile
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
 
 	"github.com/lnsp/microlog/common/logger"
 	"github.com/lnsp/microlog/profile/api"
 	"github.com/lnsp/microlog/profile/internal/profile/models"
-	"github.com/minio/minio-go"
+	"github.com/lnsp/microlog/profile/internal/profile/storage"
+	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	health "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
@@ -14,57 +18,178 @@ import (
 
 var log = logger.New()
 
+// Config stores the service configuration.
 type Config struct {
 	Datasource   string
-	S3AccessKey  string
-	S3SecretKey  string
-	S3BucketPath string
-	S3Bucket     string
-	S3Endpoint   string
+	Storage      storage.Store
+	MaxImageSize int
 }
 
-func NewServer(cfg *Config) api.ProfileServer {
-	client, err := minio.New(cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, true)
-	if err != nil {
-		log.WithError(err).Fatal("could not create minio client")
-	}
+func NewServer(cfg *Config) *ProfileServer {
 	data, err := models.Open(cfg.Datasource)
 	if err != nil {
 		log.WithError(err).Fatal("could not connect to data source")
 	}
 	return &ProfileServer{
-		s3: client,
-		db: data,
+		store:        cfg.Storage,
+		db:           data,
+		maxImageSize: cfg.MaxImageSize,
 	}
 }
 
 type ProfileServer struct {
-	s3 *minio.Client
-	db *models.DB
+	store        storage.Store
+	db           *models.DB
+	maxImageSize int
+}
+
+// statusFromError converts data errors into their matching GRPC status.
+func statusFromError(err error) error {
+	switch err {
+	case models.ErrProfileNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case models.ErrProfileExists:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case models.ErrValidation, errImageTooLarge, errImageUnsupported, errImageBadDimensions:
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func profileResponse(profile *models.Profile) *api.ProfileResponse {
+	return &api.ProfileResponse{
+		Id:           uint32(profile.UserID),
+		DisplayName:  profile.DisplayName,
+		Biography:    profile.Biography,
+		ImageURL:     profile.ImageURL,
+		ThumbnailURL: profile.ThumbnailURL,
+	}
 }
 
+// Create creates a new empty profile with the given display name.
 func (s *ProfileServer) Create(ctx context.Context, req *api.ProfileCreateRequest) (*api.ProfileCreateResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "create not implemented")
+	log := log.WithField("id", req.Id)
+	if _, err := s.db.Create(uint(req.Id), req.DisplayName); err != nil {
+		log.WithError(err).Warn("failed to create profile")
+		return nil, statusFromError(err)
+	}
+	log.Debug("created profile")
+	return &api.ProfileCreateResponse{}, nil
 }
 
+// Delete deletes the profile and its image.
 func (s *ProfileServer) Delete(ctx context.Context, req *api.ProfileDeleteRequest) (*api.ProfileDeleteResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "delete not implemented")
+	log := log.WithField("id", req.Id)
+	profile, err := s.db.Get(uint(req.Id))
+	if err != nil {
+		log.WithError(err).Warn("failed to find profile")
+		return nil, statusFromError(err)
+	}
+	if err := s.db.Delete(uint(req.Id)); err != nil {
+		log.WithError(err).Warn("failed to delete profile")
+		return nil, statusFromError(err)
+	}
+	s.deleteImage(ctx, profile.ImageKey)
+	log.Debug("deleted profile")
+	return &api.ProfileDeleteResponse{}, nil
 }
 
+// Get retrieves the profile.
 func (s *ProfileServer) Get(ctx context.Context, req *api.ProfileGetRequest) (*api.ProfileResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "get not implemented")
+	profile, err := s.db.Get(uint(req.Id))
+	if err != nil {
+		log.WithField("id", req.Id).WithError(err).Debug("failed to get profile")
+		return nil, statusFromError(err)
+	}
+	return profileResponse(profile), nil
 }
 
+// UpdateBiography changes the biography of the profile.
 func (s *ProfileServer) UpdateBiography(ctx context.Context, req *api.ProfileUpdateRequest) (*api.ProfileResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "update_biography not implemented")
+	log := log.WithField("id", req.Id)
+	profile, err := s.db.UpdateBiography(uint(req.Id), req.Biography)
+	if err != nil {
+		log.WithError(err).Warn("failed to update biography")
+		return nil, statusFromError(err)
+	}
+	log.Debug("updated biography")
+	return profileResponse(profile), nil
 }
 
+// UpdateDisplayName changes the display name of the profile.
 func (s *ProfileServer) UpdateDisplayName(ctx context.Context, req *api.ProfileUpdateRequest) (*api.ProfileResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "update_displayname not implemented")
+	log := log.WithField("id", req.Id)
+	profile, err := s.db.UpdateDisplayName(uint(req.Id), req.DisplayName)
+	if err != nil {
+		log.WithError(err).Warn("failed to update display name")
+		return nil, statusFromError(err)
+	}
+	log.Debug("updated display name")
+	return profileResponse(profile), nil
+}
+
+// imageObjects returns the object names of the image sizes stored under the given key.
+func imageObjects(key string) (string, string) {
+	return fmt.Sprintf("%s-%d.png", key, imageSizeLarge), fmt.Sprintf("%s-%d.png", key, imageSizeSmall)
+}
+
+// deleteImage removes the stored image sizes identified by the given key.
+// Failures are only logged, since orphaned objects do not affect the service.
+func (s *ProfileServer) deleteImage(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	large, small := imageObjects(key)
+	for _, name := range []string{large, small} {
+		if err := s.store.Delete(ctx, name); err != nil {
+			log.WithField("object", name).WithError(err).Warn("failed to delete image")
+		}
+	}
 }
 
+// UpdateImage scales the given image to the fixed thumbnail sizes, stores them and links them with the profile.
 func (s *ProfileServer) UpdateImage(ctx context.Context, req *api.ProfileUpdateRequest) (*api.ProfileResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "update_image not implemented")
+	log := log.WithFields(logrus.Fields{
+		"id":   req.Id,
+		"size": len(req.Image),
+	})
+	previous, err := s.db.Get(uint(req.Id))
+	if err != nil {
+		log.WithError(err).Warn("failed to find profile")
+		return nil, statusFromError(err)
+	}
+	img, err := decodeImage(req.Image, s.maxImageSize)
+	if err != nil {
+		log.WithError(err).Warn("failed to decode image")
+		return nil, statusFromError(err)
+	}
+	key := fmt.Sprintf("%d/%x", req.Id, sha256.Sum256(req.Image))
+	large, small := imageObjects(key)
+	urls := make(map[string]string)
+	for name, size := range map[string]int{large: imageSizeLarge, small: imageSizeSmall} {
+		data, err := thumbnail(img, size)
+		if err != nil {
+			log.WithError(err).Error("failed to scale image")
+			return nil, statusFromError(err)
+		}
+		url, err := s.store.Put(ctx, name, data, imageContentType)
+		if err != nil {
+			log.WithError(err).Error("failed to store image")
+			return nil, statusFromError(err)
+		}
+		urls[name] = url
+	}
+	profile, err := s.db.UpdateImage(uint(req.Id), key, urls[large], urls[small])
+	if err != nil {
+		log.WithError(err).Warn("failed to update image")
+		return nil, statusFromError(err)
+	}
+	if previous.ImageKey != key {
+		s.deleteImage(ctx, previous.ImageKey)
+	}
+	log.Debug("updated image")
+	return profileResponse(profile), nil
 }
 
 // Health returns an implementation of the GRPC Health Checking protocol.
@@ -78,9 +203,9 @@ type healthServer struct {
 
 func (h *healthServer) Check(ctx context.Context, req *health.HealthCheckRequest) (*health.HealthCheckResponse, error) {
 	// Since we have only one service running, we don not need to check the health target string.
-	// First check if S3-compatible bucket is accessible.
-	if _, err := h.s.s3.ListBuckets(); err != nil {
-		log.WithError(err).Error("S3-compatible storage unreachable")
+	// First check if the image storage is accessible.
+	if err := h.s.store.Ping(); err != nil {
+		log.WithError(err).Error("image storage unreachable")
 		return &health.HealthCheckResponse{
 			Status: health.HealthCheckResponse_NOT_SERVING,
 		}, nil
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LocalStore stores objects in a directory on the local filesystem.
+// It is meant for development and testing setups without S3-compatible storage.
+type LocalStore struct {
+	dir       string
+	publicURL string
+}
+
+// NewLocalStore stores objects in the given directory, creating it if necessary.
+// Objects are expected to be reachable below the given public URL, see Handler.
+func NewLocalStore(dir, publicURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "could not create storage directory")
+	}
+	return &LocalStore{
+		dir:       dir,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+func (s *LocalStore) path(name string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return "", errors.Errorf("invalid object name %s", name)
+	}
+	return path, nil
+}
+
+// Put writes the object into the storage directory.
+func (s *LocalStore) Put(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", errors.Wrap(err, "could not create object directory")
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return "", errors.Wrap(err, "could not write object")
+	}
+	return s.publicURL + "/" + name, nil
+}
+
+// Delete removes the object from the storage directory.
+func (s *LocalStore) Delete(ctx context.Context, name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "could not remove object")
+	}
+	return nil
+}
+
+// Ping checks if the storage directory is accessible.
+func (s *LocalStore) Ping() error {
+	if _, err := os.Stat(s.dir); err != nil {
+		return errors.Wrap(err, "could not access storage directory")
+	}
+	return nil
+}
+
+// Handler serves the stored objects via HTTP.
+func (s *LocalStore) Handler() http.Handler {
+	return http.FileServer(http.Dir(s.dir))
+}
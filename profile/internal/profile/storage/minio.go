@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/minio/minio-go"
+	"github.com/pkg/errors"
+)
+
+// MinioStore stores objects in a S3-compatible bucket.
+type MinioStore struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+// NewMinioStore connects to the S3-compatible endpoint and stores objects in the given bucket.
+// Objects are expected to be reachable below the given public URL.
+func NewMinioStore(endpoint, accessKey, secretKey, bucket, publicURL string) (*MinioStore, error) {
+	client, err := minio.New(endpoint, accessKey, secretKey, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create minio client")
+	}
+	return &MinioStore{
+		client:    client,
+		bucket:    bucket,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+// Put uploads the object into the bucket.
+func (s *MinioStore) Put(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	_, err := s.client.PutObjectWithContext(ctx, s.bucket, name, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "could not upload object")
+	}
+	return s.publicURL + "/" + name, nil
+}
+
+// Delete removes the object from the bucket.
+func (s *MinioStore) Delete(ctx context.Context, name string) error {
+	if err := s.client.RemoveObject(s.bucket, name); err != nil {
+		return errors.Wrap(err, "could not remove object")
+	}
+	return nil
+}
+
+// Ping checks if the bucket is accessible.
+func (s *MinioStore) Ping() error {
+	exists, err := s.client.BucketExists(s.bucket)
+	if err != nil {
+		return errors.Wrap(err, "could not access bucket")
+	}
+	if !exists {
+		return errors.Errorf("bucket %s does not exist", s.bucket)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+// Package storage provides object storage backends for profile images.
+package storage
+
+import (
+	"context"
+)
+
+// Store persists binary objects and makes them publicly reachable.
+type Store interface {
+	// Put stores the object under the given name and returns its public URL.
+	Put(ctx context.Context, name string, data []byte, contentType string) (string, error)
+	// Delete removes the object with the given name.
+	Delete(ctx context.Context, name string) error
+	// Ping checks if the storage backend is reachable.
+	Ping() error
+}
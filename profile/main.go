@@ -1,26 +1,95 @@
 package main
 
 import (
+	"net"
+	"net/http"
+
+	health "google.golang.org/grpc/health/grpc_health_v1"
+
 	"github.com/kelseyhightower/envconfig"
 	"github.com/lnsp/microlog/common/logger"
+	"github.com/lnsp/microlog/common/servertls"
+	"github.com/lnsp/microlog/profile/api"
+	"github.com/lnsp/microlog/profile/internal/profile"
+	"github.com/lnsp/microlog/profile/internal/profile/storage"
+	"google.golang.org/grpc"
 )
 
 var log = logger.New()
 
 type specification struct {
 	Addr         string `default:":8080" desc:"Address the service is listening on"`
-	S3AccessKey  string `required:"true" desc:"Access key for S3-compatible object storage"`
-	S3SecretKey  string `required:"true" desc:"Secret key for S3-compatible object storage"`
-	S3BucketPath string `required:"true" desc:"S3-compatible bucket path string"`
-	S3Bucket     string `required:"true" desc:"S3-compatible bucket name"`
-	S3Endpoint   string `required:"true" desc:"S3-compatible endpoint URL"`
+	TLSCert      string `desc:"Server certificate, enables TLS"`
+	TLSKey       string `desc:"Key of the server certificate"`
+	TLSClientCA  string `desc:"CA certificate bundle, enables mutual TLS by requiring client certificates"`
+	Storage      string `default:"minio" desc:"Image storage backend, either minio or local"`
+	StorageDir   string `default:"images" desc:"Directory used by the local image storage"`
+	StorageAddr  string `default:":8081" desc:"Address the local image storage is served on"`
+	S3AccessKey  string `desc:"Access key for S3-compatible object storage"`
+	S3SecretKey  string `desc:"Secret key for S3-compatible object storage"`
+	S3BucketPath string `desc:"Public URL of the S3-compatible bucket, also used as base URL for local storage"`
+	S3Bucket     string `desc:"S3-compatible bucket name"`
+	S3Endpoint   string `desc:"S3-compatible endpoint URL"`
+	MaxImageSize int    `default:"2097152" desc:"Maximum size of uploaded images in bytes"`
 	Datasource   string `required:"true" desc:"gorm compatible datasource"`
 }
 
+// openStorage sets up the configured image storage backend.
+func openStorage(spec *specification) storage.Store {
+	switch spec.Storage {
+	case "minio":
+		if spec.S3AccessKey == "" || spec.S3SecretKey == "" || spec.S3Bucket == "" || spec.S3Endpoint == "" || spec.S3BucketPath == "" {
+			log.Fatal("minio storage requires S3 access key, secret key, bucket, bucket path and endpoint")
+		}
+		store, err := storage.NewMinioStore(spec.S3Endpoint, spec.S3AccessKey, spec.S3SecretKey, spec.S3Bucket, spec.S3BucketPath)
+		if err != nil {
+			log.WithError(err).Fatal("could not setup minio storage")
+		}
+		return store
+	case "local":
+		store, err := storage.NewLocalStore(spec.StorageDir, spec.S3BucketPath)
+		if err != nil {
+			log.WithError(err).Fatal("could not setup local storage")
+		}
+		go func() {
+			if err := http.ListenAndServe(spec.StorageAddr, store.Handler()); err != nil {
+				log.WithError(err).Fatal("failed to serve images")
+			}
+		}()
+		return store
+	default:
+		log.WithField("storage", spec.Storage).Fatal("unknown storage backend")
+	}
+	return nil
+}
+
 func main() {
 	var spec specification
 	if err := envconfig.Process("profile", &spec); err != nil {
 		envconfig.Usage("profile", &spec)
 		return
 	}
+	listener, err := net.Listen("tcp", spec.Addr)
+	if err != nil {
+		log.WithError(err).Fatal("could not setup networking")
+	}
+	serverOptions, err := servertls.Options(servertls.Config{
+		CertFile:     spec.TLSCert,
+		KeyFile:      spec.TLSKey,
+		ClientCAFile: spec.TLSClientCA,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("could not setup TLS")
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
+	profileServer := profile.NewServer(&profile.Config{
+		Datasource:   spec.Datasource,
+		Storage:      openStorage(&spec),
+		MaxImageSize: spec.MaxImageSize,
+	})
+	api.RegisterProfileServer(grpcServer, profileServer)
+	health.RegisterHealthServer(grpcServer, profileServer.Health())
+	if err := grpcServer.Serve(listener); err != nil {
+		log.WithError(err).Fatal("failed to serve")
+	}
 }
@@ -27,6 +27,9 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 type CreateRequest struct {
 	Id                   uint32   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	Role                 string   `protobuf:"bytes,2,opt,name=role,proto3" json:"role,omitempty"`
+	UserAgent            string   `protobuf:"bytes,3,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	Ip                   string   `protobuf:"bytes,4,opt,name=ip,proto3" json:"ip,omitempty"`
+	Remember             bool     `protobuf:"varint,5,opt,name=remember,proto3" json:"remember,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -71,8 +74,30 @@ func (m *CreateRequest) GetRole() string {
 	return ""
 }
 
+func (m *CreateRequest) GetUserAgent() string {
+	if m != nil {
+		return m.UserAgent
+	}
+	return ""
+}
+
+func (m *CreateRequest) GetIp() string {
+	if m != nil {
+		return m.Ip
+	}
+	return ""
+}
+
+func (m *CreateRequest) GetRemember() bool {
+	if m != nil {
+		return m.Remember
+	}
+	return false
+}
+
 type CreateResponse struct {
 	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Expires              int64    `protobuf:"varint,2,opt,name=expires,proto3" json:"expires,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -110,6 +135,13 @@ func (m *CreateResponse) GetToken() string {
 	return ""
 }
 
+func (m *CreateResponse) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
 type VerifyRequest struct {
 	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -153,6 +185,10 @@ type VerifyResponse struct {
 	Ok                   bool     `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
 	Id                   uint32   `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
 	Role                 string   `protobuf:"bytes,3,opt,name=role,proto3" json:"role,omitempty"`
+	Session              string   `protobuf:"bytes,4,opt,name=session,proto3" json:"session,omitempty"`
+	Issued               int64    `protobuf:"varint,5,opt,name=issued,proto3" json:"issued,omitempty"`
+	Expires              int64    `protobuf:"varint,6,opt,name=expires,proto3" json:"expires,omitempty"`
+	Remember             bool     `protobuf:"varint,7,opt,name=remember,proto3" json:"remember,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -204,6 +240,120 @@ func (m *VerifyResponse) GetRole() string {
 	return ""
 }
 
+func (m *VerifyResponse) GetSession() string {
+	if m != nil {
+		return m.Session
+	}
+	return ""
+}
+
+func (m *VerifyResponse) GetIssued() int64 {
+	if m != nil {
+		return m.Issued
+	}
+	return 0
+}
+
+func (m *VerifyResponse) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
+func (m *VerifyResponse) GetRemember() bool {
+	if m != nil {
+		return m.Remember
+	}
+	return false
+}
+
+type RefreshRequest struct {
+	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RefreshRequest) Reset()         { *m = RefreshRequest{} }
+func (m *RefreshRequest) String() string { return proto.CompactTextString(m) }
+func (*RefreshRequest) ProtoMessage()    {}
+func (*RefreshRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{4}
+}
+
+func (m *RefreshRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RefreshRequest.Unmarshal(m, b)
+}
+func (m *RefreshRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RefreshRequest.Marshal(b, m, deterministic)
+}
+func (m *RefreshRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RefreshRequest.Merge(m, src)
+}
+func (m *RefreshRequest) XXX_Size() int {
+	return xxx_messageInfo_RefreshRequest.Size(m)
+}
+func (m *RefreshRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RefreshRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RefreshRequest proto.InternalMessageInfo
+
+func (m *RefreshRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type RefreshResponse struct {
+	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Expires              int64    `protobuf:"varint,2,opt,name=expires,proto3" json:"expires,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RefreshResponse) Reset()         { *m = RefreshResponse{} }
+func (m *RefreshResponse) String() string { return proto.CompactTextString(m) }
+func (*RefreshResponse) ProtoMessage()    {}
+func (*RefreshResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{5}
+}
+
+func (m *RefreshResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RefreshResponse.Unmarshal(m, b)
+}
+func (m *RefreshResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RefreshResponse.Marshal(b, m, deterministic)
+}
+func (m *RefreshResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RefreshResponse.Merge(m, src)
+}
+func (m *RefreshResponse) XXX_Size() int {
+	return xxx_messageInfo_RefreshResponse.Size(m)
+}
+func (m *RefreshResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RefreshResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RefreshResponse proto.InternalMessageInfo
+
+func (m *RefreshResponse) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *RefreshResponse) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
 type DeleteRequest struct {
 	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -215,7 +365,7 @@ func (m *DeleteRequest) Reset()         { *m = DeleteRequest{} }
 func (m *DeleteRequest) String() string { return proto.CompactTextString(m) }
 func (*DeleteRequest) ProtoMessage()    {}
 func (*DeleteRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_3a6be1b361fa6f14, []int{4}
+	return fileDescriptor_3a6be1b361fa6f14, []int{6}
 }
 
 func (m *DeleteRequest) XXX_Unmarshal(b []byte) error {
@@ -253,7 +403,7 @@ func (m *DeleteResponse) Reset()         { *m = DeleteResponse{} }
 func (m *DeleteResponse) String() string { return proto.CompactTextString(m) }
 func (*DeleteResponse) ProtoMessage()    {}
 func (*DeleteResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_3a6be1b361fa6f14, []int{5}
+	return fileDescriptor_3a6be1b361fa6f14, []int{7}
 }
 
 func (m *DeleteResponse) XXX_Unmarshal(b []byte) error {
@@ -274,184 +424,1119 @@ func (m *DeleteResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_DeleteResponse proto.InternalMessageInfo
 
-func init() {
-	proto.RegisterType((*CreateRequest)(nil), "api.CreateRequest")
-	proto.RegisterType((*CreateResponse)(nil), "api.CreateResponse")
-	proto.RegisterType((*VerifyRequest)(nil), "api.VerifyRequest")
-	proto.RegisterType((*VerifyResponse)(nil), "api.VerifyResponse")
-	proto.RegisterType((*DeleteRequest)(nil), "api.DeleteRequest")
-	proto.RegisterType((*DeleteResponse)(nil), "api.DeleteResponse")
+type SessionInfo struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Created              int64    `protobuf:"varint,2,opt,name=created,proto3" json:"created,omitempty"`
+	LastSeen             int64    `protobuf:"varint,3,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	UserAgent            string   `protobuf:"bytes,4,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	Ip                   string   `protobuf:"bytes,5,opt,name=ip,proto3" json:"ip,omitempty"`
+	Remember             bool     `protobuf:"varint,6,opt,name=remember,proto3" json:"remember,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func init() { proto.RegisterFile("session.proto", fileDescriptor_3a6be1b361fa6f14) }
-
-var fileDescriptor_3a6be1b361fa6f14 = []byte{
-	// 239 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x91, 0xc1, 0x4a, 0xc3, 0x40,
-	0x10, 0x86, 0x9b, 0xad, 0x56, 0x3b, 0xb0, 0x8b, 0x8c, 0x1e, 0x8a, 0xa7, 0xb2, 0x50, 0xe9, 0x29,
-	0x07, 0xf3, 0x08, 0xf6, 0x09, 0x56, 0xf0, 0x1e, 0xe9, 0x08, 0x4b, 0x4a, 0x26, 0x66, 0xd7, 0x83,
-	0x6f, 0xe4, 0x63, 0x8a, 0x3b, 0xbb, 0xe8, 0x62, 0x6f, 0x49, 0xf8, 0xbf, 0x99, 0xef, 0x9f, 0x80,
-	0x0e, 0x14, 0x82, 0xe7, 0xb1, 0x9d, 0x66, 0x8e, 0x8c, 0xcb, 0x7e, 0xf2, 0xb6, 0x03, 0xfd, 0x34,
-	0x53, 0x1f, 0xc9, 0xd1, 0xfb, 0x07, 0x85, 0x88, 0x06, 0x94, 0x3f, 0x6e, 0x9a, 0x6d, 0xb3, 0xd7,
-	0x4e, 0xf9, 0x23, 0x22, 0x5c, 0xcc, 0x7c, 0xa2, 0x8d, 0xda, 0x36, 0xfb, 0xb5, 0x4b, 0xcf, 0xf6,
-	0x01, 0x4c, 0x81, 0xc2, 0xc4, 0x63, 0x20, 0xbc, 0x83, 0xcb, 0xc8, 0x03, 0x8d, 0x09, 0x5c, 0x3b,
-	0x79, 0xb1, 0x3b, 0xd0, 0x2f, 0x34, 0xfb, 0xb7, 0xcf, 0x32, 0xfc, 0x7c, 0xec, 0x00, 0xa6, 0xc4,
-	0xf2, 0x38, 0x03, 0x8a, 0x87, 0x14, 0xba, 0x76, 0x8a, 0x87, 0x2c, 0xa5, 0xfe, 0x49, 0x2d, 0xff,
-	0x48, 0xed, 0x40, 0x1f, 0xe8, 0x44, 0xbf, 0x4d, 0xce, 0x2f, 0xbb, 0x01, 0x53, 0x62, 0xb2, 0xec,
-	0xf1, 0xab, 0x81, 0xab, 0x67, 0xb9, 0x0c, 0x76, 0xb0, 0x92, 0x66, 0x88, 0x6d, 0x3f, 0xf9, 0xb6,
-	0xba, 0xcd, 0xfd, 0x6d, 0xf5, 0x4d, 0x70, 0xbb, 0xf8, 0x81, 0xc4, 0x3f, 0x43, 0x55, 0xe7, 0x0c,
-	0xd5, 0x05, 0x05, 0x12, 0x8f, 0x0c, 0x55, 0xee, 0x19, 0xaa, 0x45, 0xed, 0xe2, 0x75, 0x95, 0xfe,
-	0x5c, 0xf7, 0x1d, 0x00, 0x00, 0xff, 0xff, 0x00, 0x33, 0xe2, 0x72, 0xca, 0x01, 0x00, 0x00,
+func (m *SessionInfo) Reset()         { *m = SessionInfo{} }
+func (m *SessionInfo) String() string { return proto.CompactTextString(m) }
+func (*SessionInfo) ProtoMessage()    {}
+func (*SessionInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{8}
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *SessionInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionInfo.Unmarshal(m, b)
+}
+func (m *SessionInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionInfo.Marshal(b, m, deterministic)
+}
+func (m *SessionInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionInfo.Merge(m, src)
+}
+func (m *SessionInfo) XXX_Size() int {
+	return xxx_messageInfo_SessionInfo.Size(m)
+}
+func (m *SessionInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionInfo.DiscardUnknown(m)
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+var xxx_messageInfo_SessionInfo proto.InternalMessageInfo
 
-// SessionClient is the client API for Session service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type SessionClient interface {
-	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
-	Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error)
-	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+func (m *SessionInfo) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
 }
 
-type sessionClient struct {
-	cc *grpc.ClientConn
+func (m *SessionInfo) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
 }
 
-func NewSessionClient(cc *grpc.ClientConn) SessionClient {
-	return &sessionClient{cc}
+func (m *SessionInfo) GetLastSeen() int64 {
+	if m != nil {
+		return m.LastSeen
+	}
+	return 0
 }
 
-func (c *sessionClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error) {
-	out := new(CreateResponse)
-	err := c.cc.Invoke(ctx, "/api.Session/Create", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *SessionInfo) GetUserAgent() string {
+	if m != nil {
+		return m.UserAgent
 	}
-	return out, nil
+	return ""
 }
 
-func (c *sessionClient) Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error) {
-	out := new(VerifyResponse)
-	err := c.cc.Invoke(ctx, "/api.Session/Verify", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *SessionInfo) GetIp() string {
+	if m != nil {
+		return m.Ip
 	}
-	return out, nil
+	return ""
 }
 
-func (c *sessionClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
-	out := new(DeleteResponse)
-	err := c.cc.Invoke(ctx, "/api.Session/Delete", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *SessionInfo) GetRemember() bool {
+	if m != nil {
+		return m.Remember
 	}
-	return out, nil
+	return false
 }
 
-// SessionServer is the server API for Session service.
-type SessionServer interface {
-	Create(context.Context, *CreateRequest) (*CreateResponse, error)
-	Verify(context.Context, *VerifyRequest) (*VerifyResponse, error)
-	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+type ListRequest struct {
+	Id                   uint32   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-// UnimplementedSessionServer can be embedded to have forward compatible implementations.
-type UnimplementedSessionServer struct {
+func (m *ListRequest) Reset()         { *m = ListRequest{} }
+func (m *ListRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRequest) ProtoMessage()    {}
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{9}
 }
 
-func (*UnimplementedSessionServer) Create(ctx context.Context, req *CreateRequest) (*CreateResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+func (m *ListRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListRequest.Unmarshal(m, b)
 }
-func (*UnimplementedSessionServer) Verify(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Verify not implemented")
+func (m *ListRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListRequest.Marshal(b, m, deterministic)
 }
-func (*UnimplementedSessionServer) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+func (m *ListRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListRequest.Merge(m, src)
 }
-
-func RegisterSessionServer(s *grpc.Server, srv SessionServer) {
-	s.RegisterService(&_Session_serviceDesc, srv)
+func (m *ListRequest) XXX_Size() int {
+	return xxx_messageInfo_ListRequest.Size(m)
+}
+func (m *ListRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListRequest.DiscardUnknown(m)
 }
 
-func _Session_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CreateRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(SessionServer).Create(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/api.Session/Create",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SessionServer).Create(ctx, req.(*CreateRequest))
+var xxx_messageInfo_ListRequest proto.InternalMessageInfo
+
+func (m *ListRequest) GetId() uint32 {
+	if m != nil {
+		return m.Id
 	}
-	return interceptor(ctx, in, info, handler)
+	return 0
 }
 
-func _Session_Verify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(VerifyRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(SessionServer).Verify(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/api.Session/Verify",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SessionServer).Verify(ctx, req.(*VerifyRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+type ListResponse struct {
+	Sessions             []*SessionInfo `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
 }
 
-func _Session_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(SessionServer).Delete(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/api.Session/Delete",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SessionServer).Delete(ctx, req.(*DeleteRequest))
+func (m *ListResponse) Reset()         { *m = ListResponse{} }
+func (m *ListResponse) String() string { return proto.CompactTextString(m) }
+func (*ListResponse) ProtoMessage()    {}
+func (*ListResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{10}
+}
+
+func (m *ListResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListResponse.Unmarshal(m, b)
+}
+func (m *ListResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListResponse.Marshal(b, m, deterministic)
+}
+func (m *ListResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListResponse.Merge(m, src)
+}
+func (m *ListResponse) XXX_Size() int {
+	return xxx_messageInfo_ListResponse.Size(m)
+}
+func (m *ListResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListResponse proto.InternalMessageInfo
+
+func (m *ListResponse) GetSessions() []*SessionInfo {
+	if m != nil {
+		return m.Sessions
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-var _Session_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "api.Session",
-	HandlerType: (*SessionServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Create",
-			Handler:    _Session_Create_Handler,
-		},
-		{
-			MethodName: "Verify",
-			Handler:    _Session_Verify_Handler,
-		},
-		{
-			MethodName: "Delete",
-			Handler:    _Session_Delete_Handler,
+type RevokeRequest struct {
+	Id                   uint32   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Session              string   `protobuf:"bytes,2,opt,name=session,proto3" json:"session,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeRequest) Reset()         { *m = RevokeRequest{} }
+func (m *RevokeRequest) String() string { return proto.CompactTextString(m) }
+func (*RevokeRequest) ProtoMessage()    {}
+func (*RevokeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{11}
+}
+
+func (m *RevokeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeRequest.Unmarshal(m, b)
+}
+func (m *RevokeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeRequest.Marshal(b, m, deterministic)
+}
+func (m *RevokeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeRequest.Merge(m, src)
+}
+func (m *RevokeRequest) XXX_Size() int {
+	return xxx_messageInfo_RevokeRequest.Size(m)
+}
+func (m *RevokeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeRequest proto.InternalMessageInfo
+
+func (m *RevokeRequest) GetId() uint32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *RevokeRequest) GetSession() string {
+	if m != nil {
+		return m.Session
+	}
+	return ""
+}
+
+type DeleteAllRequest struct {
+	Id                   uint32   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Keep                 string   `protobuf:"bytes,2,opt,name=keep,proto3" json:"keep,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteAllRequest) Reset()         { *m = DeleteAllRequest{} }
+func (m *DeleteAllRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteAllRequest) ProtoMessage()    {}
+func (*DeleteAllRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{12}
+}
+
+func (m *DeleteAllRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteAllRequest.Unmarshal(m, b)
+}
+func (m *DeleteAllRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteAllRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteAllRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteAllRequest.Merge(m, src)
+}
+func (m *DeleteAllRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteAllRequest.Size(m)
+}
+func (m *DeleteAllRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteAllRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteAllRequest proto.InternalMessageInfo
+
+func (m *DeleteAllRequest) GetId() uint32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *DeleteAllRequest) GetKeep() string {
+	if m != nil {
+		return m.Keep
+	}
+	return ""
+}
+
+type DeleteAllResponse struct {
+	Deleted              int32    `protobuf:"varint,1,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteAllResponse) Reset()         { *m = DeleteAllResponse{} }
+func (m *DeleteAllResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteAllResponse) ProtoMessage()    {}
+func (*DeleteAllResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{13}
+}
+
+func (m *DeleteAllResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteAllResponse.Unmarshal(m, b)
+}
+func (m *DeleteAllResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteAllResponse.Marshal(b, m, deterministic)
+}
+func (m *DeleteAllResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteAllResponse.Merge(m, src)
+}
+func (m *DeleteAllResponse) XXX_Size() int {
+	return xxx_messageInfo_DeleteAllResponse.Size(m)
+}
+func (m *DeleteAllResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteAllResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteAllResponse proto.InternalMessageInfo
+
+func (m *DeleteAllResponse) GetDeleted() int32 {
+	if m != nil {
+		return m.Deleted
+	}
+	return 0
+}
+
+type PromoteKeyRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PromoteKeyRequest) Reset()         { *m = PromoteKeyRequest{} }
+func (m *PromoteKeyRequest) String() string { return proto.CompactTextString(m) }
+func (*PromoteKeyRequest) ProtoMessage()    {}
+func (*PromoteKeyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{14}
+}
+
+func (m *PromoteKeyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PromoteKeyRequest.Unmarshal(m, b)
+}
+func (m *PromoteKeyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PromoteKeyRequest.Marshal(b, m, deterministic)
+}
+func (m *PromoteKeyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PromoteKeyRequest.Merge(m, src)
+}
+func (m *PromoteKeyRequest) XXX_Size() int {
+	return xxx_messageInfo_PromoteKeyRequest.Size(m)
+}
+func (m *PromoteKeyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PromoteKeyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PromoteKeyRequest proto.InternalMessageInfo
+
+func (m *PromoteKeyRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type PromoteKeyResponse struct {
+	Active               string   `protobuf:"bytes,1,opt,name=active,proto3" json:"active,omitempty"`
+	Previous             string   `protobuf:"bytes,2,opt,name=previous,proto3" json:"previous,omitempty"`
+	Keys                 []string `protobuf:"bytes,3,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PromoteKeyResponse) Reset()         { *m = PromoteKeyResponse{} }
+func (m *PromoteKeyResponse) String() string { return proto.CompactTextString(m) }
+func (*PromoteKeyResponse) ProtoMessage()    {}
+func (*PromoteKeyResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{15}
+}
+
+func (m *PromoteKeyResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PromoteKeyResponse.Unmarshal(m, b)
+}
+func (m *PromoteKeyResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PromoteKeyResponse.Marshal(b, m, deterministic)
+}
+func (m *PromoteKeyResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PromoteKeyResponse.Merge(m, src)
+}
+func (m *PromoteKeyResponse) XXX_Size() int {
+	return xxx_messageInfo_PromoteKeyResponse.Size(m)
+}
+func (m *PromoteKeyResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_PromoteKeyResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PromoteKeyResponse proto.InternalMessageInfo
+
+func (m *PromoteKeyResponse) GetActive() string {
+	if m != nil {
+		return m.Active
+	}
+	return ""
+}
+
+func (m *PromoteKeyResponse) GetPrevious() string {
+	if m != nil {
+		return m.Previous
+	}
+	return ""
+}
+
+func (m *PromoteKeyResponse) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type WatchRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchRequest) Reset()         { *m = WatchRequest{} }
+func (m *WatchRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()    {}
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{16}
+}
+
+func (m *WatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchRequest.Unmarshal(m, b)
+}
+func (m *WatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchRequest.Merge(m, src)
+}
+func (m *WatchRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchRequest.Size(m)
+}
+func (m *WatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchRequest proto.InternalMessageInfo
+
+// RevocationEvent announces a deleted session.
+// An event without session is sent once the subscription is active.
+type RevocationEvent struct {
+	Session              string   `protobuf:"bytes,1,opt,name=session,proto3" json:"session,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevocationEvent) Reset()         { *m = RevocationEvent{} }
+func (m *RevocationEvent) String() string { return proto.CompactTextString(m) }
+func (*RevocationEvent) ProtoMessage()    {}
+func (*RevocationEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{17}
+}
+
+func (m *RevocationEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevocationEvent.Unmarshal(m, b)
+}
+func (m *RevocationEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevocationEvent.Marshal(b, m, deterministic)
+}
+func (m *RevocationEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevocationEvent.Merge(m, src)
+}
+func (m *RevocationEvent) XXX_Size() int {
+	return xxx_messageInfo_RevocationEvent.Size(m)
+}
+func (m *RevocationEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevocationEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevocationEvent proto.InternalMessageInfo
+
+func (m *RevocationEvent) GetSession() string {
+	if m != nil {
+		return m.Session
+	}
+	return ""
+}
+
+type KeysRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *KeysRequest) Reset()         { *m = KeysRequest{} }
+func (m *KeysRequest) String() string { return proto.CompactTextString(m) }
+func (*KeysRequest) ProtoMessage()    {}
+func (*KeysRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{18}
+}
+
+func (m *KeysRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_KeysRequest.Unmarshal(m, b)
+}
+func (m *KeysRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_KeysRequest.Marshal(b, m, deterministic)
+}
+func (m *KeysRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_KeysRequest.Merge(m, src)
+}
+func (m *KeysRequest) XXX_Size() int {
+	return xxx_messageInfo_KeysRequest.Size(m)
+}
+func (m *KeysRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_KeysRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_KeysRequest proto.InternalMessageInfo
+
+// JSONWebKey is a public signing key as described in RFC 7517.
+type JSONWebKey struct {
+	Kid                  string   `protobuf:"bytes,1,opt,name=kid,proto3" json:"kid,omitempty"`
+	Kty                  string   `protobuf:"bytes,2,opt,name=kty,proto3" json:"kty,omitempty"`
+	Alg                  string   `protobuf:"bytes,3,opt,name=alg,proto3" json:"alg,omitempty"`
+	Use                  string   `protobuf:"bytes,4,opt,name=use,proto3" json:"use,omitempty"`
+	N                    string   `protobuf:"bytes,5,opt,name=n,proto3" json:"n,omitempty"`
+	E                    string   `protobuf:"bytes,6,opt,name=e,proto3" json:"e,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *JSONWebKey) Reset()         { *m = JSONWebKey{} }
+func (m *JSONWebKey) String() string { return proto.CompactTextString(m) }
+func (*JSONWebKey) ProtoMessage()    {}
+func (*JSONWebKey) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{19}
+}
+
+func (m *JSONWebKey) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JSONWebKey.Unmarshal(m, b)
+}
+func (m *JSONWebKey) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JSONWebKey.Marshal(b, m, deterministic)
+}
+func (m *JSONWebKey) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JSONWebKey.Merge(m, src)
+}
+func (m *JSONWebKey) XXX_Size() int {
+	return xxx_messageInfo_JSONWebKey.Size(m)
+}
+func (m *JSONWebKey) XXX_DiscardUnknown() {
+	xxx_messageInfo_JSONWebKey.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_JSONWebKey proto.InternalMessageInfo
+
+func (m *JSONWebKey) GetKid() string {
+	if m != nil {
+		return m.Kid
+	}
+	return ""
+}
+
+func (m *JSONWebKey) GetKty() string {
+	if m != nil {
+		return m.Kty
+	}
+	return ""
+}
+
+func (m *JSONWebKey) GetAlg() string {
+	if m != nil {
+		return m.Alg
+	}
+	return ""
+}
+
+func (m *JSONWebKey) GetUse() string {
+	if m != nil {
+		return m.Use
+	}
+	return ""
+}
+
+func (m *JSONWebKey) GetN() string {
+	if m != nil {
+		return m.N
+	}
+	return ""
+}
+
+func (m *JSONWebKey) GetE() string {
+	if m != nil {
+		return m.E
+	}
+	return ""
+}
+
+type KeysResponse struct {
+	Keys                 []*JSONWebKey `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *KeysResponse) Reset()         { *m = KeysResponse{} }
+func (m *KeysResponse) String() string { return proto.CompactTextString(m) }
+func (*KeysResponse) ProtoMessage()    {}
+func (*KeysResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3a6be1b361fa6f14, []int{20}
+}
+
+func (m *KeysResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_KeysResponse.Unmarshal(m, b)
+}
+func (m *KeysResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_KeysResponse.Marshal(b, m, deterministic)
+}
+func (m *KeysResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_KeysResponse.Merge(m, src)
+}
+func (m *KeysResponse) XXX_Size() int {
+	return xxx_messageInfo_KeysResponse.Size(m)
+}
+func (m *KeysResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_KeysResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_KeysResponse proto.InternalMessageInfo
+
+func (m *KeysResponse) GetKeys() []*JSONWebKey {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*CreateRequest)(nil), "api.CreateRequest")
+	proto.RegisterType((*CreateResponse)(nil), "api.CreateResponse")
+	proto.RegisterType((*VerifyRequest)(nil), "api.VerifyRequest")
+	proto.RegisterType((*VerifyResponse)(nil), "api.VerifyResponse")
+	proto.RegisterType((*RefreshRequest)(nil), "api.RefreshRequest")
+	proto.RegisterType((*RefreshResponse)(nil), "api.RefreshResponse")
+	proto.RegisterType((*DeleteRequest)(nil), "api.DeleteRequest")
+	proto.RegisterType((*DeleteResponse)(nil), "api.DeleteResponse")
+	proto.RegisterType((*SessionInfo)(nil), "api.SessionInfo")
+	proto.RegisterType((*ListRequest)(nil), "api.ListRequest")
+	proto.RegisterType((*ListResponse)(nil), "api.ListResponse")
+	proto.RegisterType((*RevokeRequest)(nil), "api.RevokeRequest")
+	proto.RegisterType((*DeleteAllRequest)(nil), "api.DeleteAllRequest")
+	proto.RegisterType((*DeleteAllResponse)(nil), "api.DeleteAllResponse")
+	proto.RegisterType((*PromoteKeyRequest)(nil), "api.PromoteKeyRequest")
+	proto.RegisterType((*PromoteKeyResponse)(nil), "api.PromoteKeyResponse")
+	proto.RegisterType((*WatchRequest)(nil), "api.WatchRequest")
+	proto.RegisterType((*RevocationEvent)(nil), "api.RevocationEvent")
+	proto.RegisterType((*KeysRequest)(nil), "api.KeysRequest")
+	proto.RegisterType((*JSONWebKey)(nil), "api.JSONWebKey")
+	proto.RegisterType((*KeysResponse)(nil), "api.KeysResponse")
+}
+
+func init() { proto.RegisterFile("session.proto", fileDescriptor_3a6be1b361fa6f14) }
+
+var fileDescriptor_3a6be1b361fa6f14 = []byte{
+	// 769 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x55, 0xdd, 0x6e, 0xd3, 0x4c,
+	0x10, 0xad, 0xed, 0xfc, 0x4e, 0x7e, 0x9a, 0x6c, 0xfb, 0xf5, 0xb3, 0x8c, 0x2a, 0x45, 0x5b, 0x81,
+	0x22, 0x41, 0x2b, 0xd4, 0x4a, 0x95, 0x90, 0x90, 0xa0, 0x2a, 0x20, 0x41, 0x11, 0x20, 0x57, 0xd0,
+	0x1b, 0xa4, 0xca, 0x4d, 0xa6, 0xc5, 0x4a, 0xea, 0x35, 0x5e, 0x27, 0xc2, 0x37, 0xbc, 0x0a, 0x2f,
+	0xc0, 0x13, 0xf0, 0x74, 0x68, 0x7f, 0xec, 0xac, 0x93, 0xb4, 0x17, 0xdc, 0xed, 0x9c, 0xcc, 0xec,
+	0xcc, 0x1c, 0x9f, 0xb3, 0x81, 0x0e, 0x47, 0xce, 0x43, 0x16, 0x1d, 0xc4, 0x09, 0x4b, 0x19, 0x71,
+	0x82, 0x38, 0xa4, 0x3f, 0xa1, 0x73, 0x9a, 0x60, 0x90, 0xa2, 0x8f, 0xdf, 0x67, 0xc8, 0x53, 0xd2,
+	0x05, 0x3b, 0x1c, 0xbb, 0xd6, 0xc0, 0x1a, 0x76, 0x7c, 0x3b, 0x1c, 0x13, 0x02, 0x95, 0x84, 0x4d,
+	0xd1, 0xb5, 0x07, 0xd6, 0xb0, 0xe9, 0xcb, 0x33, 0xd9, 0x05, 0x98, 0x71, 0x4c, 0x2e, 0x83, 0x1b,
+	0x8c, 0x52, 0xd7, 0x91, 0xbf, 0x34, 0x05, 0x72, 0x22, 0x00, 0x79, 0x45, 0xec, 0x56, 0x24, 0x6c,
+	0x87, 0x31, 0xf1, 0xa0, 0x91, 0xe0, 0x2d, 0xde, 0x5e, 0x61, 0xe2, 0x56, 0x07, 0xd6, 0xb0, 0xe1,
+	0x17, 0x31, 0x7d, 0x09, 0xdd, 0xbc, 0x3f, 0x8f, 0x59, 0xc4, 0x91, 0x6c, 0x43, 0x35, 0x65, 0x13,
+	0x8c, 0xe4, 0x0c, 0x4d, 0x5f, 0x05, 0xc4, 0x85, 0x3a, 0xfe, 0x88, 0xc3, 0x04, 0xb9, 0x9c, 0xc4,
+	0xf1, 0xf3, 0x90, 0x3e, 0x84, 0xce, 0x17, 0x4c, 0xc2, 0xeb, 0x2c, 0xdf, 0x60, 0xed, 0x05, 0xf4,
+	0xb7, 0x05, 0xdd, 0x3c, 0x4f, 0x77, 0xea, 0x82, 0xcd, 0x26, 0x32, 0xab, 0xe1, 0xdb, 0x6c, 0xa2,
+	0x57, 0xb7, 0x57, 0x56, 0x77, 0x8c, 0xd5, 0x5d, 0xa8, 0x6b, 0x16, 0xf5, 0x82, 0x79, 0x48, 0x76,
+	0xa0, 0x16, 0x72, 0x3e, 0xc3, 0xb1, 0xdc, 0xd1, 0xf1, 0x75, 0x64, 0x4e, 0x5e, 0x2b, 0x4d, 0x5e,
+	0xe2, 0xa5, 0xbe, 0xc4, 0xcb, 0x23, 0xe8, 0xfa, 0x78, 0x9d, 0x20, 0xff, 0x76, 0xff, 0x5a, 0x27,
+	0xb0, 0x59, 0xe4, 0xfd, 0x3b, 0x81, 0xaf, 0x70, 0x8a, 0x0b, 0x09, 0xac, 0xef, 0xd4, 0x83, 0x6e,
+	0x9e, 0xa6, 0x1a, 0xd1, 0x5f, 0x16, 0xb4, 0xce, 0xd5, 0xf6, 0x6f, 0xa3, 0x6b, 0x66, 0x48, 0xa7,
+	0x29, 0xf9, 0x73, 0xa1, 0x3e, 0x92, 0xdf, 0x76, 0x9c, 0xb7, 0xd4, 0x21, 0x79, 0x00, 0xcd, 0x69,
+	0xc0, 0xd3, 0x4b, 0x8e, 0x18, 0x49, 0x7a, 0x1d, 0xbf, 0x21, 0x80, 0x73, 0xc4, 0x68, 0x49, 0x5d,
+	0x95, 0xf5, 0xea, 0xaa, 0xae, 0x55, 0x57, 0x6d, 0x89, 0xc5, 0x5d, 0x68, 0xbd, 0x0f, 0x79, 0x7a,
+	0x87, 0xb6, 0xe9, 0x73, 0x68, 0xab, 0x9f, 0x35, 0x73, 0x4f, 0xa0, 0xa1, 0xbf, 0x26, 0x77, 0xad,
+	0x81, 0x33, 0x6c, 0x1d, 0xf6, 0x0e, 0x82, 0x38, 0x3c, 0x30, 0x96, 0xf4, 0x8b, 0x0c, 0xfa, 0x0c,
+	0x3a, 0x3e, 0xce, 0xd9, 0xe4, 0x4e, 0xeb, 0x18, 0x5a, 0xb1, 0x4b, 0x5a, 0xa1, 0xc7, 0xd0, 0x53,
+	0x5c, 0x9e, 0x4c, 0xa7, 0xf7, 0x18, 0x6f, 0x82, 0x18, 0xe7, 0xc6, 0x13, 0x67, 0xba, 0x0f, 0x7d,
+	0xa3, 0x4e, 0x4f, 0xed, 0x42, 0x7d, 0x2c, 0x41, 0x55, 0x5d, 0xf5, 0xf3, 0x90, 0xee, 0x41, 0xff,
+	0x53, 0xc2, 0x6e, 0x59, 0x8a, 0x67, 0x98, 0xad, 0xf6, 0x91, 0x5f, 0x89, 0x7e, 0x05, 0x62, 0x26,
+	0xe9, 0x4b, 0x77, 0xa0, 0x16, 0x8c, 0xd2, 0x70, 0x8e, 0x3a, 0x53, 0x47, 0x82, 0xed, 0x38, 0xc1,
+	0x79, 0xc8, 0x66, 0x5c, 0x4f, 0x56, 0xc4, 0x6a, 0xe2, 0x8c, 0xbb, 0xce, 0xc0, 0x51, 0x13, 0x67,
+	0x9c, 0x76, 0xa1, 0x7d, 0x11, 0xa4, 0xa3, 0x5c, 0xc5, 0xf4, 0xb1, 0xd0, 0xeb, 0x9c, 0x8d, 0x82,
+	0x34, 0x64, 0xd1, 0xeb, 0xb9, 0xf8, 0xa0, 0x06, 0x4d, 0x56, 0x99, 0xa6, 0x0e, 0xb4, 0xce, 0x30,
+	0xe3, 0x79, 0x6d, 0x0c, 0xf0, 0xee, 0xfc, 0xe3, 0x87, 0x0b, 0xbc, 0x3a, 0xc3, 0x8c, 0xf4, 0xc0,
+	0x99, 0x14, 0x8b, 0x88, 0xa3, 0x44, 0xd2, 0x4c, 0x8f, 0x25, 0x8e, 0x02, 0x09, 0xa6, 0x37, 0xda,
+	0xc0, 0xe2, 0x28, 0x90, 0x19, 0x47, 0xad, 0x2a, 0x71, 0x24, 0x6d, 0xb0, 0x22, 0x2d, 0x27, 0x2b,
+	0x12, 0x11, 0x4a, 0x19, 0x35, 0x7d, 0x0b, 0xe9, 0x11, 0xb4, 0xd5, 0x00, 0x9a, 0x95, 0x3d, 0xbd,
+	0xa1, 0x12, 0xc7, 0xa6, 0x14, 0xc7, 0x62, 0x24, 0xb5, 0xf2, 0xe1, 0x9f, 0x0a, 0xd4, 0xb5, 0x62,
+	0xc8, 0x11, 0xd4, 0xd4, 0xf3, 0x46, 0x88, 0x4c, 0x2e, 0xbd, 0xb5, 0xde, 0x56, 0x09, 0xd3, 0xae,
+	0xda, 0x10, 0x45, 0xea, 0xa5, 0xd2, 0x45, 0xa5, 0xe7, 0xcd, 0xdb, 0x2a, 0x61, 0x66, 0x91, 0x92,
+	0x86, 0x2e, 0x2a, 0x59, 0xda, 0xdb, 0x2a, 0x61, 0x45, 0xd1, 0x31, 0xd4, 0xf5, 0xeb, 0x41, 0x54,
+	0x46, 0xf9, 0xcd, 0xf1, 0xb6, 0xcb, 0x60, 0x51, 0xb7, 0x0f, 0x15, 0x61, 0x1c, 0xa2, 0xec, 0x61,
+	0x58, 0xcc, 0xeb, 0x1b, 0x88, 0x39, 0x9b, 0x72, 0x8a, 0x9e, 0xad, 0x64, 0x9b, 0xbb, 0x66, 0x3b,
+	0x35, 0x3c, 0xf2, 0x86, 0x25, 0x9f, 0x39, 0x26, 0xe4, 0x3f, 0x23, 0x75, 0x61, 0x1d, 0x6f, 0x67,
+	0x19, 0x2e, 0x2e, 0x79, 0x01, 0xb0, 0x10, 0x37, 0x51, 0x79, 0x2b, 0x96, 0xf0, 0xfe, 0x5f, 0xc1,
+	0x8d, 0x0b, 0x7a, 0x5a, 0xbf, 0xb9, 0x68, 0x39, 0x51, 0x3b, 0x9a, 0xb2, 0x2e, 0x88, 0x2a, 0x29,
+	0x9b, 0x6e, 0x3c, 0xb5, 0x04, 0x55, 0x42, 0x42, 0x9a, 0x2a, 0x43, 0xce, 0x5e, 0xdf, 0x40, 0xf2,
+	0x7e, 0x57, 0x35, 0xf9, 0xdf, 0x7c, 0xf4, 0x77, 0x00, 0x5b, 0xab, 0x41, 0xc3, 0xac, 0x07, 0x00,
+	0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// SessionClient is the client API for Session service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type SessionClient interface {
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
+	Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Refresh(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*RefreshResponse, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	DeleteAllForUser(ctx context.Context, in *DeleteAllRequest, opts ...grpc.CallOption) (*DeleteAllResponse, error)
+	PromoteKey(ctx context.Context, in *PromoteKeyRequest, opts ...grpc.CallOption) (*PromoteKeyResponse, error)
+	WatchRevocations(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Session_WatchRevocationsClient, error)
+	Keys(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*KeysResponse, error)
+}
+
+type sessionClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewSessionClient(cc *grpc.ClientConn) SessionClient {
+	return &sessionClient{cc}
+}
+
+func (c *sessionClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error) {
+	out := new(CreateResponse)
+	err := c.cc.Invoke(ctx, "/api.Session/Create", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionClient) Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error) {
+	out := new(VerifyResponse)
+	err := c.cc.Invoke(ctx, "/api.Session/Verify", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/api.Session/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionClient) Refresh(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*RefreshResponse, error) {
+	out := new(RefreshResponse)
+	err := c.cc.Invoke(ctx, "/api.Session/Refresh", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, "/api.Session/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionClient) Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/api.Session/Revoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionClient) DeleteAllForUser(ctx context.Context, in *DeleteAllRequest, opts ...grpc.CallOption) (*DeleteAllResponse, error) {
+	out := new(DeleteAllResponse)
+	err := c.cc.Invoke(ctx, "/api.Session/DeleteAllForUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionClient) PromoteKey(ctx context.Context, in *PromoteKeyRequest, opts ...grpc.CallOption) (*PromoteKeyResponse, error) {
+	out := new(PromoteKeyResponse)
+	err := c.cc.Invoke(ctx, "/api.Session/PromoteKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionClient) WatchRevocations(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Session_WatchRevocationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Session_serviceDesc.Streams[0], "/api.Session/WatchRevocations", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sessionWatchRevocationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Session_WatchRevocationsClient interface {
+	Recv() (*RevocationEvent, error)
+	grpc.ClientStream
+}
+
+type sessionWatchRevocationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *sessionWatchRevocationsClient) Recv() (*RevocationEvent, error) {
+	m := new(RevocationEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *sessionClient) Keys(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*KeysResponse, error) {
+	out := new(KeysResponse)
+	err := c.cc.Invoke(ctx, "/api.Session/Keys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SessionServer is the server API for Session service.
+type SessionServer interface {
+	Create(context.Context, *CreateRequest) (*CreateResponse, error)
+	Verify(context.Context, *VerifyRequest) (*VerifyResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Refresh(context.Context, *RefreshRequest) (*RefreshResponse, error)
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Revoke(context.Context, *RevokeRequest) (*DeleteResponse, error)
+	DeleteAllForUser(context.Context, *DeleteAllRequest) (*DeleteAllResponse, error)
+	PromoteKey(context.Context, *PromoteKeyRequest) (*PromoteKeyResponse, error)
+	WatchRevocations(*WatchRequest, Session_WatchRevocationsServer) error
+	Keys(context.Context, *KeysRequest) (*KeysResponse, error)
+}
+
+// UnimplementedSessionServer can be embedded to have forward compatible implementations.
+type UnimplementedSessionServer struct {
+}
+
+func (*UnimplementedSessionServer) Create(ctx context.Context, req *CreateRequest) (*CreateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (*UnimplementedSessionServer) Verify(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Verify not implemented")
+}
+func (*UnimplementedSessionServer) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (*UnimplementedSessionServer) Refresh(ctx context.Context, req *RefreshRequest) (*RefreshResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Refresh not implemented")
+}
+func (*UnimplementedSessionServer) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (*UnimplementedSessionServer) Revoke(ctx context.Context, req *RevokeRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
+func (*UnimplementedSessionServer) DeleteAllForUser(ctx context.Context, req *DeleteAllRequest) (*DeleteAllResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAllForUser not implemented")
+}
+func (*UnimplementedSessionServer) PromoteKey(ctx context.Context, req *PromoteKeyRequest) (*PromoteKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PromoteKey not implemented")
+}
+func (*UnimplementedSessionServer) WatchRevocations(req *WatchRequest, srv Session_WatchRevocationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRevocations not implemented")
+}
+func (*UnimplementedSessionServer) Keys(ctx context.Context, req *KeysRequest) (*KeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Keys not implemented")
+}
+
+func RegisterSessionServer(s *grpc.Server, srv SessionServer) {
+	s.RegisterService(&_Session_serviceDesc, srv)
+}
+
+func _Session_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Session/Create",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Session_Verify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionServer).Verify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Session/Verify",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionServer).Verify(ctx, req.(*VerifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Session_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Session/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Session_Refresh_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionServer).Refresh(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Session/Refresh",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionServer).Refresh(ctx, req.(*RefreshRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Session_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Session/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Session_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Session/Revoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionServer).Revoke(ctx, req.(*RevokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Session_DeleteAllForUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionServer).DeleteAllForUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Session/DeleteAllForUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionServer).DeleteAllForUser(ctx, req.(*DeleteAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Session_PromoteKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PromoteKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionServer).PromoteKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Session/PromoteKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionServer).PromoteKey(ctx, req.(*PromoteKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Session_WatchRevocations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SessionServer).WatchRevocations(m, &sessionWatchRevocationsServer{stream})
+}
+
+type Session_WatchRevocationsServer interface {
+	Send(*RevocationEvent) error
+	grpc.ServerStream
+}
+
+type sessionWatchRevocationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *sessionWatchRevocationsServer) Send(m *RevocationEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Session_Keys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionServer).Keys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Session/Keys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionServer).Keys(ctx, req.(*KeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Session_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "api.Session",
+	HandlerType: (*SessionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _Session_Create_Handler,
+		},
+		{
+			MethodName: "Verify",
+			Handler:    _Session_Verify_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Session_Delete_Handler,
+		},
+		{
+			MethodName: "Refresh",
+			Handler:    _Session_Refresh_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _Session_List_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _Session_Revoke_Handler,
+		},
+		{
+			MethodName: "DeleteAllForUser",
+			Handler:    _Session_DeleteAllForUser_Handler,
+		},
+		{
+			MethodName: "PromoteKey",
+			Handler:    _Session_PromoteKey_Handler,
+		},
+		{
+			MethodName: "Keys",
+			Handler:    _Session_Keys_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRevocations",
+			Handler:       _Session_WatchRevocations_Handler,
+			ServerStreams: true,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "session.proto",
 }
@@ -10,6 +10,7 @@ import (
 	health "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/go-redis/redis"
+	"github.com/lnsp/microlog/common/keyring"
 	"github.com/lnsp/microlog/common/logger"
 	"github.com/lnsp/microlog/session/api"
 	"github.com/pkg/errors"
@@ -19,49 +20,71 @@ import (
 
 // Config stores configuration context for the Session implementation.
 type Config struct {
-	Secret         []byte
-	RedisAddr      string
-	RedisPassword  string
-	ExpirationTime time.Duration
+	Keys                   *keyring.Keyring
+	RedisAddr              string
+	RedisPassword          string
+	ExpirationTime         time.Duration
+	RememberExpirationTime time.Duration
 }
 
 // Server is an implementation of the SessionServer.
 type Server struct {
-	secret     []byte
-	redis      *redis.Client
-	expiration time.Duration
+	keys               *keyring.Keyring
+	redis              *redis.Client
+	expiration         time.Duration
+	rememberExpiration time.Duration
 }
 
 var log = logger.New()
 
 // Create initiates a new session with the given role and identity context.
+// The user agent and IP of the client are stored as session metadata.
+// Sessions created with remember me use the longer remember expiration time.
 func (s *Server) Create(ctx context.Context, req *api.CreateRequest) (*api.CreateResponse, error) {
 	log := log.WithFields(logrus.Fields{
 		"identity": req.Id,
 		"role":     req.Role,
+		"remember": req.Remember,
 	})
-	token, err := s.GenerateToken(&UserInfo{
+	id, err := newSessionID()
+	if err != nil {
+		log.WithError(err).Warn("failed to generate session id")
+		return nil, err
+	}
+	token, expires, err := s.GenerateToken(id, &UserInfo{
 		Identity: req.Id,
 		Role:     req.Role,
-	})
+		Remember: req.Remember,
+	}, s.lifetime(req.Remember))
 	if err != nil {
 		log.WithError(err).Warn("failed to generate token")
 		return nil, errors.Wrap(err, "failed to generate token")
 	}
-	if err := s.redis.Set(token, "active", s.expiration).Err(); err != nil {
-		log.WithError(err).Warn("failed to save token")
-		return nil, errors.Wrap(err, "failed to save token")
+	now := time.Now()
+	if err := s.saveSession(&sessionData{
+		ID:        id,
+		Identity:  req.Id,
+		Role:      req.Role,
+		Created:   now,
+		LastSeen:  now,
+		UserAgent: req.UserAgent,
+		IP:        req.Ip,
+		Remember:  req.Remember,
+	}); err != nil {
+		log.WithError(err).Warn("failed to save session")
+		return nil, err
 	}
-	log.Debug("created session")
+	log.WithField("session", id).Debug("created session")
 	return &api.CreateResponse{
-		Token: token,
+		Token:   token,
+		Expires: expires.Unix(),
 	}, nil
 }
 
-// Verify verifies if a given token is valid.
+// Verify verifies if a given token is valid and belongs to an active session.
 func (s *Server) Verify(ctx context.Context, req *api.VerifyRequest) (*api.VerifyResponse, error) {
 	log := log.WithField("token", req.Token)
-	info, err := s.ProofToken(req.Token)
+	claims, err := s.ProofToken(req.Token)
 	if err != nil {
 		log.WithError(err).Debug("failed to verify token")
 		return &api.VerifyResponse{
@@ -69,43 +92,233 @@ func (s *Server) Verify(ctx context.Context, req *api.VerifyRequest) (*api.Verif
 		}, nil
 	}
 	log = log.WithFields(logrus.Fields{
-		"identity": info.Identity,
-		"role":     info.Role,
+		"identity": claims.Identity,
+		"role":     claims.Role,
+		"session":  claims.Id,
 	})
-	if active := s.redis.Get(req.Token).String(); active == "" {
-		log.WithError(err).Warn("attempt to sign in using deleted session")
+	active, err := s.touchSession(claims.Id)
+	if err != nil {
+		log.WithError(err).Warn("failed to check session")
+		return nil, err
+	}
+	if !active {
+		log.Warn("attempt to sign in using deleted session")
 		return &api.VerifyResponse{
 			Ok: false,
 		}, nil
 	}
 	log.Debug("verified session")
 	return &api.VerifyResponse{
-		Ok:   true,
-		Id:   info.Identity,
-		Role: info.Role,
+		Ok:       true,
+		Id:       claims.Identity,
+		Role:     claims.Role,
+		Session:  claims.Id,
+		Issued:   claims.IssuedAt,
+		Expires:  claims.ExpiresAt,
+		Remember: claims.Remember,
+	}, nil
+}
+
+// Refresh extends the lifetime of an active session and reissues its token.
+// The new token keeps the session ID, so the session stays revocable as before.
+func (s *Server) Refresh(ctx context.Context, req *api.RefreshRequest) (*api.RefreshResponse, error) {
+	log := log.WithField("token", req.Token)
+	claims, err := s.ProofToken(req.Token)
+	if err != nil {
+		log.WithError(err).Debug("failed to verify token")
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	log = log.WithFields(logrus.Fields{
+		"identity": claims.Identity,
+		"role":     claims.Role,
+		"session":  claims.Id,
+	})
+	active, remember, err := s.extendSession(claims.Identity, claims.Id)
+	if err != nil {
+		log.WithError(err).Warn("failed to extend session")
+		return nil, err
+	}
+	if !active {
+		log.Warn("attempt to refresh deleted session")
+		return nil, status.Error(codes.Unauthenticated, "session not found")
+	}
+	info := claims.UserInfo
+	info.Remember = remember
+	token, expires, err := s.GenerateToken(claims.Id, &info, s.lifetime(remember))
+	if err != nil {
+		log.WithError(err).Warn("failed to generate token")
+		return nil, errors.Wrap(err, "failed to generate token")
+	}
+	log.Debug("refreshed session")
+	return &api.RefreshResponse{
+		Token:   token,
+		Expires: expires.Unix(),
 	}, nil
 }
 
 // Delete removes an active session from the session store.
 func (s *Server) Delete(ctx context.Context, req *api.DeleteRequest) (*api.DeleteResponse, error) {
 	log := log.WithField("token", req.Token)
-	info, err := s.ProofToken(req.Token)
+	claims, err := s.ProofToken(req.Token)
 	if err != nil {
 		log.WithError(err).Debug("failed to verify token")
 		return nil, errors.Wrap(err, "failed to verify token")
 	}
 	log = log.WithFields(logrus.Fields{
-		"identity": info.Identity,
-		"role":     info.Role,
+		"identity": claims.Identity,
+		"role":     claims.Role,
+		"session":  claims.Id,
 	})
-	if err := s.redis.Del(req.Token).Err(); err != nil {
+	if err := s.deleteSession(claims.Identity, claims.Id); err != nil {
 		log.WithError(err).Warn("failed to delete session")
-		return nil, errors.Wrap(err, "failed to delete session")
+		return nil, err
 	}
 	log.Debug("deleted session")
 	return &api.DeleteResponse{}, nil
 }
 
+// List returns the active sessions of the given identity.
+func (s *Server) List(ctx context.Context, req *api.ListRequest) (*api.ListResponse, error) {
+	log := log.WithField("identity", req.Id)
+	sessions, err := s.listSessions(req.Id)
+	if err != nil {
+		log.WithError(err).Warn("failed to list sessions")
+		return nil, err
+	}
+	resp := &api.ListResponse{
+		Sessions: make([]*api.SessionInfo, len(sessions)),
+	}
+	for i, session := range sessions {
+		resp.Sessions[i] = &api.SessionInfo{
+			Id:        session.ID,
+			Created:   session.Created.Unix(),
+			LastSeen:  session.LastSeen.Unix(),
+			UserAgent: session.UserAgent,
+			Ip:        session.IP,
+			Remember:  session.Remember,
+		}
+	}
+	return resp, nil
+}
+
+// Revoke removes the session with the given ID if it belongs to the given identity.
+func (s *Server) Revoke(ctx context.Context, req *api.RevokeRequest) (*api.DeleteResponse, error) {
+	log := log.WithFields(logrus.Fields{
+		"identity": req.Id,
+		"session":  req.Session,
+	})
+	owned, err := s.ownsSession(req.Id, req.Session)
+	if err != nil {
+		log.WithError(err).Warn("failed to check session")
+		return nil, err
+	}
+	if !owned {
+		log.Warn("attempt to revoke foreign session")
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	if err := s.deleteSession(req.Id, req.Session); err != nil {
+		log.WithError(err).Warn("failed to revoke session")
+		return nil, err
+	}
+	log.Debug("revoked session")
+	return &api.DeleteResponse{}, nil
+}
+
+// DeleteAllForUser removes all sessions of the given identity, optionally keeping a single one.
+func (s *Server) DeleteAllForUser(ctx context.Context, req *api.DeleteAllRequest) (*api.DeleteAllResponse, error) {
+	log := log.WithFields(logrus.Fields{
+		"identity": req.Id,
+		"keep":     req.Keep,
+	})
+	deleted, err := s.deleteSessions(req.Id, req.Keep)
+	if err != nil {
+		log.WithError(err).Warn("failed to delete sessions")
+		return nil, err
+	}
+	log.WithField("count", deleted).Debug("deleted sessions")
+	return &api.DeleteAllResponse{
+		Deleted: int32(deleted),
+	}, nil
+}
+
+// AdminMethods are the methods only admins may call, since they change the configuration of all replicas.
+var AdminMethods = []string{"/api.Session/PromoteKey"}
+
+// PromoteKey makes the given key the signing key for new tokens.
+// Tokens signed by the previous key stay valid as long as it is part of the keyring.
+func (s *Server) PromoteKey(ctx context.Context, req *api.PromoteKeyRequest) (*api.PromoteKeyResponse, error) {
+	log := log.WithField("key", req.Id)
+	previous, err := s.keys.Promote(req.Id)
+	if errors.Cause(err) == keyring.ErrUnknownKey {
+		log.WithError(err).Warn("attempt to promote unknown key")
+		return nil, status.Error(codes.NotFound, "key not found")
+	} else if err == keyring.ErrNoKeyFile {
+		log.WithError(err).Warn("attempt to promote key without key file")
+		return nil, status.Error(codes.FailedPrecondition, "promoting keys requires a key file shared by all replicas")
+	} else if err != nil {
+		log.WithError(err).Error("failed to promote key")
+		return nil, err
+	}
+	log.WithField("previous", previous).Info("promoted signing key")
+	return &api.PromoteKeyResponse{
+		Active:   req.Id,
+		Previous: previous,
+		Keys:     s.keys.IDs(),
+	}, nil
+}
+
+// Keys returns the public keys used to sign session tokens.
+// Services holding them can verify tokens without asking the session service.
+func (s *Server) Keys(ctx context.Context, req *api.KeysRequest) (*api.KeysResponse, error) {
+	jwks := s.keys.JSONWebKeys()
+	resp := &api.KeysResponse{
+		Keys: make([]*api.JSONWebKey, len(jwks)),
+	}
+	for i, jwk := range jwks {
+		resp.Keys[i] = &api.JSONWebKey{
+			Kid: jwk.KeyID,
+			Kty: jwk.KeyType,
+			Alg: jwk.Algorithm,
+			Use: jwk.Use,
+			N:   jwk.N,
+			E:   jwk.E,
+		}
+	}
+	return resp, nil
+}
+
+// WatchRevocations streams the IDs of deleted sessions to the client.
+// Once the subscription is active an empty event is sent, deletions before are not announced.
+func (s *Server) WatchRevocations(req *api.WatchRequest, stream api.Session_WatchRevocationsServer) error {
+	pubsub := s.redis.Subscribe(revocationChannel)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(); err != nil {
+		log.WithError(err).Warn("failed to subscribe to revocations")
+		return errors.Wrap(err, "failed to subscribe to revocations")
+	}
+	if err := stream.Send(&api.RevocationEvent{}); err != nil {
+		return err
+	}
+	log.Debug("started revocation watch")
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-stream.Context().Done():
+			log.Debug("stopped revocation watch")
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return status.Error(codes.Unavailable, "revocation subscription closed")
+			}
+			if err := stream.Send(&api.RevocationEvent{
+				Session: msg.Payload,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 // Health returns an implementation of the GRPC Health Checking service.
 func (s *Server) Health() health.HealthServer {
 	return &healthServer{s}
@@ -136,8 +349,9 @@ func NewServer(cfg *Config) *Server {
 		Password: cfg.RedisPassword,
 	})
 	return &Server{
-		secret:     cfg.Secret,
-		redis:      redisClient,
-		expiration: cfg.ExpirationTime,
+		keys:               cfg.Keys,
+		redis:              redisClient,
+		expiration:         cfg.ExpirationTime,
+		rememberExpiration: cfg.RememberExpirationTime,
 	}
 }
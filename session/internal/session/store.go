@@ -0,0 +1,211 @@
+package session
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+)
+
+// sessionData stores the metadata of an active session.
+type sessionData struct {
+	ID        string
+	Identity  uint32
+	Role      string
+	Created   time.Time
+	LastSeen  time.Time
+	UserAgent string
+	IP        string
+	Remember  bool
+}
+
+// touchScript updates the last seen time of a session if it is still active.
+// Running it as a script prevents recreating sessions which expire in between.
+var touchScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("HSET", KEYS[1], "last_seen", ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// revocationChannel is the redis channel deleted session IDs are published on.
+const revocationChannel = "sessions:revoked"
+
+// sessionKey returns the redis key of the session hash.
+func sessionKey(id string) string {
+	return "session:" + id
+}
+
+// indexKey returns the redis key of the set of session IDs belonging to the identity.
+func indexKey(identity uint32) string {
+	return fmt.Sprintf("sessions:%d", identity)
+}
+
+// newSessionID generates a random unique session ID.
+func newSessionID() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", errors.Wrap(err, "failed to generate session id")
+	}
+	return fmt.Sprintf("%x", random), nil
+}
+
+// saveSession stores the session and adds it to the index of its identity.
+func (s *Server) saveSession(data *sessionData) error {
+	key, index := sessionKey(data.ID), indexKey(data.Identity)
+	_, err := s.redis.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HMSet(key, map[string]interface{}{
+			"identity":   data.Identity,
+			"role":       data.Role,
+			"created":    data.Created.Unix(),
+			"last_seen":  data.LastSeen.Unix(),
+			"user_agent": data.UserAgent,
+			"ip":         data.IP,
+			"remember":   data.Remember,
+		})
+		pipe.Expire(key, s.lifetime(data.Remember))
+		pipe.SAdd(index, data.ID)
+		// The index outlives every session, stale entries are pruned when listing.
+		pipe.Expire(index, s.maxLifetime())
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to save session")
+	}
+	return nil
+}
+
+// lifetime returns how long a session stays valid without being refreshed.
+func (s *Server) lifetime(remember bool) time.Duration {
+	if remember {
+		return s.rememberExpiration
+	}
+	return s.expiration
+}
+
+// maxLifetime returns the longest lifetime any session can have.
+func (s *Server) maxLifetime() time.Duration {
+	if s.rememberExpiration > s.expiration {
+		return s.rememberExpiration
+	}
+	return s.expiration
+}
+
+// extendSession resets the expiration of an active session and its index.
+// It returns whether the session is still active and whether it was created with remember me.
+func (s *Server) extendSession(identity uint32, id string) (bool, bool, error) {
+	key := sessionKey(id)
+	remember, err := s.redis.HGet(key, "remember").Result()
+	if err == redis.Nil {
+		return false, false, nil
+	} else if err != nil {
+		return false, false, errors.Wrap(err, "failed to get session")
+	}
+	long, _ := strconv.ParseBool(remember)
+	var extended *redis.BoolCmd
+	_, err = s.redis.TxPipelined(func(pipe redis.Pipeliner) error {
+		extended = pipe.Expire(key, s.lifetime(long))
+		pipe.Expire(indexKey(identity), s.maxLifetime())
+		return nil
+	})
+	if err != nil {
+		return false, false, errors.Wrap(err, "failed to extend session")
+	}
+	return extended.Val(), long, nil
+}
+
+// touchSession marks the session as seen right now.
+// It returns false if the session is not active anymore.
+func (s *Server) touchSession(id string) (bool, error) {
+	active, err := touchScript.Run(s.redis, []string{sessionKey(id)}, time.Now().Unix()).Int()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to touch session")
+	}
+	return active == 1, nil
+}
+
+// deleteSession removes the session and its index entry.
+// The deletion is announced to revocation watchers.
+func (s *Server) deleteSession(identity uint32, id string) error {
+	_, err := s.redis.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(sessionKey(id))
+		pipe.SRem(indexKey(identity), id)
+		pipe.Publish(revocationChannel, id)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete session")
+	}
+	return nil
+}
+
+// ownsSession checks if the session belongs to the given identity.
+func (s *Server) ownsSession(identity uint32, id string) (bool, error) {
+	owned, err := s.redis.SIsMember(indexKey(identity), id).Result()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check session")
+	}
+	return owned, nil
+}
+
+// listSessions returns the active sessions of the given identity.
+// Index entries of expired sessions are removed on the way.
+func (s *Server) listSessions(identity uint32) ([]sessionData, error) {
+	index := indexKey(identity)
+	ids, err := s.redis.SMembers(index).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list sessions")
+	}
+	sessions := make([]sessionData, 0, len(ids))
+	for _, id := range ids {
+		fields, err := s.redis.HGetAll(sessionKey(id)).Result()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get session")
+		}
+		if len(fields) == 0 {
+			if err := s.redis.SRem(index, id).Err(); err != nil {
+				return nil, errors.Wrap(err, "failed to prune session index")
+			}
+			continue
+		}
+		created, _ := strconv.ParseInt(fields["created"], 10, 64)
+		lastSeen, _ := strconv.ParseInt(fields["last_seen"], 10, 64)
+		remember, _ := strconv.ParseBool(fields["remember"])
+		sessions = append(sessions, sessionData{
+			ID:        id,
+			Identity:  identity,
+			Role:      fields["role"],
+			Created:   time.Unix(created, 0),
+			LastSeen:  time.Unix(lastSeen, 0),
+			UserAgent: fields["user_agent"],
+			IP:        fields["ip"],
+			Remember:  remember,
+		})
+	}
+	return sessions, nil
+}
+
+// deleteSessions removes all sessions of the given identity except the one to keep.
+// It returns the number of deleted sessions.
+func (s *Server) deleteSessions(identity uint32, keep string) (int, error) {
+	index := indexKey(identity)
+	ids, err := s.redis.SMembers(index).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list sessions")
+	}
+	deleted := 0
+	for _, id := range ids {
+		if id == keep {
+			continue
+		}
+		if err := s.deleteSession(identity, id); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
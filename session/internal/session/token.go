@@ -11,6 +11,7 @@ import (
 type UserInfo struct {
 	Identity uint32
 	Role     string
+	Remember bool
 }
 
 // Claims store user information in a JWT compatible way.
@@ -19,34 +20,37 @@ type Claims struct {
 	UserInfo
 }
 
-// GenerateToken generates a new token based on the given user info.
-func (s *Server) GenerateToken(info *UserInfo) (string, error) {
+// GenerateToken generates a new token for the given session ID based on the given user info.
+// The token is valid for the given lifetime.
+// It returns the signed token and its expiration time.
+func (s *Server) GenerateToken(id string, info *UserInfo, lifetime time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expires := now.Add(lifetime)
 	claims := &Claims{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(s.expiration).Unix(),
+			Id:        id,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expires.Unix(),
 		},
 		UserInfo: *info,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(s.secret)
+	signed, err := s.keys.Sign(claims)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to generate token")
+		return "", time.Time{}, errors.Wrap(err, "failed to generate token")
 	}
-	return signed, nil
+	return signed, time.Unix(expires.Unix(), 0), nil
 }
 
 // ProofToken checks if the given string is a valid token.
-// If the token is valid, the retrieved user information will be returned.
-func (s *Server) ProofToken(signed string) (*UserInfo, error) {
+// If the token is valid, the retrieved claims including the session ID will be returned.
+func (s *Server) ProofToken(signed string) (*Claims, error) {
 	var claims Claims
-	_, err := jwt.ParseWithClaims(signed, &claims, func(token *jwt.Token) (interface{}, error) {
-		if token.Method != jwt.SigningMethodHS256 {
-			return nil, errors.New("unexpected signing method")
-		}
-		return s.secret, nil
-	})
+	_, err := jwt.ParseWithClaims(signed, &claims, s.keys.Keyfunc)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse token")
 	}
-	return &claims.UserInfo, nil
+	if claims.Id == "" {
+		return nil, errors.New("missing session id")
+	}
+	return &claims, nil
 }
@@ -7,7 +7,9 @@ import (
 	health "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/lnsp/microlog/common/keyring"
 	"github.com/lnsp/microlog/common/logger"
+	"github.com/lnsp/microlog/common/servertls"
 	"github.com/lnsp/microlog/session/api"
 	"github.com/lnsp/microlog/session/internal/session"
 	"google.golang.org/grpc"
@@ -16,11 +18,21 @@ import (
 var log = logger.New()
 
 type specification struct {
-	Secret        string `required:"true" desc:"Signing key for session tokens"`
+	Secret        string `desc:"Signing key for session tokens, available as key ID default"`
+	Keys          string `desc:"Additional signing keys as comma separated id:secret pairs"`
+	ActiveKey     string `desc:"ID of the key used to sign new tokens"`
+	KeyFile       string `desc:"JSON file containing the active key ID and keys, persists promotions"`
+	KeyDir        string `desc:"Directory of PEM encoded RSA keys named <id>.pem, used to sign RS256 tokens"`
 	Redis         string `required:"true" desc:"Address for redis data store"`
 	RedisPassword string `required:"true" desc:"Password for redis data store"`
 	Addr          string `default:":8080" desc:"Address the service is listening on"`
+	TLSCert       string `desc:"Server certificate, enables TLS"`
+	TLSKey        string `desc:"Key of the server certificate"`
+	TLSClientCA   string `desc:"CA certificate bundle, enables mutual TLS by requiring client certificates"`
+	TLSAdmins     string `desc:"Comma separated common names of client certificates allowed to promote keys, requires TLSClientCA"`
 	Expiration    string `default:"24h" desc:"Set expiration time"`
+	// RememberExpiration applies to sessions created with remember me.
+	RememberExpiration string `default:"720h" desc:"Set expiration time of remembered sessions"`
 }
 
 func main() {
@@ -37,12 +49,37 @@ func main() {
 	if err != nil {
 		log.WithError(err).Fatal("bad expiration time format")
 	}
-	grpcServer := grpc.NewServer()
+	rememberExpirationTime, err := time.ParseDuration(spec.RememberExpiration)
+	if err != nil {
+		log.WithError(err).Fatal("bad remember expiration time format")
+	}
+	keys, err := keyring.New(keyring.Config{
+		Secret: spec.Secret,
+		Keys:   spec.Keys,
+		Active: spec.ActiveKey,
+		File:   spec.KeyFile,
+		Dir:    spec.KeyDir,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("could not load signing keys")
+	}
+	serverOptions, err := servertls.Options(servertls.Config{
+		CertFile:     spec.TLSCert,
+		KeyFile:      spec.TLSKey,
+		ClientCAFile: spec.TLSClientCA,
+		AdminMethods: session.AdminMethods,
+		Admins:       servertls.ParseNames(spec.TLSAdmins),
+	})
+	if err != nil {
+		log.WithError(err).Fatal("could not setup TLS")
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
 	sessionServer := session.NewServer(&session.Config{
-		Secret:         []byte(spec.Secret),
-		RedisAddr:      spec.Redis,
-		RedisPassword:  spec.RedisPassword,
-		ExpirationTime: expirationTime,
+		Keys:                   keys,
+		RedisAddr:              spec.Redis,
+		RedisPassword:          spec.RedisPassword,
+		ExpirationTime:         expirationTime,
+		RememberExpirationTime: rememberExpirationTime,
 	})
 	api.RegisterSessionServer(grpcServer, sessionServer)
 	health.RegisterHealthServer(grpcServer, sessionServer.Health())